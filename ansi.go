@@ -0,0 +1,623 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// ANSI terminal rendering backend
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Ansi renderer configuration options.
+const (
+	ANSI_NO_COLOR = 1 << iota // no escape sequences, for output that is not a terminal
+)
+
+// the escape sequences that turn styles on and off
+const (
+	ansiBold         = "\x1b[1m"
+	ansiBoldOff      = "\x1b[22m"
+	ansiItalic       = "\x1b[3m"
+	ansiItalicOff    = "\x1b[23m"
+	ansiUnderline    = "\x1b[4m"
+	ansiUnderlineOff = "\x1b[24m"
+	ansiReverse      = "\x1b[7m"
+	ansiReverseOff   = "\x1b[27m"
+	ansiStrike       = "\x1b[9m"
+	ansiStrikeOff    = "\x1b[29m"
+	ansiLink         = "\x1b[4;34m"
+	ansiLinkOff      = "\x1b[24;39m"
+	ansiCode         = "\x1b[36m"
+	ansiFaint        = "\x1b[90m"
+	ansiColorOff     = "\x1b[39m"
+)
+
+// ansiWrapMark starts a line that is reflowed to the width of the terminal.
+// Paragraphs are rendered before the lists and quotes around them add their
+// prefixes, so the lines are only wrapped in DocumentFooter, once the
+// prefixes are known.
+const ansiWrapMark = '\x02'
+
+// Ansi is a type that implements the Renderer interface for output to a
+// terminal. Styles are set with ANSI escape sequences: strong text is bold,
+// emphasis italic, links underlined and blue, and code cyan. Quotes are
+// marked with a bar, code blocks indented, and list items start with a bullet
+// or their number. With a width, paragraphs are reflowed to fit it. Raw HTML
+// is dropped.
+//
+// Do not create this directly, instead use the AnsiRenderer function.
+type Ansi struct {
+	flags int // ANSI_* options
+	width int // the width to wrap paragraphs at, or 0
+
+	// the next number of each ordered list being rendered
+	listNumbers []int
+
+	// whether the last list item was a definition term
+	afterTerm bool
+}
+
+// AnsiRenderer creates and configures an Ansi object, which satisfies the
+// Renderer interface.
+//
+// flags is a set of ANSI_* options ORed together. Paragraphs are reflowed to
+// width columns, including the indentation of the lists and quotes they are
+// in; with a width of 0 their lines are kept as they are.
+func AnsiRenderer(flags int, width int) Renderer {
+	return &Ansi{
+		flags: flags,
+		width: width,
+	}
+}
+
+// ToAnsi is a convenience function that renders input for a color terminal
+// of the given width with the given options.
+func ToAnsi(input []byte, width int, opts Options) []byte {
+	return MarkdownOptions(input, AnsiRenderer(0, width), opts)
+}
+
+func (options *Ansi) GetFlags() int {
+	return options.flags
+}
+
+// write text in a style, unless colors are off
+func (options *Ansi) style(out *bytes.Buffer, on string, text []byte, off string) {
+	if options.flags&ANSI_NO_COLOR != 0 {
+		out.Write(text)
+		return
+	}
+	out.WriteString(on)
+	out.Write(text)
+	out.WriteString(off)
+}
+
+// write text in a style around the output of a callback
+func (options *Ansi) styleFunc(out *bytes.Buffer, on string, text func() bool, off string) bool {
+	color := options.flags&ANSI_NO_COLOR == 0
+	if color {
+		out.WriteString(on)
+	}
+	if !text() {
+		return false
+	}
+	if color {
+		out.WriteString(off)
+	}
+	return true
+}
+
+// write every line of text with a prefix, leaving blank lines blank
+func writeIndented(out *bytes.Buffer, text []byte, first, rest string) {
+	for i, line := range bytes.Split(bytes.Trim(text, "\n"), []byte("\n")) {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if len(line) == 0 {
+			out.WriteString(trimRightSpace(prefix))
+		} else {
+			out.WriteString(prefix)
+			out.Write(line)
+		}
+		out.WriteByte('\n')
+	}
+}
+
+func trimRightSpace(s string) string {
+	for len(s) > 0 && s[len(s)-1] == ' ' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func (options *Ansi) quoteBar() string {
+	if options.flags&ANSI_NO_COLOR != 0 {
+		return "│ "
+	}
+	return ansiFaint + "│" + ansiColorOff + " "
+}
+
+func (options *Ansi) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	blockSeparator(out)
+	for _, line := range bytes.Split(bytes.TrimRight(text, "\n"), []byte("\n")) {
+		if len(line) > 0 {
+			out.WriteString("    ")
+			options.style(out, ansiCode, line, ansiColorOff)
+		}
+		out.WriteByte('\n')
+	}
+}
+
+func (options *Ansi) BlockQuote(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	bar := options.quoteBar()
+	writeIndented(out, text, bar, bar)
+}
+
+func (options *Ansi) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {
+	blockSeparator(out)
+	bar := options.quoteBar()
+	if len(title) > 0 {
+		out.WriteString(bar)
+		options.style(out, ansiBold, title, ansiBoldOff)
+		out.WriteByte('\n')
+	}
+	writeIndented(out, text, bar, bar)
+}
+
+func (options *Ansi) Alert(out *bytes.Buffer, text []byte, kind string) {
+	options.Admonition(out, text, kind, alertTitle(kind))
+}
+
+func (options *Ansi) Div(out *bytes.Buffer, text []byte, class string) {
+	if text = bytes.Trim(text, "\n"); len(text) > 0 {
+		blockSeparator(out)
+		writeLine(out, text)
+	}
+}
+
+func (options *Ansi) BlockHtml(out *bytes.Buffer, text []byte) {
+}
+
+func (options *Ansi) MathBlock(out *bytes.Buffer, text []byte) {
+	options.BlockCode(out, text, "")
+}
+
+func (options *Ansi) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	marker := out.Len()
+	blockSeparator(out)
+	start := out.Len()
+	on, off := ansiBold, ansiBoldOff
+	if level == 1 {
+		on, off = ansiBold+ansiUnderline, ansiUnderlineOff+ansiBoldOff
+	}
+	if !options.styleFunc(out, on, text, off) || out.Len() == start {
+		out.Truncate(marker)
+		return
+	}
+	out.WriteByte('\n')
+
+	// without colors, the top headers are underlined like in the source
+	if options.flags&ANSI_NO_COLOR != 0 && level <= 2 {
+		underline := "="
+		if level == 2 {
+			underline = "-"
+		}
+		width := visibleWidth(bytes.TrimRight(out.Bytes()[start:], "\n"))
+		out.Write(bytes.Repeat([]byte(underline), width))
+		out.WriteByte('\n')
+	}
+}
+
+func (options *Ansi) HRule(out *bytes.Buffer) {
+	blockSeparator(out)
+	width := options.width
+	if width <= 0 {
+		width = 40
+	}
+	options.style(out, ansiFaint, bytes.Repeat([]byte("─"), width), ansiColorOff)
+	out.WriteByte('\n')
+}
+
+func (options *Ansi) List(out *bytes.Buffer, text func() bool, flags int) {
+	options.NumberedList(out, text, flags, 1)
+}
+
+func (options *Ansi) NumberedList(out *bytes.Buffer, text func() bool, flags, start int) {
+	marker := out.Len()
+	if len(options.listNumbers) == 0 {
+		blockSeparator(out)
+	} else if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		// nested lists follow the item text directly
+		out.WriteByte('\n')
+	}
+	options.listNumbers = append(options.listNumbers, start)
+	ok := text()
+	options.listNumbers = options.listNumbers[:len(options.listNumbers)-1]
+	if !ok {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Ansi) ListItem(out *bytes.Buffer, text []byte, flags int) {
+	afterTerm := options.afterTerm
+	options.afterTerm = flags&LIST_TYPE_TERM != 0
+
+	var prefix string
+	switch {
+	case flags&LIST_TYPE_TERM != 0:
+		// terms sharing their definitions are not separated
+		if !afterTerm {
+			blockSeparator(out)
+		}
+		options.style(out, ansiBold, bytes.TrimSpace(text), ansiBoldOff)
+		out.WriteByte('\n')
+		return
+	case flags&LIST_TYPE_DEFINITION != 0:
+		prefix = "    "
+	case flags&LIST_TYPE_ORDERED != 0:
+		n := len(options.listNumbers) - 1
+		prefix = strconv.Itoa(options.listNumbers[n]) + ". "
+		options.listNumbers[n]++
+	default:
+		prefix = "• "
+	}
+	if flags&LIST_ITEM_TASK != 0 {
+		if flags&LIST_ITEM_CHECKED != 0 {
+			prefix += "[x] "
+		} else {
+			prefix += "[ ] "
+		}
+	}
+
+	// the text of a tight item is reflowed like a paragraph
+	if options.width > 0 && len(text) > 0 && text[0] != ansiWrapMark {
+		text = append([]byte{ansiWrapMark}, text...)
+	}
+
+	// indent the lines after the first to line up with it
+	writeIndented(out, text, prefix, blankPrefix(prefix))
+}
+
+// blankPrefix turns the prefix of a line into the prefix of the lines wrapped
+// after it: the bars of quotes and the escape sequences are kept, and
+// everything else is blanked out.
+func blankPrefix(prefix string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(prefix); {
+		if n := escapeLength([]byte(prefix[i:])); n > 0 {
+			buf.WriteString(prefix[i : i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(prefix[i:])
+		if r == '│' {
+			buf.WriteRune(r)
+		} else {
+			buf.WriteByte(' ')
+		}
+		i += size
+	}
+	return buf.String()
+}
+
+func (options *Ansi) Paragraph(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	blockSeparator(out)
+	if options.width > 0 {
+		out.WriteByte(ansiWrapMark)
+	}
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+	out.WriteByte('\n')
+}
+
+func (options *Ansi) Table(out *bytes.Buffer, header []byte, body []byte, columnData []int) {
+	options.CaptionedTable(out, header, body, columnData, nil)
+}
+
+func (options *Ansi) CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte) {
+	blockSeparator(out)
+	if len(caption) > 0 {
+		writeLine(out, caption)
+	}
+
+	// the cells end in tabs; line up the columns
+	var rows [][][]byte
+	headerRows := 0
+	for i, part := range [][]byte{header, body} {
+		for _, line := range bytes.Split(bytes.TrimRight(part, "\n"), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			rows = append(rows, bytes.Split(bytes.TrimSuffix(line, []byte("\t")), []byte("\t")))
+			if i == 0 {
+				headerRows++
+			}
+		}
+	}
+	widths := make([]int, len(columnData))
+	for _, row := range rows {
+		for col, cell := range row {
+			if col < len(widths) {
+				if w := visibleWidth(cell); w > widths[col] {
+					widths[col] = w
+				}
+			}
+		}
+	}
+
+	for r, row := range rows {
+		var line bytes.Buffer
+		for col := range widths {
+			var cell []byte
+			if col < len(row) {
+				cell = row[col]
+			}
+			if col > 0 {
+				line.WriteString("  ")
+			}
+			pad := widths[col] - visibleWidth(cell)
+			left := 0
+			switch columnData[col] & TABLE_ALIGNMENT_MASK {
+			case TABLE_ALIGNMENT_RIGHT:
+				left = pad
+			case TABLE_ALIGNMENT_CENTER:
+				left = pad / 2
+			}
+			line.Write(bytes.Repeat([]byte(" "), left))
+			if r < headerRows {
+				options.style(&line, ansiBold, cell, ansiBoldOff)
+			} else {
+				line.Write(cell)
+			}
+			line.Write(bytes.Repeat([]byte(" "), pad-left))
+		}
+		writeLine(out, bytes.TrimRight(line.Bytes(), " "))
+
+		if r == headerRows-1 {
+			var rule bytes.Buffer
+			for col, width := range widths {
+				if col > 0 {
+					rule.WriteString("  ")
+				}
+				rule.Write(bytes.Repeat([]byte("─"), width))
+			}
+			options.style(out, ansiFaint, rule.Bytes(), ansiColorOff)
+			out.WriteByte('\n')
+		}
+	}
+}
+
+func (options *Ansi) TableRow(out *bytes.Buffer, text []byte) {
+	writeLine(out, text)
+}
+
+func (options *Ansi) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	options.TableCell(out, text, flags)
+}
+
+func (options *Ansi) TableCell(out *bytes.Buffer, text []byte, flags int) {
+	out.Write(text)
+	out.WriteByte('\t')
+}
+
+func (options *Ansi) Footnotes(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	options.HRule(out)
+	options.listNumbers = append(options.listNumbers, 1)
+	ok := text()
+	options.listNumbers = options.listNumbers[:len(options.listNumbers)-1]
+	if !ok {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Ansi) FootnoteItem(out *bytes.Buffer, name, text []byte, flags int) {
+	n := len(options.listNumbers) - 1
+	prefix := "[" + strconv.Itoa(options.listNumbers[n]) + "] "
+	options.listNumbers[n]++
+	writeIndented(out, bytes.TrimSpace(text), prefix, blankPrefix(prefix))
+}
+
+func (options *Ansi) TitleBlock(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	options.style(out, ansiBold, bytes.TrimRight(text, "\n"), ansiBoldOff)
+	out.WriteByte('\n')
+}
+
+func (options *Ansi) AutoLink(out *bytes.Buffer, link []byte, kind int) {
+	options.style(out, ansiLink, bytes.TrimPrefix(link, []byte("mailto:")), ansiLinkOff)
+}
+
+func (options *Ansi) CodeSpan(out *bytes.Buffer, text []byte) {
+	options.style(out, ansiCode, text, ansiColorOff)
+}
+
+func (options *Ansi) DoubleEmphasis(out *bytes.Buffer, text []byte) {
+	options.style(out, ansiBold, text, ansiBoldOff)
+}
+
+func (options *Ansi) Emphasis(out *bytes.Buffer, text []byte) {
+	options.style(out, ansiItalic, text, ansiItalicOff)
+}
+
+func (options *Ansi) Image(out *bytes.Buffer, link []byte, title []byte, alt []byte) {
+	options.Link(out, link, title, alt)
+}
+
+func (options *Ansi) LineBreak(out *bytes.Buffer) {
+	out.WriteByte('\n')
+	if options.width > 0 {
+		out.WriteByte(ansiWrapMark)
+	}
+}
+
+// links show their destination after the text, unless it is the same
+func (options *Ansi) Link(out *bytes.Buffer, link []byte, title []byte, content []byte) {
+	options.style(out, ansiLink, content, ansiLinkOff)
+	if len(link) == 0 || link[0] == '#' || bytes.Equal(link, content) {
+		return
+	}
+	out.WriteString(" (")
+	options.style(out, ansiFaint, link, ansiColorOff)
+	out.WriteByte(')')
+}
+
+func (options *Ansi) RawHtmlTag(out *bytes.Buffer, tag []byte) {
+}
+
+func (options *Ansi) TripleEmphasis(out *bytes.Buffer, text []byte) {
+	options.style(out, ansiBold+ansiItalic, text, ansiItalicOff+ansiBoldOff)
+}
+
+func (options *Ansi) StrikeThrough(out *bytes.Buffer, text []byte) {
+	options.style(out, ansiStrike, text, ansiStrikeOff)
+}
+
+func (options *Ansi) Superscript(out *bytes.Buffer, text []byte) {
+	out.WriteByte('^')
+	out.Write(text)
+}
+
+func (options *Ansi) Subscript(out *bytes.Buffer, text []byte) {
+	out.WriteByte('_')
+	out.Write(text)
+}
+
+func (options *Ansi) Highlight(out *bytes.Buffer, text []byte) {
+	options.style(out, ansiReverse, text, ansiReverseOff)
+}
+
+func (options *Ansi) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	out.Write(text)
+}
+
+func (options *Ansi) Math(out *bytes.Buffer, text []byte) {
+	options.style(out, ansiCode, text, ansiColorOff)
+}
+
+func (options *Ansi) FootnoteRef(out *bytes.Buffer, ref []byte, id int) {
+	out.WriteByte('[')
+	out.WriteString(strconv.Itoa(id))
+	out.WriteByte(']')
+}
+
+func (options *Ansi) Entity(out *bytes.Buffer, entity []byte) {
+	out.WriteString(html.UnescapeString(string(entity)))
+}
+
+func (options *Ansi) NormalText(out *bytes.Buffer, text []byte) {
+	if options.width <= 0 {
+		out.Write(text)
+		return
+	}
+
+	// the lines of paragraphs are joined to be reflowed
+	for _, c := range text {
+		switch c {
+		case '\n':
+			out.WriteByte(' ')
+		case ansiWrapMark:
+		default:
+			out.WriteByte(c)
+		}
+	}
+}
+
+func (options *Ansi) DocumentHeader(out *bytes.Buffer) {
+}
+
+func (options *Ansi) DocumentFooter(out *bytes.Buffer) {
+	if options.width <= 0 {
+		return
+	}
+
+	var doc bytes.Buffer
+	for _, line := range bytes.SplitAfter(out.Bytes(), []byte("\n")) {
+		mark := bytes.IndexByte(line, ansiWrapMark)
+		if mark < 0 {
+			doc.Write(line)
+			continue
+		}
+		options.wrap(&doc, string(line[:mark]), bytes.TrimRight(line[mark+1:], "\n"))
+		doc.WriteByte('\n')
+	}
+	out.Reset()
+	out.Write(doc.Bytes())
+}
+
+// write text after prefix, wrapping it into lines that fit the width
+func (options *Ansi) wrap(out *bytes.Buffer, prefix string, text []byte) {
+	avail := options.width - visibleWidth([]byte(prefix))
+	if avail < 10 {
+		avail = 10
+	}
+	words := bytes.Fields(text)
+	if len(words) == 0 {
+		out.WriteString(trimRightSpace(prefix))
+		return
+	}
+
+	out.WriteString(prefix)
+	rest := blankPrefix(prefix)
+	used := 0
+	for _, word := range words {
+		width := visibleWidth(word)
+		if used > 0 && used+1+width > avail {
+			out.WriteByte('\n')
+			out.WriteString(rest)
+			used = 0
+		}
+		if used > 0 {
+			out.WriteByte(' ')
+			used++
+		}
+		out.Write(word)
+		used += width
+	}
+}
+
+// the length of the escape sequence at the start of data, or 0
+func escapeLength(data []byte) int {
+	if len(data) < 2 || data[0] != '\x1b' || data[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// the number of columns text takes up on the terminal
+func visibleWidth(text []byte) int {
+	width := 0
+	for i := 0; i < len(text); {
+		if n := escapeLength(text[i:]); n > 0 {
+			i += n
+			continue
+		}
+		_, size := utf8.DecodeRune(text[i:])
+		i += size
+		width++
+	}
+	return width
+}
@@ -15,10 +15,37 @@ package blackfriday
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
+	"sync"
 	"unicode"
+	"unicode/utf8"
 )
 
+// rawBuffers holds the scratch buffers that quote and listItem collect their
+// lines in, so nested blocks don't allocate a new one at every level.
+var rawBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the largest buffer put back in rawBuffers; bigger ones
+// are left to the garbage collector rather than kept alive by the pool.
+const maxPooledBuffer = 64 << 10
+
+func getRawBuffer() *bytes.Buffer {
+	return rawBuffers.Get().(*bytes.Buffer)
+}
+
+// putRawBuffer returns a buffer to rawBuffers. Nothing may refer to its bytes
+// afterwards.
+func putRawBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	rawBuffers.Put(buf)
+}
+
 // Parse block-level data.
 // Note: this function and many that it calls assume that
 // the input buffer ends with a newline.
@@ -73,7 +100,7 @@ func (p *parser) block(out *bytes.Buffer, data []byte) {
 		}
 
 		// blank lines.  note: returns the # of bytes to skip
-		if i := p.isEmpty(data); i > 0 {
+		if i := p.skipEmptyLines(data); i > 0 {
 			data = data[i:]
 			continue
 		}
@@ -108,6 +135,18 @@ func (p *parser) block(out *bytes.Buffer, data []byte) {
 			}
 		}
 
+		// display math:
+		//
+		// $$
+		// e^{i\pi} + 1 = 0
+		// $$
+		if p.flags&EXTENSION_MATH != 0 {
+			if i := p.mathBlock(out, data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// horizontal rule:
 		//
 		// ------
@@ -133,6 +172,31 @@ func (p *parser) block(out *bytes.Buffer, data []byte) {
 			continue
 		}
 
+		// admonition:
+		//
+		// !!! warning "Mind the gap"
+		//     Content indented by four spaces,
+		//     which can hold any blocks.
+		if p.flags&EXTENSION_ADMONITIONS != 0 {
+			if i := p.admonition(out, data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
+		// fenced div:
+		//
+		// ::: warning
+		// Any blocks, up to a line
+		// of colons alone.
+		// :::
+		if p.fencedDivs {
+			if i := p.fencedDiv(out, data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// table:
 		//
 		// Name  | Age | Phone
@@ -146,6 +210,21 @@ func (p *parser) block(out *bytes.Buffer, data []byte) {
 			}
 		}
 
+		// grid table:
+		//
+		// +-------+----------+
+		// | Name  | Phone    |
+		// +=======+==========+
+		// | Bob   | 555-1234 |
+		// |       | (home)   |
+		// +-------+----------+
+		if p.flags&EXTENSION_GRID_TABLES != 0 {
+			if i := p.gridTable(out, data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// an itemized/unordered list:
 		//
 		// * Item 1
@@ -190,16 +269,21 @@ func (p *parser) block(out *bytes.Buffer, data []byte) {
 }
 
 func (p *parser) isPrefixHeader(data []byte) bool {
-	if data[0] != '#' {
+	// the header may be indented up to three spaces
+	indent := 0
+	for indent < 3 && data[indent] == ' ' {
+		indent++
+	}
+	if data[indent] != '#' {
 		return false
 	}
 
 	if p.flags&EXTENSION_SPACE_HEADERS != 0 {
 		level := 0
-		for level < 6 && data[level] == '#' {
+		for level < 6 && data[indent+level] == '#' {
 			level++
 		}
-		if data[level] != ' ' {
+		if data[indent+level] != ' ' {
 			return false
 		}
 	}
@@ -207,11 +291,12 @@ func (p *parser) isPrefixHeader(data []byte) bool {
 }
 
 func (p *parser) prefixHeader(out *bytes.Buffer, data []byte) int {
+	indent := skipChar(data, 0, ' ')
 	level := 0
-	for level < 6 && data[level] == '#' {
+	for level < 6 && data[indent+level] == '#' {
 		level++
 	}
-	i := skipChar(data, level, ' ')
+	i := skipChar(data, indent+level, ' ')
 	end := skipUntilChar(data, i, '\n')
 	skip := end
 	id := ""
@@ -243,7 +328,7 @@ func (p *parser) prefixHeader(out *bytes.Buffer, data []byte) int {
 	}
 	if end > i {
 		if id == "" && p.flags&EXTENSION_AUTO_HEADER_IDS != 0 {
-			id = SanitizedAnchorName(string(data[i:end]))
+			id = p.headerIDSlugifier(string(p.normalize(data[i:end])))
 		}
 		work := func() bool {
 			p.inline(out, data[i:end])
@@ -254,10 +339,33 @@ func (p *parser) prefixHeader(out *bytes.Buffer, data []byte) int {
 	return skip
 }
 
+// returns the text of a header line without a {#id} at its end, and the id
+func trailingHeaderID(text []byte) ([]byte, string) {
+	if len(text) < 4 || text[len(text)-1] != '}' {
+		return text, ""
+	}
+	start := bytes.LastIndex(text, []byte("{#"))
+	if start < 0 || start+3 >= len(text) || isBackslashEscaped(text, start) {
+		return text, ""
+	}
+	id := text[start+2 : len(text)-1]
+	rest := bytes.TrimRight(text[:start], " ")
+	if len(rest) == 0 || bytes.IndexAny(id, " {}") >= 0 {
+		return text, ""
+	}
+	return rest, string(id)
+}
+
 func (p *parser) isUnderlinedHeader(data []byte) int {
+	// the underline may be indented up to three spaces
+	start := 0
+	for start < 3 && data[start] == ' ' {
+		start++
+	}
+
 	// test of level 1 header
-	if data[0] == '=' {
-		i := skipChar(data, 1, '=')
+	if data[start] == '=' {
+		i := skipChar(data, start+1, '=')
 		i = skipChar(data, i, ' ')
 		if data[i] == '\n' {
 			return 1
@@ -267,8 +375,8 @@ func (p *parser) isUnderlinedHeader(data []byte) int {
 	}
 
 	// test of level 2 header
-	if data[0] == '-' {
-		i := skipChar(data, 1, '-')
+	if data[start] == '-' {
+		i := skipChar(data, start+1, '-')
 		i = skipChar(data, i, ' ')
 		if data[i] == '\n' {
 			return 2
@@ -280,6 +388,17 @@ func (p *parser) isUnderlinedHeader(data []byte) int {
 	return 0
 }
 
+// isUnderlineLongEnough reports whether the header underline at the start of
+// data is as long as the text of the line above it, if the options ask for it.
+func (p *parser) isUnderlineLongEnough(text, data []byte) bool {
+	if p.setextHeaders != SETEXT_HEADERS_FULL_UNDERLINE {
+		return true
+	}
+	start := skipChar(data, 0, ' ')
+	length := skipChar(data, start, data[start]) - start
+	return length >= utf8.RuneCount(bytes.TrimSpace(text))
+}
+
 func (p *parser) titleBlock(out *bytes.Buffer, data []byte, doRender bool) int {
 	if data[0] != '%' {
 		return 0
@@ -325,6 +444,16 @@ func (p *parser) html(out *bytes.Buffer, data []byte, doRender bool) int {
 			return size
 		}
 
+		// check for a processing instruction
+		if size := p.htmlProcessingInstruction(out, data, doRender); size > 0 {
+			return size
+		}
+
+		// check for a declaration, like <!DOCTYPE html>
+		if size := p.htmlDeclaration(out, data, doRender); size > 0 {
+			return size
+		}
+
 		// no special case recognized
 		return 0
 	}
@@ -448,6 +577,41 @@ func (p *parser) htmlCDATA(out *bytes.Buffer, data []byte, doRender bool) int {
 	return p.renderHTMLBlock(out, data, i, doRender)
 }
 
+// processing instruction, as in <?php ... ?>
+func (p *parser) htmlProcessingInstruction(out *bytes.Buffer, data []byte, doRender bool) int {
+	if len(data) < 3 || data[1] != '?' {
+		return 0
+	}
+	i := 3
+	// scan for the end marker, across lines if necessary
+	for i < len(data) && !(data[i-1] == '?' && data[i] == '>') {
+		i++
+	}
+	i++
+	// no end marker
+	if i >= len(data) {
+		return 0
+	}
+	return p.renderHTMLBlock(out, data, i, doRender)
+}
+
+// declaration, as in <!DOCTYPE html>
+func (p *parser) htmlDeclaration(out *bytes.Buffer, data []byte, doRender bool) int {
+	if len(data) < 3 || data[1] != '!' || !isletter(data[2]) {
+		return 0
+	}
+	i := 3
+	for i < len(data) && data[i] != '>' {
+		i++
+	}
+	i++
+	// no end marker
+	if i >= len(data) {
+		return 0
+	}
+	return p.renderHTMLBlock(out, data, i, doRender)
+}
+
 // HR, which is the only self-closing block tag considered
 func (p *parser) htmlHr(out *bytes.Buffer, data []byte, doRender bool) int {
 	if data[0] != '<' || (data[1] != 'h' && data[1] != 'H') || (data[2] != 'r' && data[2] != 'R') {
@@ -475,7 +639,8 @@ func (p *parser) htmlFindTag(data []byte) (string, bool) {
 	for isalnum(data[i]) {
 		i++
 	}
-	key := string(data[:i])
+	// tag names are case-insensitive
+	key := strings.ToLower(string(data[:i]))
 	if _, ok := blockTags[key]; ok {
 		return key, true
 	}
@@ -487,7 +652,7 @@ func (p *parser) htmlFindEnd(tag string, data []byte) int {
 
 	// check if tag is a match
 	closetag := []byte("</" + tag + ">")
-	if !bytes.HasPrefix(data, closetag) {
+	if len(data) < len(closetag) || !bytes.Equal(bytes.ToLower(data[:len(closetag)]), closetag) {
 		return 0
 	}
 	i := len(closetag)
@@ -530,23 +695,37 @@ func (*parser) isEmpty(data []byte) int {
 	return i + 1
 }
 
+// skipEmptyLines returns the length of the run of blank lines at the start of
+// data, so that any number of consecutive blank lines is treated as one
+func (p *parser) skipEmptyLines(data []byte) int {
+	i := 0
+	for i < len(data) {
+		n := p.isEmpty(data[i:])
+		if n == 0 {
+			break
+		}
+		i += n
+	}
+	return i
+}
+
 func (*parser) isHRule(data []byte) bool {
 	i := 0
 
 	// skip up to three spaces
-	for i < 3 && data[i] == ' ' {
+	for i < 3 && i < len(data) && data[i] == ' ' {
 		i++
 	}
 
 	// look at the hrule char
-	if data[i] != '*' && data[i] != '-' && data[i] != '_' {
+	if i >= len(data) || (data[i] != '*' && data[i] != '-' && data[i] != '_') {
 		return false
 	}
 	c := data[i]
 
 	// the whole line must be the char or whitespace
 	n := 0
-	for data[i] != '\n' {
+	for i < len(data) && data[i] != '\n' {
 		switch {
 		case data[i] == c:
 			n++
@@ -593,8 +772,9 @@ func isFenceLine(data []byte, info *string, oldmarker string, newlineOptional bo
 	}
 	marker = string(data[i-size : i])
 
-	// if this is the end marker, it must match the beginning marker
-	if oldmarker != "" && marker != oldmarker {
+	// if this is the end marker, it must use the same char as the beginning
+	// marker, and be at least as long
+	if oldmarker != "" && (c != oldmarker[0] || size < len(oldmarker)) {
 		return 0, ""
 	}
 
@@ -665,7 +845,7 @@ func isFenceLine(data []byte, info *string, oldmarker string, newlineOptional bo
 func (p *parser) fencedCodeBlock(out *bytes.Buffer, data []byte, doRender bool) int {
 	var infoString string
 	beg, marker := isFenceLine(data, &infoString, "", false)
-	if beg == 0 || beg >= len(data) {
+	if beg == 0 {
 		return 0
 	}
 
@@ -684,10 +864,8 @@ func (p *parser) fencedCodeBlock(out *bytes.Buffer, data []byte, doRender bool)
 
 		// copy the current line
 		end := skipUntilChar(data, beg, '\n') + 1
-
-		// did we reach the end of the buffer without a closing marker?
-		if end >= len(data) {
-			return 0
+		if end > len(data) {
+			end = len(data)
 		}
 
 		// verbatim copy to the working buffer
@@ -695,20 +873,83 @@ func (p *parser) fencedCodeBlock(out *bytes.Buffer, data []byte, doRender bool)
 			work.Write(data[beg:end])
 		}
 		beg = end
+
+		// when rendering, the end of the buffer closes the block without a
+		// marker; lookahead only accepts a block that is really closed
+		if beg >= len(data) {
+			if !doRender {
+				return 0
+			}
+			break
+		}
 	}
 
 	if doRender {
-		p.r.BlockCode(out, work.Bytes(), infoString)
+		p.r.BlockCode(out, p.normalizeCode(work.Bytes()), infoString)
 	}
 
 	return beg
 }
 
+// parse display math between $$ and $$, which can span several lines; the
+// closing $$ must end its line
+func (p *parser) mathBlock(out *bytes.Buffer, data []byte) int {
+	i := 0
+	for i < 3 && i < len(data) && data[i] == ' ' {
+		i++
+	}
+	if i+1 >= len(data) || data[i] != '$' || data[i+1] != '$' {
+		return 0
+	}
+	start := i + 2
+
+	for end := start; end+1 < len(data); end++ {
+		if data[end] != '$' || data[end+1] != '$' || isBackslashEscaped(data, end) {
+			continue
+		}
+		eol := end + 2
+		for eol < len(data) && data[eol] == ' ' {
+			eol++
+		}
+		if eol < len(data) && data[eol] != '\n' {
+			continue
+		}
+		if eol < len(data) {
+			eol++
+		}
+		text := bytes.TrimSpace(data[start:end])
+		if len(text) == 0 {
+			return 0
+		}
+		if r, ok := p.r.(MathRenderer); ok {
+			r.MathBlock(out, text)
+		} else {
+			code := append(append([]byte(nil), text...), '\n')
+			p.r.BlockCode(out, code, "math")
+		}
+		return eol
+	}
+	return 0
+}
+
 func (p *parser) table(out *bytes.Buffer, data []byte) int {
 	var header bytes.Buffer
-	i, columns := p.tableHeader(&header, data)
+	var caption []byte
+	i, columns := 0, []int(nil)
+
+	// a caption directly above the table
+	if p.flags&EXTENSION_TABLE_CAPTIONS != 0 {
+		if n, text := tableCaption(data); n > 0 && n < len(data) {
+			if i, columns = p.tableHeader(&header, data[n:]); i > 0 {
+				i += n
+				caption = text
+			}
+		}
+	}
 	if i == 0 {
-		return 0
+		if i, columns = p.tableHeader(&header, data); i == 0 {
+			return 0
+		}
 	}
 
 	var body bytes.Buffer
@@ -728,14 +969,122 @@ func (p *parser) table(out *bytes.Buffer, data []byte) int {
 
 		// include the newline in data sent to tableRow
 		i++
-		p.tableRow(&body, data[rowStart:i], columns, false)
+		row := data[rowStart:i]
+
+		// join continuation lines
+		for p.tableLineContinuation && i < len(data) {
+			cont := isTableContinuation(row)
+			if cont < 0 {
+				break
+			}
+			end := skipUntilChar(data, i, '\n')
+			if end >= len(data) || p.isEmpty(data[i:]) > 0 {
+				break
+			}
+			joined := make([]byte, 0, cont+end+2-i)
+			joined = append(joined, row[:cont]...)
+			joined = append(joined, ' ')
+			joined = append(joined, bytes.TrimLeft(data[i:end+1], " ")...)
+			row = joined
+			i = end + 1
+		}
+
+		p.tableRow(&body, row, columns, false)
 	}
 
-	p.r.Table(out, header.Bytes(), body.Bytes(), columns)
+	// or directly below it
+	if caption == nil && p.flags&EXTENSION_TABLE_CAPTIONS != 0 {
+		if n, text := tableCaption(data[i:]); n > 0 {
+			i += n
+			caption = text
+		}
+	}
+
+	var captionWork bytes.Buffer
+	if caption != nil {
+		p.inline(&captionWork, caption)
+	}
+
+	if r, ok := p.r.(TableCaptionRenderer); ok && captionWork.Len() > 0 {
+		r.CaptionedTable(out, header.Bytes(), body.Bytes(), columns, captionWork.Bytes())
+	} else {
+		p.r.Table(out, header.Bytes(), body.Bytes(), columns)
+	}
 
 	return i
 }
 
+// returns the size of a table caption line, [Caption text], and the caption
+// text; brackets inside the caption must be escaped, so that links are not
+// taken for captions
+func tableCaption(data []byte) (int, []byte) {
+	i := 0
+	for i < 3 && i < len(data) && data[i] == ' ' {
+		i++
+	}
+	if i >= len(data) || data[i] != '[' {
+		return 0, nil
+	}
+	start := i + 1
+	end := skipUntilChar(data, i, '\n')
+	if end >= len(data) {
+		return 0, nil
+	}
+	last := end
+	for last > start && data[last-1] == ' ' {
+		last--
+	}
+	if last <= start || data[last-1] != ']' || isBackslashEscaped(data, last-1) {
+		return 0, nil
+	}
+	last--
+	for j := start; j < last; j++ {
+		if (data[j] == '[' || data[j] == ']') && !isBackslashEscaped(data, j) {
+			return 0, nil
+		}
+	}
+	text := bytes.TrimSpace(data[start:last])
+	if len(text) == 0 {
+		return 0, nil
+	}
+	return end + 1, text
+}
+
+// replaces the escaped pipes in a table cell with plain ones, including in
+// code spans, where the escape would otherwise be kept; other escapes are
+// left for inline parsing
+func unescapePipes(cell []byte) []byte {
+	if bytes.Index(cell, []byte("\\|")) < 0 {
+		return cell
+	}
+	out := make([]byte, 0, len(cell))
+	for i := 0; i < len(cell); i++ {
+		if cell[i] == '\\' && i+1 < len(cell) && cell[i+1] == '|' && !isBackslashEscaped(cell, i) {
+			continue
+		}
+		out = append(out, cell[i])
+	}
+	return out
+}
+
+// returns the position of the backslash that continues a table row on the
+// next line, with the spaces before it trimmed, or -1 if the row doesn't end
+// in one
+func isTableContinuation(row []byte) int {
+	end := len(row)
+	for end > 0 && (row[end-1] == '\n' || row[end-1] == ' ') {
+		end--
+	}
+	if end == 0 || row[end-1] != '\\' || isBackslashEscaped(row, end-1) {
+		return -1
+	}
+	end--
+	for end > 0 && row[end-1] == ' ' {
+		end--
+	}
+	return end
+}
+
 // check if the specified position is preceded by an odd number of backslashes
 func isBackslashEscaped(data []byte, i int) bool {
 	backslashes := 0
@@ -784,11 +1133,25 @@ func (p *parser) tableHeader(out *bytes.Buffer, data []byte) (size int, columns
 	i = skipChar(data, i, ' ')
 
 	// each column header is of form: / *:?-+:? *|/ with # dashes + # colons >= 3
-	// and trailing | optional on last column
+	// and trailing | optional on last column; / *::-+:: *|/ justifies the column
 	col := 0
 	for data[i] != '\n' {
 		dashes := 0
 
+		if data[i] == ':' && data[i+1] == ':' {
+			i += 2
+			dashes += 2
+			for data[i] == '-' {
+				i++
+				dashes++
+			}
+			if data[i] != ':' || data[i+1] != ':' {
+				return
+			}
+			i += 2
+			dashes += 2
+			columns[col] = TABLE_ALIGNMENT_JUSTIFY
+		}
 		if data[i] == ':' {
 			i++
 			columns[col] |= TABLE_ALIGNMENT_LEFT
@@ -856,14 +1219,21 @@ func (p *parser) tableRow(out *bytes.Buffer, data []byte, columns []int, header
 		i++
 	}
 
-	for col = 0; col < len(columns) && i < len(data); col++ {
+	extend := p.tableOverflow == TABLE_OVERFLOW_EXTEND
+	for col = 0; i < len(data); col++ {
+		if col >= len(columns) && (!extend || len(bytes.TrimSpace(data[i:])) == 0) {
+			break
+		}
+
 		for data[i] == ' ' {
 			i++
 		}
 
 		cellStart := i
 
-		for (data[i] != '|' || isBackslashEscaped(data, i)) && data[i] != '\n' {
+		// when merging, the last cell runs to the end of the line
+		merge := p.tableOverflow == TABLE_OVERFLOW_MERGE && col == len(columns)-1
+		for (merge || data[i] != '|' || isBackslashEscaped(data, i)) && data[i] != '\n' {
 			i++
 		}
 
@@ -875,14 +1245,24 @@ func (p *parser) tableRow(out *bytes.Buffer, data []byte, columns []int, header
 		for cellEnd > cellStart && data[cellEnd-1] == ' ' {
 			cellEnd--
 		}
+		if merge && cellEnd > cellStart && data[cellEnd-1] == '|' && !isBackslashEscaped(data, cellEnd-1) {
+			cellEnd--
+			for cellEnd > cellStart && data[cellEnd-1] == ' ' {
+				cellEnd--
+			}
+		}
 
 		var cellWork bytes.Buffer
-		p.inline(&cellWork, data[cellStart:cellEnd])
+		p.inline(&cellWork, unescapePipes(data[cellStart:cellEnd]))
 
+		flags := columns[len(columns)-1]
+		if col < len(columns) {
+			flags = columns[col]
+		}
 		if header {
-			p.r.TableHeaderCell(&rowWork, cellWork.Bytes(), columns[col])
+			p.r.TableHeaderCell(&rowWork, cellWork.Bytes(), flags)
 		} else {
-			p.r.TableCell(&rowWork, cellWork.Bytes(), columns[col])
+			p.r.TableCell(&rowWork, cellWork.Bytes(), flags)
 		}
 	}
 
@@ -895,7 +1275,141 @@ func (p *parser) tableRow(out *bytes.Buffer, data []byte, columns []int, header
 		}
 	}
 
-	// silently ignore rows with too many cells
+	// rows with too many cells have been cut short, unless the extra cells
+	// were merged or extended above
+
+	p.r.TableRow(out, rowWork.Bytes())
+}
+
+// Parse a grid table. The column boundaries are given by the corners in the
+// top border; a cell whose first line has no | at a boundary spans the
+// columns on both sides of it. The rows above a border drawn with = are the
+// header. Colons in that border (or in the top border if there is no header)
+// set the alignment, as in pipe tables.
+func (p *parser) gridTable(out *bytes.Buffer, data []byte) int {
+	// collect the lines up to the last border
+	var lines [][]rune
+	i, end, count := 0, 0, 0
+	for i < len(data) {
+		j := skipUntilChar(data, i, '\n')
+		line := []rune(strings.TrimRight(string(data[i:j]), " "))
+		if len(line) == 0 || (line[0] != '+' && line[0] != '|') {
+			break
+		}
+		lines = append(lines, line)
+		i = j + 1
+		if line[0] == '+' {
+			end, count = i, len(lines)
+		}
+	}
+	lines = lines[:count]
+	if len(lines) < 3 || !isGridTableBorder(lines[0]) {
+		return 0
+	}
+
+	var bounds []int
+	for k, c := range lines[0] {
+		if c == '+' {
+			bounds = append(bounds, k)
+		}
+	}
+	last := bounds[len(bounds)-1]
+
+	// check the shape of every line, and find the header border
+	headerEnd := 0
+	for k, line := range lines {
+		if line[0] == '+' {
+			if !isGridTableBorder(line) || len(line) != last+1 || line[last] != '+' {
+				return 0
+			}
+			if headerEnd == 0 && k > 0 && k < len(lines)-1 && strings.ContainsRune(string(line), '=') {
+				headerEnd = k
+			}
+		} else if len(line) != last+1 || line[last] != '|' {
+			return 0
+		}
+	}
+
+	columns := make([]int, len(bounds)-1)
+	alignments := lines[headerEnd]
+	for col := range columns {
+		if alignments[bounds[col]+1] == ':' {
+			columns[col] |= TABLE_ALIGNMENT_LEFT
+		}
+		if alignments[bounds[col+1]-1] == ':' {
+			columns[col] |= TABLE_ALIGNMENT_RIGHT
+		}
+	}
+
+	var header, body bytes.Buffer
+	start := 1
+	for k := 1; k < len(lines); k++ {
+		if lines[k][0] != '+' {
+			continue
+		}
+		if k > start {
+			rowOut := &body
+			if k <= headerEnd {
+				rowOut = &header
+			}
+			p.gridTableRow(rowOut, lines[start:k], bounds, columns, k <= headerEnd)
+		}
+		start = k + 1
+	}
+
+	p.r.Table(out, header.Bytes(), body.Bytes(), columns)
+
+	return end
+}
+
+func isGridTableBorder(line []rune) bool {
+	if len(line) < 3 || line[0] != '+' || line[len(line)-1] != '+' {
+		return false
+	}
+	for k, c := range line {
+		if c != '+' && c != '-' && c != '=' && c != ':' {
+			return false
+		}
+		if c == '+' && k > 0 && line[k-1] == '+' {
+			return false
+		}
+	}
+	return true
+}
+
+// render one row of a grid table, from the lines between two borders
+func (p *parser) gridTableRow(out *bytes.Buffer, lines [][]rune, bounds, columns []int, header bool) {
+	var rowWork bytes.Buffer
+
+	for col := 0; col < len(columns); {
+		// a missing | means the cell spans the next column too
+		span := 1
+		for col+span < len(columns) && lines[0][bounds[col+span]] != '|' {
+			span++
+		}
+
+		var cell []string
+		for _, line := range lines {
+			text := strings.TrimSpace(string(line[bounds[col]+1 : bounds[col+span]]))
+			if text != "" || len(cell) > 0 {
+				cell = append(cell, text)
+			}
+		}
+		for len(cell) > 0 && cell[len(cell)-1] == "" {
+			cell = cell[:len(cell)-1]
+		}
+
+		var cellWork bytes.Buffer
+		p.inline(&cellWork, []byte(strings.Join(cell, "\n")))
+
+		flags := columns[col] | (span-1)<<TABLE_CELL_SPAN_SHIFT
+		if header {
+			p.r.TableHeaderCell(&rowWork, cellWork.Bytes(), flags)
+		} else {
+			p.r.TableCell(&rowWork, cellWork.Bytes(), flags)
+		}
+		col += span
+	}
 
 	p.r.TableRow(out, rowWork.Bytes())
 }
@@ -929,7 +1443,8 @@ func (p *parser) terminateBlockquote(data []byte, beg, end int) bool {
 
 // parse a blockquote fragment
 func (p *parser) quote(out *bytes.Buffer, data []byte) int {
-	var raw bytes.Buffer
+	raw := getRawBuffer()
+	defer putRawBuffer(raw)
 	beg, end := 0, 0
 	for beg < len(data) {
 		end = beg
@@ -960,12 +1475,244 @@ func (p *parser) quote(out *bytes.Buffer, data []byte) int {
 		beg = end
 	}
 
+	// renderers without alerts get the marker in a plain blockquote
 	var cooked bytes.Buffer
+	if r, ok := p.r.(AlertRenderer); ok && p.flags&EXTENSION_ALERTS != 0 {
+		if kind, skip := alertMarker(raw.Bytes()); skip > 0 {
+			if skip < raw.Len() {
+				p.block(&cooked, raw.Bytes()[skip:])
+			}
+			r.Alert(out, cooked.Bytes(), kind)
+			return end
+		}
+	}
 	p.block(&cooked, raw.Bytes())
 	p.r.BlockQuote(out, cooked.Bytes())
 	return end
 }
 
+// the kinds of GitHub-style alerts
+var alertKinds = map[string]struct{}{
+	"note":      {},
+	"tip":       {},
+	"important": {},
+	"warning":   {},
+	"caution":   {},
+}
+
+// alertMarker checks whether the text of a blockquote starts with a line
+// holding only an alert marker, as in [!NOTE]. It returns the kind, in lower
+// case, and the length of the line, or 0 if there is no marker of a known
+// kind.
+func alertMarker(data []byte) (string, int) {
+	if !bytes.HasPrefix(data, []byte("[!")) {
+		return "", 0
+	}
+	i := 2
+	for i < len(data) && isletter(data[i]) {
+		i++
+	}
+	if i >= len(data) || data[i] != ']' {
+		return "", 0
+	}
+	kind := strings.ToLower(string(data[2:i]))
+	if _, ok := alertKinds[kind]; !ok {
+		return "", 0
+	}
+	i = skipChar(data, i+1, ' ')
+	if i < len(data) && data[i] != '\n' {
+		return "", 0
+	}
+	if i < len(data) {
+		i++
+	}
+	return kind, i
+}
+
+// alertTitle returns the title of an alert: its kind, capitalized.
+func alertTitle(kind string) []byte {
+	if kind == "" {
+		return nil
+	}
+	return []byte(strings.ToUpper(kind[:1]) + kind[1:])
+}
+
+// parse an admonition: a !!! kind "title" line followed by lines indented by
+// four spaces. Without a title the kind, capitalized, is the title; an empty
+// title, "", leaves it out.
+func (p *parser) admonition(out *bytes.Buffer, data []byte) int {
+	if !bytes.HasPrefix(data, []byte("!!! ")) {
+		return 0
+	}
+	i := skipChar(data, 4, ' ')
+	kindStart := i
+	for i < len(data) && (isalnum(data[i]) || data[i] == '-' || data[i] == '_') {
+		i++
+	}
+	if i == kindStart {
+		return 0
+	}
+	kind := string(data[kindStart:i])
+	title := []byte(strings.ToUpper(kind[:1]) + kind[1:])
+
+	i = skipChar(data, i, ' ')
+	eol := skipUntilChar(data, i, '\n')
+	if i < eol {
+		// the rest of the line must be a quoted title
+		last := eol
+		for last > i && data[last-1] == ' ' {
+			last--
+		}
+		if data[i] != '"' || last-i < 2 || data[last-1] != '"' {
+			return 0
+		}
+		title = data[i+1 : last-1]
+	}
+	end := eol
+	if end < len(data) {
+		end++
+	}
+
+	// gather the indented lines and the blank lines between them
+	var raw bytes.Buffer
+	for line := end; line < len(data); {
+		next := skipUntilChar(data, line, '\n')
+		if next < len(data) {
+			next++
+		}
+		if p.isEmpty(data[line:next]) > 0 {
+			raw.WriteByte('\n')
+		} else if next-line > 4 && p.codePrefix(data[line:]) > 0 {
+			raw.Write(data[line+4 : next])
+			end = next
+		} else {
+			break
+		}
+		line = next
+	}
+
+	var titleWork bytes.Buffer
+	p.inline(&titleWork, title)
+	r, ok := p.r.(AdmonitionRenderer)
+
+	// other renderers get a blockquote that starts with the title
+	var cooked bytes.Buffer
+	if !ok && titleWork.Len() > 0 {
+		p.r.Paragraph(&cooked, func() bool {
+			cooked.Write(titleWork.Bytes())
+			return true
+		})
+	}
+	if content := bytes.TrimLeft(raw.Bytes(), "\n"); len(content) > 0 {
+		p.block(&cooked, content)
+	}
+	if ok {
+		r.Admonition(out, cooked.Bytes(), kind, titleWork.Bytes())
+	} else {
+		p.r.BlockQuote(out, cooked.Bytes())
+	}
+	return end
+}
+
+// isDivFence checks whether data starts with a fenced div line: three or more
+// colons, then a class name or {.class ...} attributes for an opening fence,
+// or nothing for a closing one. It returns the classes, separated by spaces,
+// and the length of the line, or 0 if it is not a fence.
+func isDivFence(data []byte) (string, int) {
+	i := skipChar(data, 0, ':')
+	if i < 3 {
+		return "", 0
+	}
+	i = skipChar(data, i, ' ')
+
+	var classes []string
+	switch {
+	case i < len(data) && data[i] == '{':
+		end := skipUntilChar(data, i, '}')
+		if end >= len(data) || bytes.IndexByte(data[i:end], '\n') >= 0 {
+			return "", 0
+		}
+		// only the classes are kept, ids and key=value pairs are dropped
+		for _, attr := range strings.Fields(string(data[i+1 : end])) {
+			if len(attr) > 1 && attr[0] == '.' {
+				classes = append(classes, attr[1:])
+			}
+		}
+		i = end + 1
+	case i < len(data) && data[i] != '\n':
+		start := i
+		for i < len(data) && data[i] != ' ' && data[i] != '\n' && data[i] != ':' {
+			i++
+		}
+		classes = append(classes, string(data[start:i]))
+	}
+
+	// an opening fence may be closed by colons too, as in ::: name :::
+	if len(classes) > 0 {
+		i = skipChar(data, i, ' ')
+		i = skipChar(data, i, ':')
+	}
+	i = skipChar(data, i, ' ')
+	if i < len(data) && data[i] != '\n' {
+		return "", 0
+	}
+	if i < len(data) {
+		i++
+	}
+	return strings.Join(classes, " "), i
+}
+
+// parse a fenced div: an opening fence with a class name, any blocks, and a
+// closing fence. Opening fences inside it start nested divs, and each closing
+// fence closes the innermost one still open, whatever the number of colons.
+func (p *parser) fencedDiv(out *bytes.Buffer, data []byte) int {
+	class, beg := isDivFence(data)
+	if beg == 0 || class == "" {
+		return 0
+	}
+
+	depth := 0
+	for line := beg; line < len(data); {
+		// fences inside fenced code are only code
+		if p.flags&EXTENSION_FENCED_CODE != 0 {
+			if i := p.fencedCodeBlock(out, data[line:], false); i > 0 {
+				line += i
+				continue
+			}
+		}
+
+		next := skipUntilChar(data, line, '\n')
+		if next < len(data) {
+			next++
+		}
+		if inner, i := isDivFence(data[line:next]); i > 0 {
+			switch {
+			case inner != "":
+				depth++
+			case depth > 0:
+				depth--
+			default:
+				// other renderers get the blocks inside, without a container
+				r, ok := p.r.(DivRenderer)
+				if !ok {
+					p.block(out, data[beg:line])
+					return next
+				}
+				var work bytes.Buffer
+				if line > beg {
+					p.block(&work, data[beg:line])
+				}
+				r.Div(out, work.Bytes(), class)
+				return next
+			}
+		}
+		line = next
+	}
+
+	// without a closing fence it is not a div
+	return 0
+}
+
 // returns prefix length for block code
 func (p *parser) codePrefix(data []byte) int {
 	if data[0] == ' ' && data[1] == ' ' && data[2] == ' ' && data[3] == ' ' {
@@ -1014,7 +1761,7 @@ func (p *parser) code(out *bytes.Buffer, data []byte) int {
 
 	work.WriteByte('\n')
 
-	p.r.BlockCode(out, work.Bytes(), "")
+	p.r.BlockCode(out, p.normalizeCode(work.Bytes()), "")
 
 	return i
 }
@@ -1051,13 +1798,56 @@ func (p *parser) oliPrefix(data []byte) int {
 		i++
 	}
 
-	// we need >= 1 digits followed by a dot and a space
-	if start == i || data[i] != '.' || data[i+1] != ' ' {
+	// we need >= 1 digits followed by a dot or a parenthesis and a space
+	if start == i || (data[i] != '.' && data[i] != ')') || data[i+1] != ' ' {
 		return 0
 	}
 	return i + 2
 }
 
+// returns the LIST_BULLET_* flag for the unordered list item at the start of
+// data, which can change from item to item
+func (p *parser) uliBullet(data []byte) int {
+	i := p.uliPrefix(data)
+	if i == 0 {
+		return 0
+	}
+	switch data[i-2] {
+	case '+':
+		return LIST_BULLET_PLUS
+	case '-':
+		return LIST_BULLET_DASH
+	}
+	return 0
+}
+
+// returns the LIST_DELIMITER_* flag for the ordered list item at the start of
+// data, which changes between lists
+func (p *parser) oliDelimiter(data []byte) int {
+	if i := p.oliPrefix(data); i > 0 && data[i-2] == ')' {
+		return LIST_DELIMITER_PAREN
+	}
+	return 0
+}
+
+// returns the number of an ordered list item, or 1 if it has none or the
+// number doesn't fit in an int
+func oliNumber(data []byte) int {
+	i := 0
+	for i < 3 && i < len(data) && data[i] == ' ' {
+		i++
+	}
+	start := i
+	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(string(data[start:i]))
+	if err != nil || n < 0 {
+		return 1
+	}
+	return n
+}
+
 // returns definition list item prefix
 func (p *parser) dliPrefix(data []byte) int {
 	i := 0
@@ -1074,6 +1864,25 @@ func (p *parser) dliPrefix(data []byte) int {
 
 // parse ordered or unordered list block
 func (p *parser) list(out *bytes.Buffer, data []byte, flags int) int {
+	// lists have their own nesting limit: while below it, the items of this
+	// list don't count against the block nesting the list itself is in
+	if p.listNesting < p.maxListNesting {
+		p.listNesting++
+		p.nesting--
+		defer func() {
+			p.nesting++
+			p.listNesting--
+		}()
+	}
+
+	start := 1
+	if flags&LIST_TYPE_ORDERED != 0 {
+		start = oliNumber(data)
+		flags |= p.oliDelimiter(data)
+	} else {
+		flags |= p.uliBullet(data)
+	}
+
 	i := 0
 	flags |= LIST_ITEM_BEGINNING_OF_LIST
 	work := func() bool {
@@ -1089,13 +1898,19 @@ func (p *parser) list(out *bytes.Buffer, data []byte, flags int) int {
 		return true
 	}
 
-	p.r.List(out, work, flags)
+	if r, ok := p.r.(ListStartRenderer); ok && start != 1 {
+		r.NumberedList(out, work, flags, start)
+	} else {
+		p.r.List(out, work, flags)
+	}
 	return i
 }
 
 // Parse a single list item.
 // Assumes initial prefix is already removed if this is a sublist.
 func (p *parser) listItem(out *bytes.Buffer, data []byte, flags *int) int {
+	*flags &^= LIST_ITEM_TASK | LIST_ITEM_CHECKED | LIST_BULLET_PLUS | LIST_BULLET_DASH
+
 	// keep track of the indentation of the first line
 	itemIndent := 0
 	for itemIndent < 3 && data[itemIndent] == ' ' {
@@ -1103,6 +1918,9 @@ func (p *parser) listItem(out *bytes.Buffer, data []byte, flags *int) int {
 	}
 
 	i := p.uliPrefix(data)
+	if i > 0 {
+		*flags |= p.uliBullet(data)
+	}
 	if i == 0 {
 		i = p.oliPrefix(data)
 	}
@@ -1127,6 +1945,21 @@ func (p *parser) listItem(out *bytes.Buffer, data []byte, flags *int) int {
 		i++
 	}
 
+	// task list item: [ ] or [x], followed by a space
+	if p.flags&EXTENSION_TASK_LISTS != 0 && *flags&LIST_TYPE_DEFINITION == 0 {
+		if i+3 < len(data) && data[i] == '[' && data[i+2] == ']' && data[i+3] == ' ' {
+			switch data[i+1] {
+			case ' ':
+				*flags |= LIST_ITEM_TASK
+			case 'x', 'X':
+				*flags |= LIST_ITEM_TASK | LIST_ITEM_CHECKED
+			}
+			if *flags&LIST_ITEM_TASK != 0 {
+				i = skipChar(data, i+3, ' ')
+			}
+		}
+	}
+
 	// find the end of the line
 	line := i
 	for i > 0 && data[i-1] != '\n' {
@@ -1134,7 +1967,8 @@ func (p *parser) listItem(out *bytes.Buffer, data []byte, flags *int) int {
 	}
 
 	// get working buffer
-	var raw bytes.Buffer
+	raw := getRawBuffer()
+	defer putRawBuffer(raw)
 
 	// put the first line into the working buffer
 	raw.Write(data[line:i])
@@ -1177,6 +2011,13 @@ gatherlines:
 			p.oliPrefix(chunk) > 0 ||
 			p.dliPrefix(chunk) > 0:
 
+			// a change of the delimiter of the numbers starts a new list
+			if indent <= itemIndent && *flags&LIST_TYPE_ORDERED != 0 && p.oliPrefix(chunk) > 0 &&
+				p.oliDelimiter(chunk) != *flags&LIST_DELIMITER_PAREN {
+				*flags |= LIST_ITEM_END_OF_LIST
+				break gatherlines
+			}
+
 			if containsBlankLine {
 				// end the list if the type changed after a blank line
 				if indent <= itemIndent &&
@@ -1200,6 +2041,11 @@ gatherlines:
 				sublist = raw.Len()
 			}
 
+		// each line of consecutive terms is a term of its own; a term
+		// has no prefix, so its own line is gathered here too
+		case *flags&LIST_TYPE_TERM != 0 && !containsBlankLine && raw.Len() > 0:
+			break gatherlines
+
 		// is this a nested prefix header?
 		case p.isPrefixHeader(chunk):
 			// if the header is not indented, it is not nested in the list
@@ -1306,7 +2152,10 @@ func (p *parser) renderParagraph(out *bytes.Buffer, data []byte) {
 	}
 
 	work := func() bool {
+		inParagraph := p.inParagraph
+		p.inParagraph = true
 		p.inline(out, data[beg:end])
+		p.inParagraph = inParagraph
 		return true
 	}
 	p.r.Paragraph(out, work)
@@ -1328,9 +2177,10 @@ func (p *parser) paragraph(out *bytes.Buffer, data []byte) int {
 		// did we find a blank line marking the end of the paragraph?
 		if n := p.isEmpty(current); n > 0 {
 			// did this blank line followed by a definition list item?
+			// then the lines before it are the terms
 			if p.flags&EXTENSION_DEFINITION_LISTS != 0 {
-				if i < len(data)-1 && data[i+1] == ':' {
-					return p.list(out, data[prev:], LIST_TYPE_DEFINITION)
+				if next := i + p.skipEmptyLines(current); next < len(data) && data[next] == ':' {
+					return p.list(out, data, LIST_TYPE_DEFINITION)
 				}
 			}
 
@@ -1339,8 +2189,8 @@ func (p *parser) paragraph(out *bytes.Buffer, data []byte) int {
 		}
 
 		// an underline under some text marks a header, so our paragraph ended on prev line
-		if i > 0 {
-			if level := p.isUnderlinedHeader(current); level > 0 {
+		if i > 0 && p.setextHeaders != SETEXT_HEADERS_NONE {
+			if level := p.isUnderlinedHeader(current); level > 0 && p.isUnderlineLongEnough(data[prev:i], current) {
 				// render the paragraph
 				p.renderParagraph(out, data[:prev])
 
@@ -1353,6 +2203,17 @@ func (p *parser) paragraph(out *bytes.Buffer, data []byte) int {
 					eol--
 				}
 
+				// an explicit {#id} at the end of the line wins over the
+				// generated one
+				id := ""
+				text := data[prev:eol]
+				if p.flags&EXTENSION_HEADER_IDS != 0 {
+					text, id = trailingHeaderID(text)
+				}
+				if id == "" && p.flags&EXTENSION_AUTO_HEADER_IDS != 0 {
+					id = p.headerIDSlugifier(string(p.normalize(text)))
+				}
+
 				// render the header
 				// this ugly double closure avoids forcing variables onto the heap
 				work := func(o *bytes.Buffer, pp *parser, d []byte) func() bool {
@@ -1360,12 +2221,7 @@ func (p *parser) paragraph(out *bytes.Buffer, data []byte) int {
 						pp.inline(o, d)
 						return true
 					}
-				}(out, p, data[prev:eol])
-
-				id := ""
-				if p.flags&EXTENSION_AUTO_HEADER_IDS != 0 {
-					id = SanitizedAnchorName(string(data[prev:eol]))
-				}
+				}(out, p, text)
 
 				p.r.Header(out, work, level, id)
 
@@ -1392,18 +2248,29 @@ func (p *parser) paragraph(out *bytes.Buffer, data []byte) int {
 			return i
 		}
 
-		// if there's a fenced code block, paragraph is over
+		// if there's a fenced code block, paragraph is over; the opening
+		// fence is enough, as a block that is never closed runs to the end
 		if p.flags&EXTENSION_FENCED_CODE != 0 {
-			if p.fencedCodeBlock(out, current, false) > 0 {
+			var infoString string
+			if end, _ := isFenceLine(current, &infoString, "", false); end > 0 {
+				p.renderParagraph(out, data[:i])
+				return i
+			}
+		}
+
+		// if a fenced div opens, paragraph is over
+		if p.fencedDivs {
+			if class, n := isDivFence(current); i > 0 && n > 0 && class != "" {
 				p.renderParagraph(out, data[:i])
 				return i
 			}
 		}
 
-		// if there's a definition list item, prev line is a definition term
+		// if there's a definition list item, the lines before it are
+		// definition terms
 		if p.flags&EXTENSION_DEFINITION_LISTS != 0 {
 			if p.dliPrefix(current) != 0 {
-				return p.list(out, data[prev:], LIST_TYPE_DEFINITION)
+				return p.list(out, data, LIST_TYPE_DEFINITION)
 			}
 		}
 
@@ -14,6 +14,14 @@
 package blackfriday
 
 import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -202,8 +210,24 @@ func TestPrefixHeaderSpaceExtension(t *testing.T) {
 		"*   List\n    * Nested list\n    # Nested header\n",
 		"<ul>\n<li><p>List</p>\n\n<ul>\n<li><p>Nested list</p>\n\n" +
 			"<h1>Nested header</h1></li>\n</ul></li>\n</ul>\n",
+
+		"#\n",
+		"<p>#</p>\n",
+
+		"## Closed header ##\n",
+		"<h2>Closed header</h2>\n",
+
+		"   ### Indented header\n",
+		"<h3>Indented header</h3>\n",
+
+		"    # Code\n",
+		"<pre><code># Code\n</code></pre>\n",
+
+		"Paragraph\n  ## Indented header\n",
+		"<p>Paragraph</p>\n\n<h2>Indented header</h2>\n",
 	}
 	doTestsBlock(t, tests, EXTENSION_SPACE_HEADERS)
+	doTestsBlock(t, tests, EXTENSION_SPACE_HEADERS|EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK)
 }
 
 func TestPrefixHeaderIdExtension(t *testing.T) {
@@ -314,6 +338,55 @@ func TestPrefixHeaderIdExtensionWithPrefixAndSuffix(t *testing.T) {
 	doTestsBlockWithRunner(t, tests, EXTENSION_HEADER_IDS, runnerWithRendererParameters(parameters))
 }
 
+func TestHeaderLevelOffset(t *testing.T) {
+	var tests = []string{
+		"# Title\n\n## Section\n",
+		"<h2 id=\"title\">Title</h2>\n\n<h3 id=\"section\">Section</h3>\n",
+
+		"##### Five\n\n###### Six\n",
+		"<h6 id=\"five\">Five</h6>\n\n<h6 id=\"six\">Six</h6>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_AUTO_HEADER_IDS,
+		runnerWithRendererParameters(HtmlRendererParameters{HeaderLevelOffset: 1}))
+
+	tests = []string{
+		"# Title\n\nSection\n-------\n",
+		"<h4 id=\"title\">Title</h4>\n\n<h5 id=\"section\">Section</h5>\n",
+
+		"### Three\n\n#### Four\n",
+		"<h6 id=\"three\">Three</h6>\n\n<h6 id=\"four\">Four</h6>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_AUTO_HEADER_IDS,
+		runnerWithRendererParameters(HtmlRendererParameters{HeaderLevelOffset: 3}))
+}
+
+func TestHeaderDataAnchor(t *testing.T) {
+	var tests = []string{
+		"# Header 1\n",
+		"<h1 id=\"header-1\" data-anchor=\"header-1\">Header 1</h1>\n",
+
+		"## Header *with* markup\n",
+		"<h2 id=\"header-with-markup\" data-anchor=\"header-with-markup\">Header <em>with</em> markup</h2>\n",
+
+		"# Custom {#custom-id}\n",
+		"<h1 id=\"custom-id\" data-anchor=\"custom-id\">Custom</h1>\n",
+
+		"# Header 1\n\n# Header 1\n",
+		"<h1 id=\"header-1\" data-anchor=\"header-1\">Header 1</h1>\n\n" +
+			"<h1 id=\"header-1-1\" data-anchor=\"header-1-1\">Header 1</h1>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_AUTO_HEADER_IDS|EXTENSION_HEADER_IDS, func(input string, extensions int) string {
+		renderer := HtmlRenderer(HTML_HEADER_DATA_ANCHOR, "", "")
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+
+	// no ID, no anchor
+	doTestsBlockWithRunner(t, []string{"# Header 1\n", "<h1>Header 1</h1>\n"}, 0, func(input string, extensions int) string {
+		renderer := HtmlRenderer(HTML_HEADER_DATA_ANCHOR, "", "")
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+}
+
 func TestPrefixAutoHeaderIdExtension(t *testing.T) {
 	var tests = []string{
 		"# Header 1\n",
@@ -476,10 +549,82 @@ func TestUnderlineHeaders(t *testing.T) {
 
 		"Double underline\n=====\n=====\n",
 		"<h1>Double underline</h1>\n\n<p>=====</p>\n",
+
+		"Trailing tab\n===\t\n",
+		"<h1>Trailing tab</h1>\n",
+
+		"Indented underline\n   ===\n",
+		"<h1>Indented underline</h1>\n",
+
+		"Indented underline\n  ---  \n",
+		"<h2>Indented underline</h2>\n",
+
+		"Too indented\n    ===\n",
+		"<p>Too indented\n    ===</p>\n",
+
+		"Not a header\n=== foo\n",
+		"<p>Not a header\n=== foo</p>\n",
+
+		"Not a header\n--- foo\n",
+		"<p>Not a header\n--- foo</p>\n",
+
+		"Not a header\n= = =\n",
+		"<p>Not a header\n= = =</p>\n",
 	}
 	doTestsBlock(t, tests, 0)
 }
 
+func TestSetextHeaderOptions(t *testing.T) {
+	runner := func(setext int) func(string, int) string {
+		return func(input string, extensions int) string {
+			return string(MarkdownOptions([]byte(input), HtmlRenderer(0, "", ""), Options{
+				Extensions:    extensions,
+				SetextHeaders: setext,
+			}))
+		}
+	}
+
+	// the default: any underline
+	var tests = []string{
+		"Header 1\n=\n",
+		"<h1>Header 1</h1>\n",
+
+		"Header 2\n--\n",
+		"<h2>Header 2</h2>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, runner(SETEXT_HEADERS_ANY))
+
+	tests = []string{
+		"Header 1\n========\n",
+		"<h1>Header 1</h1>\n",
+
+		"Header 2\n-----------  \n",
+		"<h2>Header 2</h2>\n",
+
+		"Héader 1\n   ========\n",
+		"<h1>Héader 1</h1>\n",
+
+		"Header 1\n=======\n",
+		"<p>Header 1\n=======</p>\n",
+
+		"Header 2\n---\n",
+		"<p>Header 2</p>\n\n<hr>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, runner(SETEXT_HEADERS_FULL_UNDERLINE))
+
+	tests = []string{
+		"Header 1\n========\n",
+		"<p>Header 1\n========</p>\n",
+
+		"Header 2\n--------\n",
+		"<p>Header 2</p>\n\n<hr>\n",
+
+		"# Header 1\n",
+		"<h1>Header 1</h1>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, runner(SETEXT_HEADERS_NONE))
+}
+
 func TestUnderlineHeadersAutoIDs(t *testing.T) {
 	var tests = []string{
 		"Header 1\n========\n",
@@ -530,6 +675,112 @@ func TestUnderlineHeadersAutoIDs(t *testing.T) {
 	doTestsBlock(t, tests, EXTENSION_AUTO_HEADER_IDS)
 }
 
+func TestUnderlineHeadersIdExtension(t *testing.T) {
+	var tests = []string{
+		"Header 1 {#someid}\n========\n",
+		"<h1 id=\"someid\">Header 1</h1>\n",
+
+		"Header 2   {#someid}  \n--------\n",
+		"<h2 id=\"someid\">Header 2</h2>\n",
+
+		"Header {#not an id}\n======\n",
+		"<h1>Header {#not an id}</h1>\n",
+
+		"{#only-an-id}\n======\n",
+		"<h1>{#only-an-id}</h1>\n",
+
+		"Escaped \\{#id}\n======\n",
+		"<h1>Escaped {#id}</h1>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_HEADER_IDS)
+}
+
+func TestHeaderIDSlugifier(t *testing.T) {
+	calls := 0
+	slugifier := func(text string) string {
+		calls++
+		return strings.Replace(text, " ", "_", -1)
+	}
+	input := "# Hello World\n\n## Sub Part {#explicit}\n\nSetext Title\n---\n"
+	expected := "<nav>\n<ul>\n<li><a href=\"#Hello_World\">Hello World</a>\n<ul>\n" +
+		"<li><a href=\"#explicit\">Sub Part</a></li>\n" +
+		"<li><a href=\"#Setext_Title\">Setext Title</a></li>\n</ul></li>\n</ul>\n</nav>\n\n" +
+		"<h1 id=\"Hello_World\">Hello World</h1>\n\n" +
+		"<h2 id=\"explicit\">Sub Part</h2>\n\n" +
+		"<h2 id=\"Setext_Title\">Setext Title</h2>\n"
+
+	actual := string(MarkdownOptions([]byte(input), HtmlRenderer(HTML_TOC, "", ""), Options{
+		Extensions:        EXTENSION_AUTO_HEADER_IDS | EXTENSION_HEADER_IDS,
+		HeaderIDSlugifier: slugifier,
+	}))
+	if actual != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", input, expected, actual)
+	}
+	if calls != 2 {
+		t.Errorf("expected the slugifier to be called twice, got %d calls", calls)
+	}
+}
+
+func TestTocLevelsAndPlaceholder(t *testing.T) {
+	runner := func(params HtmlRendererParameters) func(string, int) string {
+		return func(input string, extensions int) string {
+			renderer := HtmlRendererWithParameters(HTML_TOC, "", "", params)
+			return runMarkdownBlockWithRenderer(input, extensions, renderer)
+		}
+	}
+
+	var tests = []string{
+		"# One\n\n## Two\n\n## Three\n\n### Four\n",
+		"<nav>\n<ul>\n<li><a href=\"#one\">One</a>\n<ul>\n" +
+			"<li><a href=\"#two\">Two</a></li>\n" +
+			"<li><a href=\"#three\">Three</a>\n<ul>\n<li><a href=\"#four\">Four</a></li>\n</ul></li>\n" +
+			"</ul></li>\n</ul>\n</nav>\n\n" +
+			"<h1 id=\"one\">One</h1>\n\n<h2 id=\"two\">Two</h2>\n\n<h2 id=\"three\">Three</h2>\n\n<h3 id=\"four\">Four</h3>\n",
+
+		"# One\n\n[TOC]\n\n## Two\n",
+		"<h1 id=\"one\">One</h1>\n\n" +
+			"<nav>\n<ul>\n<li><a href=\"#one\">One</a>\n<ul>\n<li><a href=\"#two\">Two</a></li>\n</ul></li>\n</ul>\n</nav>\n\n" +
+			"<h2 id=\"two\">Two</h2>\n",
+
+		"> [TOC]\n\n# One\n",
+		"<nav>\n<ul>\n<li><a href=\"#one\">One</a></li>\n</ul>\n</nav>\n\n" +
+			"<blockquote>\n<p>[TOC]</p>\n</blockquote>\n\n<h1 id=\"one\">One</h1>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_AUTO_HEADER_IDS, runner(HtmlRendererParameters{}))
+
+	tests = []string{
+		"# One\n\n## Two\n\n## Three\n\n### Four\n\n#### Five\n",
+		"<nav>\n<ul>\n<li><a href=\"#two\">Two</a></li>\n" +
+			"<li><a href=\"#three\">Three</a>\n<ul>\n<li><a href=\"#four\">Four</a></li>\n</ul></li>\n" +
+			"</ul>\n</nav>\n\n" +
+			"<h1 id=\"one\">One</h1>\n\n<h2 id=\"two\">Two</h2>\n\n<h2 id=\"three\">Three</h2>\n\n" +
+			"<h3 id=\"four\">Four</h3>\n\n<h4 id=\"five\">Five</h4>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_AUTO_HEADER_IDS, runner(HtmlRendererParameters{TocMinLevel: 2, TocMaxLevel: 3}))
+}
+
+func TestTocRendererReuse(t *testing.T) {
+	renderer := HtmlRenderer(HTML_TOC, "", "")
+	Markdown([]byte("# A\n\npara\n\n[TOC]\n\n# B\n"), renderer, EXTENSION_AUTO_HEADER_IDS)
+
+	// the placeholder and the headers of the first document are gone
+	expected := "<nav>\n<ul>\n<li><a href=\"#c\">C</a></li>\n</ul>\n</nav>\n\n<h1 id=\"c\">C</h1>\n"
+	if actual := string(Markdown([]byte("# C\n"), renderer, EXTENSION_AUTO_HEADER_IDS)); actual != expected {
+		t.Errorf("second document:\nExpected[%#v]\nActual  [%#v]", expected, actual)
+	}
+}
+
+func TestHeaderIDsExplicitAndDuplicate(t *testing.T) {
+	var tests = []string{
+		"# Intro\n\nIntro\n=====\n\n## Intro\n",
+		"<h1 id=\"intro\">Intro</h1>\n\n<h1 id=\"intro-1\">Intro</h1>\n\n<h2 id=\"intro-2\">Intro</h2>\n",
+
+		"# Intro {#start}\n\nIntro {#start}\n-----\n\n# Intro\n",
+		"<h1 id=\"start\">Intro</h1>\n\n<h2 id=\"start-1\">Intro</h2>\n\n<h1 id=\"intro\">Intro</h1>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_HEADER_IDS|EXTENSION_AUTO_HEADER_IDS)
+}
+
 func TestHorizontalRule(t *testing.T) {
 	var tests = []string{
 		"-\n",
@@ -595,6 +846,32 @@ func TestHorizontalRule(t *testing.T) {
 	doTestsBlock(t, tests, 0)
 }
 
+func TestBlockquoteParagraphs(t *testing.T) {
+	var tests = []string{
+		"> one\n>\n> two\n",
+		"<blockquote>\n<p>one</p>\n\n<p>two</p>\n</blockquote>\n",
+
+		">one\n>\n>two\n",
+		"<blockquote>\n<p>one</p>\n\n<p>two</p>\n</blockquote>\n",
+
+		"> one\n>   \t\n> two\n",
+		"<blockquote>\n<p>one</p>\n\n<p>two</p>\n</blockquote>\n",
+
+		"> one\n>\n>\n> two\n",
+		"<blockquote>\n<p>one</p>\n\n<p>two</p>\n</blockquote>\n",
+
+		"> one\r\n>\r\n> two\r\n",
+		"<blockquote>\n<p>one</p>\n\n<p>two</p>\n</blockquote>\n",
+
+		"> > one\n> >\n> > two\n",
+		"<blockquote>\n<blockquote>\n<p>one</p>\n\n<p>two</p>\n</blockquote>\n</blockquote>\n",
+
+		"* item\n\n    > one\n    >\n    > two\n",
+		"<ul>\n<li><p>item</p>\n\n<blockquote>\n<p>one</p>\n\n<p>two</p>\n</blockquote></li>\n</ul>\n",
+	}
+	doTestsBlock(t, tests, 0)
+}
+
 func TestUnorderedList(t *testing.T) {
 	var tests = []string{
 		"* Hello\n",
@@ -725,6 +1002,100 @@ qux
 	doTestsBlock(t, tests, 0)
 }
 
+func TestTaskList(t *testing.T) {
+	var tests = []string{
+		"* [ ] todo\n* [x] done\n* [X] also done\n* plain\n",
+		"<ul>\n<li class=\"task-list-item\"><input type=\"checkbox\" disabled=\"\" /> todo</li>\n" +
+			"<li class=\"task-list-item\"><input type=\"checkbox\" disabled=\"\" checked=\"\" /> done</li>\n" +
+			"<li class=\"task-list-item\"><input type=\"checkbox\" disabled=\"\" checked=\"\" /> also done</li>\n" +
+			"<li>plain</li>\n</ul>\n",
+
+		"1. [x] ordered\n2. [ ] list\n",
+		"<ol>\n<li class=\"task-list-item\"><input type=\"checkbox\" disabled=\"\" checked=\"\" /> ordered</li>\n" +
+			"<li class=\"task-list-item\"><input type=\"checkbox\" disabled=\"\" /> list</li>\n</ol>\n",
+
+		"* [x] loose\n\n* [ ] list\n",
+		"<ul>\n<li class=\"task-list-item\"><p><input type=\"checkbox\" disabled=\"\" checked=\"\" /> loose</p></li>\n\n" +
+			"<li class=\"task-list-item\"><p><input type=\"checkbox\" disabled=\"\" /> list</p></li>\n</ul>\n",
+
+		"* [x]\n* [ ]no space\n* [y] other\n* text [x] in the middle\n",
+		"<ul>\n<li>[x]</li>\n<li>[ ]no space</li>\n<li>[y] other</li>\n<li>text [x] in the middle</li>\n</ul>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_TASK_LISTS)
+
+	// without the extension, they are just text
+	doTestsBlock(t, []string{
+		"* [ ] todo\n* [x] done\n",
+		"<ul>\n<li>[ ] todo</li>\n<li>[x] done</li>\n</ul>\n",
+	}, 0)
+}
+
+func TestTaskListMarkdown(t *testing.T) {
+	var tests = []string{
+		"- [x] done\n- [ ] todo\n",
+		"* [x] done\n* [ ] todo\n",
+
+		"1. [X] first\n    - [ ] nested\n",
+		"1. [x] first\n    * [ ] nested\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TASK_LISTS, func(input string, extensions int) string {
+		normalized := Markdown([]byte(input), MarkdownRenderer(extensions), extensions)
+
+		// the checked state must survive the round trip
+		expected := runMarkdownBlock(input, extensions)
+		if actual := runMarkdownBlock(string(normalized), extensions); actual != expected {
+			return fmt.Sprintf("%s(renders as %q instead of %q)", normalized, actual, expected)
+		}
+		return string(normalized)
+	})
+}
+
+func TestSetTaskChecked(t *testing.T) {
+	input := "* [ ] one\n    * [x] nested\n* [ ] two\n\n```\n* [ ] code\n```\n"
+	var tests = []struct {
+		item     int
+		checked  bool
+		expected string
+	}{
+		{0, true, "* [x] one\n    * [x] nested\n* [ ] two\n\n```\n* [ ] code\n```\n"},
+		{1, false, "* [ ] one\n    * [ ] nested\n* [ ] two\n\n```\n* [ ] code\n```\n"},
+		{2, true, "* [ ] one\n    * [x] nested\n* [x] two\n\n```\n* [ ] code\n```\n"},
+		{3, true, "* [ ] one\n    * [x] nested\n* [ ] two\n\n```\n* [ ] code\n```\n"},
+	}
+	extensions := EXTENSION_TASK_LISTS | EXTENSION_FENCED_CODE
+	for _, test := range tests {
+		actual := string(SetTaskChecked([]byte(input), extensions, test.item, test.checked))
+		if actual != test.expected {
+			t.Errorf("\nItem    [%d]\nExpected[%#v]\nActual  [%#v]", test.item, test.expected, actual)
+		}
+	}
+
+	// toggling an item and rendering again checks its box
+	toggled := SetTaskChecked([]byte("* [ ] todo\n"), EXTENSION_TASK_LISTS, 0, true)
+	expected := "<ul>\n<li class=\"task-list-item\"><input type=\"checkbox\" disabled=\"\" checked=\"\" /> todo</li>\n</ul>\n"
+	if actual := runMarkdownBlock(string(toggled), EXTENSION_TASK_LISTS); actual != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", string(toggled), expected, actual)
+	}
+}
+
+func TestTaskListCheckedStyle(t *testing.T) {
+	var tests = []string{
+		"* [ ] todo\n* [x] *done*\n* plain\n",
+		"<ul>\n<li class=\"task-list-item\"><input type=\"checkbox\" disabled=\"\" /> todo</li>\n" +
+			"<li class=\"task-list-item completed\"><input type=\"checkbox\" disabled=\"\" checked=\"\" /> <del><em>done</em></del></li>\n" +
+			"<li>plain</li>\n</ul>\n",
+
+		"* [x] loose\n\n    more\n\n* [ ] list\n",
+		"<ul>\n<li class=\"task-list-item completed\"><p><input type=\"checkbox\" disabled=\"\" checked=\"\" /> <del>loose</del></p>\n\n" +
+			"<p>more</p></li>\n\n" +
+			"<li class=\"task-list-item\"><p><input type=\"checkbox\" disabled=\"\" /> list</p></li>\n</ul>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TASK_LISTS, runnerWithRendererParameters(HtmlRendererParameters{
+		TaskCheckedClass: "completed",
+		TaskCheckedDel:   true,
+	}))
+}
+
 func TestFencedCodeBlockWithinList(t *testing.T) {
 	doTestsBlock(t, []string{
 		"* Foo\n\n    ```\n    bar\n\n    qux\n    ```\n",
@@ -862,6 +1233,246 @@ qux
 	doTestsBlock(t, tests, 0)
 }
 
+func TestLooseList(t *testing.T) {
+	var tests = []string{
+		"* a\n* b\n",
+		"<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n",
+
+		"* a\n\n* b\n",
+		"<ul>\n<li><p>a</p></li>\n\n<li><p>b</p></li>\n</ul>\n",
+
+		"1. a\n2. b\n",
+		"<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n",
+
+		"1. a\n\n2. b\n",
+		"<ol>\n<li><p>a</p></li>\n\n<li><p>b</p></li>\n</ol>\n",
+	}
+	doTestsBlock(t, tests, 0)
+}
+
+func TestOrderedListStart(t *testing.T) {
+	var tests = []string{
+		"5. Five\n6. Six\n",
+		"<ol start=\"5\">\n<li>Five</li>\n<li>Six</li>\n</ol>\n",
+
+		"1. One\n2. Two\n",
+		"<ol>\n<li>One</li>\n<li>Two</li>\n</ol>\n",
+
+		"123. Multi-digit\n",
+		"<ol start=\"123\">\n<li>Multi-digit</li>\n</ol>\n",
+
+		"007. Leading zeros\n",
+		"<ol start=\"7\">\n<li>Leading zeros</li>\n</ol>\n",
+
+		"0. Zero\n",
+		"<ol start=\"0\">\n<li>Zero</li>\n</ol>\n",
+
+		"99999999999999999999999. Too big\n",
+		"<ol>\n<li>Too big</li>\n</ol>\n",
+
+		"* Unordered\n",
+		"<ul>\n<li>Unordered</li>\n</ul>\n",
+
+		// nested lists start from their own first item
+		"3. Three\n\n   2. Two\n   3. Three\n\n4. Four\n",
+		"<ol start=\"3\">\n<li><p>Three</p>\n\n<ol start=\"2\">\n<li>Two</li>\n<li>Three</li>\n</ol></li>\n\n<li><p>Four</p></li>\n</ol>\n",
+
+		"3. Three\n   2. Two\n4. Four\n",
+		"<ol start=\"3\">\n<li>Three\n\n<ol start=\"2\">\n<li>Two</li>\n</ol></li>\n<li>Four</li>\n</ol>\n",
+
+		"1. One\n\n   5. Five\n\n      7. Seven\n",
+		"<ol>\n<li><p>One</p>\n\n<ol start=\"5\">\n<li><p>Five</p>\n\n<ol start=\"7\">\n<li>Seven</li>\n</ol></li>\n</ol></li>\n</ol>\n",
+	}
+	doTestsBlock(t, tests, 0)
+}
+
+func TestLatexNestedOrderedListStart(t *testing.T) {
+	input := "3. Three\n\n   2. Two\n\n      5. Five\n"
+	expected := []string{
+		"\\setcounter{enumi}{2}",
+		"\\setcounter{enumii}{1}",
+		"\\setcounter{enumiii}{4}",
+	}
+	actual := runMarkdownBlockWithRenderer(input, 0, LatexRenderer(0))
+	for _, counter := range expected {
+		if !strings.Contains(actual, counter) {
+			t.Errorf("Expected %s in:\n%s", counter, actual)
+		}
+	}
+}
+
+func TestOrderedListParenDelimiter(t *testing.T) {
+	var tests = []string{
+		"1) One\n2) Two\n",
+		"<ol>\n<li>One</li>\n<li>Two</li>\n</ol>\n",
+
+		"3) Three\n\n4) Four\n",
+		"<ol start=\"3\">\n<li><p>Three</p></li>\n\n<li><p>Four</p></li>\n</ol>\n",
+
+		// a change of delimiter starts a new list
+		"1. One\n2) Two\n",
+		"<ol>\n<li>One</li>\n</ol>\n\n<ol start=\"2\">\n<li>Two</li>\n</ol>\n",
+
+		"1) One\n\n2. Two\n3. Three\n",
+		"<ol>\n<li>One</li>\n</ol>\n\n<ol start=\"2\">\n<li>Two</li>\n<li>Three</li>\n</ol>\n",
+
+		// but not in a nested list
+		"1. One\n    1) Nested\n2. Two\n",
+		"<ol>\n<li>One\n\n<ol>\n<li>Nested</li>\n</ol></li>\n<li>Two</li>\n</ol>\n",
+
+		"1)Not a list\n",
+		"<p>1)Not a list</p>\n",
+	}
+	doTestsBlock(t, tests, 0)
+}
+
+// bulletRecorder records the bullet of each list item, and of each list
+type bulletRecorder struct {
+	nullRenderer
+	bullets []string
+}
+
+func bulletOf(flags int) string {
+	switch {
+	case flags&LIST_TYPE_ORDERED != 0:
+		return "1."
+	case flags&LIST_BULLET_PLUS != 0:
+		return "+"
+	case flags&LIST_BULLET_DASH != 0:
+		return "-"
+	}
+	return "*"
+}
+
+func (r *bulletRecorder) List(out *bytes.Buffer, text func() bool, flags int) {
+	r.bullets = append(r.bullets, "list "+bulletOf(flags))
+	text()
+}
+
+func (r *bulletRecorder) ListItem(out *bytes.Buffer, text []byte, flags int) {
+	r.bullets = append(r.bullets, bulletOf(flags))
+}
+
+func TestListBullets(t *testing.T) {
+	tests := []struct {
+		input   string
+		bullets string
+	}{
+		{"* a\n* b\n", "list *, *, *"},
+		{"+ a\n+ b\n", "list +, +, +"},
+		{"- a\n- b\n", "list -, -, -"},
+		{"- a\n* b\n+ c\n", "list -, -, *, +"},
+		{"- a\n\n    + b\n\n    1. c\n\n- d\n", "list -, list +, +, list 1., 1., -, -"},
+		{"+ a\n\n    b\n\n+ c\n", "list +, +, +"},
+	}
+	for _, test := range tests {
+		r := &bulletRecorder{}
+		Markdown([]byte(test.input), r, 0)
+		if got := strings.Join(r.bullets, ", "); got != test.bullets {
+			t.Errorf("bullets of %q:\ngot  %s\nwant %s", test.input, got, test.bullets)
+		}
+	}
+
+	// the Markdown renderer can keep them
+	var md = []string{
+		"- a\n- b\n\n+ c\n",
+		"- a\n- b\n\n+ c\n",
+
+		"- a\n    + b\n    * c\n",
+		"- a\n    + b\n    * c\n",
+	}
+	doTestsBlockWithRunner(t, md, 0, func(input string, extensions int) string {
+		return string(Markdown([]byte(input), MarkdownRendererWithFlags(extensions, MD_PRESERVE_BULLETS), extensions))
+	})
+	md = []string{
+		"- a\n+ b\n",
+		"* a\n* b\n",
+	}
+	doTestsBlockWithRunner(t, md, 0, func(input string, extensions int) string {
+		return string(Markdown([]byte(input), MarkdownRenderer(extensions), extensions))
+	})
+}
+
+func TestConsecutiveBlankLines(t *testing.T) {
+	var lists = []string{
+		"* one\n* two\n%s1. three\n2. four\n",
+		"* one\n* two\n%s* three\n* four\n",
+		"* one\n%s    more\n* two\n",
+		"para\n%spara\n",
+	}
+	for _, list := range lists {
+		single := runMarkdownBlock(fmt.Sprintf(list, "\n"), 0)
+		triple := runMarkdownBlock(fmt.Sprintf(list, "\n\n\n"), 0)
+		if single != triple {
+			t.Errorf("\nInput   [%#v]\nOne blank line   [%#v]\nThree blank lines[%#v]",
+				list, single, triple)
+		}
+	}
+
+	var tests = []string{
+		"* one\n* two\n\n\n\n1. three\n2. four\n",
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n\n<ol>\n<li>three</li>\n<li>four</li>\n</ol>\n",
+
+		"Term\n\n\n\n: Definition\n",
+		"<dl>\n<dt>Term</dt>\n<dd><p>Definition</p></dd>\n</dl>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_DEFINITION_LISTS)
+}
+
+func TestDeeplyNestedList(t *testing.T) {
+	const depth = 20
+	var input, expected bytes.Buffer
+	for i := 1; i <= depth; i++ {
+		fmt.Fprintf(&input, "%s* level %d\n", strings.Repeat("    ", i-1), i)
+		fmt.Fprintf(&expected, "<ul>\n<li>level %d", i)
+		if i < depth {
+			expected.WriteString("\n\n")
+		}
+	}
+	expected.WriteString(strings.Repeat("</li>\n</ul>", depth))
+	expected.WriteString("\n")
+
+	renderer := HtmlRenderer(0, "", "")
+	actual := string(MarkdownOptions(input.Bytes(), renderer, Options{MaxListNesting: depth}))
+	if actual != expected.String() {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]",
+			input.String(), expected.String(), actual)
+	}
+
+	// lists beyond the list nesting limit count against the block nesting
+	// limit and are truncated
+	actual = string(MarkdownOptions(input.Bytes(), renderer, Options{MaxListNesting: 1}))
+	if !strings.Contains(actual, "level 10") || strings.Contains(actual, "level 20") {
+		t.Errorf("\nExpected truncated output, got [%#v]", actual)
+	}
+}
+
+func TestMaxNesting(t *testing.T) {
+	input := []byte(strings.Repeat("> ", 20) + "deep\n")
+	renderer := HtmlRenderer(0, "", "")
+
+	// nested deeper than the default, the quoted text is dropped
+	actual := string(MarkdownOptions(input, renderer, Options{}))
+	if strings.Contains(actual, "deep") || strings.Count(actual, "<blockquote>") != MAX_NESTING_DEFAULT {
+		t.Errorf("\nInput   [%#v]\nActual  [%#v]", string(input), actual)
+	}
+
+	actual = string(MarkdownOptions(input, renderer, Options{MaxNesting: 32}))
+	expected := strings.Repeat("<blockquote>\n", 20) + "<p>deep</p>\n" + strings.Repeat("</blockquote>\n", 20)
+	if actual != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", string(input), expected, actual)
+	}
+
+	// the limit is only a configuration knob: much deeper documents are
+	// rendered in full when it is raised
+	input = []byte(strings.Repeat(">", 200) + " deep\n")
+	actual = string(MarkdownOptions(input, renderer, Options{MaxNesting: 256}))
+	expected = strings.Repeat("<blockquote>\n", 200) + "<p>deep</p>\n" + strings.Repeat("</blockquote>\n", 200)
+	if actual != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", string(input), expected, actual)
+	}
+}
+
 func TestDefinitionList(t *testing.T) {
 	var tests = []string{
 		"Term 1\n:   Definition a\n",
@@ -968,6 +1579,46 @@ func TestDefinitionList(t *testing.T) {
 			"<p>Text 1</p>\n\n" +
 			"<ol>\n<li>First</li>\n<li>Second</li>\n</ol></dd>\n" +
 			"</dl>\n",
+
+		// consecutive terms share the definitions after them
+		"Term 1\nTerm 2\n:   Definition\n",
+		"<dl>\n<dt>Term 1</dt>\n<dt>Term 2</dt>\n<dd>Definition</dd>\n</dl>\n",
+
+		"Term 1\nTerm 2\nTerm 3\n\n:   Definition\n",
+		"<dl>\n<dt>Term 1</dt>\n<dt>Term 2</dt>\n<dt>Term 3</dt>\n<dd><p>Definition</p></dd>\n</dl>\n",
+
+		"Term 1\n:   Definition a\n:   Definition b\n\nTerm 2\nTerm 3\n:   Definition c\n\nText\n",
+		"<dl>\n<dt>Term 1</dt>\n<dd>Definition a</dd>\n<dd>Definition b</dd>\n" +
+			"<dt>Term 2</dt>\n<dt>Term 3</dt>\n<dd>Definition c</dd>\n</dl>\n\n<p>Text</p>\n",
+
+		"Text\n\nTerm 1\nTerm 2\n:   Definition\n",
+		"<p>Text</p>\n\n<dl>\n<dt>Term 1</dt>\n<dt>Term 2</dt>\n<dd>Definition</dd>\n</dl>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_DEFINITION_LISTS)
+}
+
+func TestLooseDefinitionList(t *testing.T) {
+	var tests = []string{
+		"Term\n:   Definition\n",
+		"<dl>\n<dt>Term</dt>\n<dd>Definition</dd>\n</dl>\n",
+
+		"Term\n\n:   Definition\n",
+		"<dl>\n<dt>Term</dt>\n<dd><p>Definition</p></dd>\n</dl>\n",
+
+		"Term\n   \n:   Definition\n",
+		"<dl>\n<dt>Term</dt>\n<dd><p>Definition</p></dd>\n</dl>\n",
+
+		"Term\n\n\n:   Definition\n",
+		"<dl>\n<dt>Term</dt>\n<dd><p>Definition</p></dd>\n</dl>\n",
+
+		"Term\n\n:   Definition\nlazy line\n",
+		"<dl>\n<dt>Term</dt>\n<dd><p>Definition\nlazy line</p></dd>\n</dl>\n",
+
+		"Term\n\n:   Definition a\n:   Definition b\n",
+		"<dl>\n<dt>Term</dt>\n<dd><p>Definition a</p></dd>\n<dd><p>Definition b</p></dd>\n</dl>\n",
+
+		"Text\n\nTerm\n\n:   Definition\n\nText\n",
+		"<p>Text</p>\n\n<dl>\n<dt>Term</dt>\n<dd><p>Definition</p></dd>\n</dl>\n\n<p>Text</p>\n",
 	}
 	doTestsBlock(t, tests, EXTENSION_DEFINITION_LISTS)
 }
@@ -1021,10 +1672,42 @@ func TestPreformattedHtml(t *testing.T) {
 
 		"Paragraph\n\n<div>\nHow about here? >&<\n</div>\n\nAnd here?\n",
 		"<p>Paragraph</p>\n\n<div>\nHow about here? >&<\n</div>\n\n<p>And here?</p>\n",
+
+		"<DIV>\nUpper *case* tags\n</Div>\n",
+		"<DIV>\nUpper *case* tags\n</Div>\n",
+
+		"<?php\necho '*not* emphasis';\n?>\n",
+		"<?php\necho '*not* emphasis';\n?>\n",
+
+		"<?php unclosed\n",
+		"<p>&lt;?php unclosed</p>\n",
+
+		"<!DOCTYPE html>\n",
+		"<!DOCTYPE html>\n",
+
+		"<!DOCTYPE html>\nParagraph\n",
+		"<!DOCTYPE html>\n\n<p>Paragraph</p>\n",
+
+		"<!1 not a declaration>\n",
+		"<p>&lt;!1 not a declaration&gt;</p>\n",
 	}
 	doTestsBlock(t, tests, 0)
 }
 
+func TestSkipHtmlBlocks(t *testing.T) {
+	var tests = []string{
+		"<div>\nRaw *html*\n</div>\n\nParagraph\n",
+		"<p>Paragraph</p>\n",
+
+		"<!-- comment -->\n\n<?php echo 1; ?>\n\n<!DOCTYPE html>\n\nParagraph\n",
+		"<p>Paragraph</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		renderer := HtmlRenderer(HTML_SKIP_HTML, "", "")
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+}
+
 func TestPreformattedHtmlLax(t *testing.T) {
 	var tests = []string{
 		"Paragraph\n<div>\nHere? >&<\n</div>\n",
@@ -1048,6 +1731,35 @@ func TestPreformattedHtmlLax(t *testing.T) {
 	doTestsBlock(t, tests, EXTENSION_LAX_HTML_BLOCKS)
 }
 
+func TestIndentedCodeBlock(t *testing.T) {
+	var tests = []string{
+		"    func foo() bool {\n        return true;\n    }\n",
+		"<pre><code>func foo() bool {\n    return true;\n}\n</code></pre>\n",
+
+		"\tone tab\n\t\ttwo tabs\n",
+		"<pre><code>one tab\n    two tabs\n</code></pre>\n",
+
+		"    blank lines\n\n      \n    in the middle\n\n\n",
+		"<pre><code>blank lines\n\n\nin the middle\n</code></pre>\n",
+
+		"    <escaped> & \"quoted\"\n",
+		"<pre><code>&lt;escaped&gt; &amp; &quot;quoted&quot;\n</code></pre>\n",
+
+		"   three spaces are not enough\n",
+		"<p>three spaces are not enough</p>\n",
+
+		"Paragraph\n    lazy continuation\n",
+		"<p>Paragraph\n    lazy continuation</p>\n",
+
+		"*   List item\n    continuation\n",
+		"<ul>\n<li>List item\ncontinuation</li>\n</ul>\n",
+
+		"*   List item\n\n        code in a list\n",
+		"<ul>\n<li><p>List item</p>\n\n<pre><code>code in a list\n</code></pre></li>\n</ul>\n",
+	}
+	doTestsBlock(t, tests, 0)
+}
+
 func TestFencedCodeBlock(t *testing.T) {
 	var tests = []string{
 		"``` go\nfunc foo() bool {\n\treturn true;\n}\n```\n",
@@ -1075,7 +1787,7 @@ func TestFencedCodeBlock(t *testing.T) {
 		"<pre><code class=\"language-ocaml\">with extra whitespace\n</code></pre>\n",
 
 		"~ ~~ java\nWith whitespace\n~~~\n",
-		"<p>~ ~~ java\nWith whitespace\n~~~</p>\n",
+		"<p>~ ~~ java\nWith whitespace</p>\n\n<pre><code></code></pre>\n",
 
 		"~~\nonly two\n~~\n",
 		"<p>~~\nonly two\n~~</p>\n",
@@ -1084,25 +1796,25 @@ func TestFencedCodeBlock(t *testing.T) {
 		"<pre><code class=\"language-python\">extra\n</code></pre>\n",
 
 		"~~~ perl\nthree to start, four to end\n~~~~\n",
-		"<p>~~~ perl\nthree to start, four to end\n~~~~</p>\n",
+		"<pre><code class=\"language-perl\">three to start, four to end\n</code></pre>\n",
 
 		"~~~~ perl\nfour to start, three to end\n~~~\n",
-		"<p>~~~~ perl\nfour to start, three to end\n~~~</p>\n",
+		"<pre><code class=\"language-perl\">four to start, three to end\n~~~\n</code></pre>\n",
 
 		"~~~ bash\ntildes\n~~~\n",
 		"<pre><code class=\"language-bash\">tildes\n</code></pre>\n",
 
 		"``` lisp\nno ending\n",
-		"<p>``` lisp\nno ending</p>\n",
+		"<pre><code class=\"language-lisp\">no ending\n</code></pre>\n",
 
 		"~~~ lisp\nend with language\n~~~ lisp\n",
-		"<p>~~~ lisp\nend with language\n~~~ lisp</p>\n",
+		"<pre><code class=\"language-lisp\">end with language\n~~~ lisp\n</code></pre>\n",
 
 		"```\nmismatched begin and end\n~~~\n",
-		"<p>```\nmismatched begin and end\n~~~</p>\n",
+		"<pre><code>mismatched begin and end\n~~~\n</code></pre>\n",
 
 		"~~~\nmismatched begin and end\n```\n",
-		"<p>~~~\nmismatched begin and end\n```</p>\n",
+		"<pre><code>mismatched begin and end\n```\n</code></pre>\n",
 
 		"   ``` oz\nleading spaces\n```\n",
 		"<pre><code class=\"language-oz\">leading spaces\n</code></pre>\n",
@@ -1139,6 +1851,515 @@ func TestFencedCodeBlock(t *testing.T) {
 
 		"```\n[]:()\n[]:)\n[]:(\n[]:x\n[]:testing\n[:testing\n\n[]:\nlinebreak\n[]()\n\n[]:\n[]()\n```",
 		"<pre><code>[]:()\n[]:)\n[]:(\n[]:x\n[]:testing\n[:testing\n\n[]:\nlinebreak\n[]()\n\n[]:\n[]()\n</code></pre>\n",
+
+		"````\ncontains a shorter ```\nfence\n````\n",
+		"<pre><code>contains a shorter ```\nfence\n</code></pre>\n",
+
+		"```\ncontains ~~~\n```\n",
+		"<pre><code>contains ~~~\n</code></pre>\n",
+
+		"   ``` go\nindented fence\n  ```\n",
+		"<pre><code class=\"language-go\">indented fence\n</code></pre>\n",
+
+		"``` go\n",
+		"<pre><code class=\"language-go\"></code></pre>\n",
+
+		"Paragraph\n\n``` go\nunclosed\n\nstill code\n",
+		"<p>Paragraph</p>\n\n<pre><code class=\"language-go\">unclosed\n\nstill code\n</code></pre>\n",
+
+		"Paragraph\n```\nunclosed right after\n",
+		"<p>Paragraph</p>\n\n<pre><code>unclosed right after\n</code></pre>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_FENCED_CODE)
+}
+
+func TestCodeLanguageBadge(t *testing.T) {
+	var tests = []string{
+		"``` go\nfunc main() {}\n```\n",
+		"<pre><span class=\"code-lang-badge\">go</span><code class=\"language-go\">func main() {}\n</code></pre>\n",
+
+		"```\nplain\n```\n",
+		"<pre><code>plain\n</code></pre>\n",
+
+		"    indented\n",
+		"<pre><code>indented\n</code></pre>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_FENCED_CODE, func(input string, extensions int) string {
+		renderer := HtmlRenderer(HTML_CODE_LANGUAGE_BADGE, "", "")
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+}
+
+func TestCodeBlockRenderer(t *testing.T) {
+	highlight := func(w io.Writer, lang string, code []byte) bool {
+		if lang != "go" {
+			fmt.Fprint(w, "partial output")
+			return false
+		}
+		fmt.Fprintf(w, "<div class=\"highlight\">%s</div>\n", bytes.ToUpper(code))
+		return true
+	}
+	var tests = []string{
+		"``` go linenos=table\nfunc main() {}\n```\n",
+		"<div class=\"highlight\">FUNC MAIN() {}\n</div>\n",
+
+		"```python\nprint(1)\n```\n",
+		"<pre><code class=\"language-python\">print(1)\n</code></pre>\n",
+
+		"    indented\n",
+		"<pre><code>indented\n</code></pre>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_FENCED_CODE,
+		runnerWithRendererParameters(HtmlRendererParameters{CodeBlockRenderer: highlight}))
+}
+
+func TestHtmlFilter(t *testing.T) {
+	// a stand-in for a real sanitizer
+	filter := func(html []byte) []byte {
+		lower := bytes.ToLower(html)
+		if bytes.HasPrefix(lower, []byte("<script")) || bytes.HasPrefix(lower, []byte("</script")) {
+			return nil
+		}
+		return bytes.Replace(html, []byte("<script>alert(1)</script>"), nil, -1)
+	}
+	var tests = []string{
+		"<div>\n<script>alert(1)</script>\n</div>\n",
+		"<div>\n\n</div>\n",
+
+		"Text <script>x</script> and <b>bold</b>\n",
+		"<p>Text x and <b>bold</b></p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, runnerWithRendererParameters(HtmlRendererParameters{HtmlFilter: filter}))
+
+	// the skip flags still drop the HTML entirely
+	tests = []string{
+		"<div>\n<script>alert(1)</script>\n</div>\n",
+		"",
+
+		"Text <b>bold</b>\n",
+		"<p>Text bold</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		renderer := HtmlRendererWithParameters(HTML_SKIP_HTML, "", "", HtmlRendererParameters{HtmlFilter: filter})
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+}
+
+func TestAdmonitions(t *testing.T) {
+	var tests = []string{
+		"!!! note\n    Some *text*.\n",
+		"<div class=\"admonition note\">\n<p class=\"admonition-title\">Note</p>\n<p>Some <em>text</em>.</p>\n</div>\n",
+
+		"!!! warning \"Mind the *gap*\"\n    Careful.\n",
+		"<div class=\"admonition warning\">\n<p class=\"admonition-title\">Mind the <em>gap</em></p>\n<p>Careful.</p>\n</div>\n",
+
+		// an empty title leaves the title out
+		"!!! tip \"\"\n    Hint.\n",
+		"<div class=\"admonition tip\">\n<p>Hint.</p>\n</div>\n",
+
+		"!!! note\n",
+		"<div class=\"admonition note\">\n<p class=\"admonition-title\">Note</p>\n</div>\n",
+
+		// the content is parsed as blocks
+		"!!! note\n    First.\n\n        code\n\n    * a\n    * b\n",
+		"<div class=\"admonition note\">\n<p class=\"admonition-title\">Note</p>\n<p>First.</p>\n\n<pre><code>code\n</code></pre>\n\n<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n</div>\n",
+
+		"!!! note\n    Outer.\n\n    !!! danger\n        Inner.\n",
+		"<div class=\"admonition note\">\n<p class=\"admonition-title\">Note</p>\n<p>Outer.</p>\n\n<div class=\"admonition danger\">\n<p class=\"admonition-title\">Danger</p>\n<p>Inner.</p>\n</div>\n</div>\n",
+
+		// the first unindented line ends the block
+		"!!! note\n    Inside.\n\nOutside.\n",
+		"<div class=\"admonition note\">\n<p class=\"admonition-title\">Note</p>\n<p>Inside.</p>\n</div>\n\n<p>Outside.</p>\n",
+
+		"!!! note\n    Inside.\nOutside.\n",
+		"<div class=\"admonition note\">\n<p class=\"admonition-title\">Note</p>\n<p>Inside.</p>\n</div>\n\n<p>Outside.</p>\n",
+
+		// not admonitions
+		"!!!note\n",
+		"<p>!!!note</p>\n",
+
+		"!!! note \"unterminated\n",
+		"<p>!!! note &quot;unterminated</p>\n",
+
+		"!!! note trailing words\n",
+		"<p>!!! note trailing words</p>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_ADMONITIONS)
+
+	tests = []string{
+		"!!! note\n    Text.\n",
+		"<p>!!! note\n    Text.</p>\n",
+	}
+	doTestsBlock(t, tests, 0)
+
+	// a titled quote for renderers without admonitions
+	tests = []string{
+		"!!! warning \"Mind the *gap*\"\n    Careful.\n",
+		"<blockquote>\n<p>Mind the <em>gap</em></p>\n\n<p>Careful.</p>\n</blockquote>\n",
+
+		"!!! tip \"\"\n    Hint.\n",
+		"<blockquote>\n<p>Hint.</p>\n</blockquote>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_ADMONITIONS, runBasicRenderer)
+
+	// the Markdown renderer writes the title out and keeps a literal !!! escaped
+	tests = []string{
+		"!!! tip \"\"\n    * a\n    * b\n",
+		"!!! tip \"\"\n\n    * a\n    * b\n",
+
+		"!!! note\n",
+		"!!! note \"Note\"\n",
+
+		"\\!!! note\n",
+		"\\!!! note\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_ADMONITIONS, func(input string, extensions int) string {
+		normalized := Markdown([]byte(input), MarkdownRenderer(extensions), extensions)
+		expected := runMarkdownBlock(input, extensions)
+		if actual := runMarkdownBlock(string(normalized), extensions); actual != expected {
+			return fmt.Sprintf("%s(renders as %q instead of %q)", normalized, actual, expected)
+		}
+		return string(normalized)
+	})
+}
+
+func TestAlerts(t *testing.T) {
+	var tests = []string{
+		"> [!NOTE]\n> Useful information.\n",
+		"<div class=\"markdown-alert markdown-alert-note\">\n<p class=\"markdown-alert-title\">Note</p>\n<p>Useful information.</p>\n</div>\n",
+
+		"> [!Warning]  \n> Careful.\n>\n> * one\n> * two\n",
+		"<div class=\"markdown-alert markdown-alert-warning\">\n<p class=\"markdown-alert-title\">Warning</p>\n" +
+			"<p>Careful.</p>\n\n<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n</div>\n",
+
+		"> [!x]\n> Not an alert.\n",
+		"<blockquote>\n<p>[!x]\nNot an alert.</p>\n</blockquote>\n",
+
+		"> [!NOTE] with text\n",
+		"<blockquote>\n<p>[!NOTE] with text</p>\n</blockquote>\n",
+
+		"> [!TIP]\n",
+		"<div class=\"markdown-alert markdown-alert-tip\">\n<p class=\"markdown-alert-title\">Tip</p>\n</div>\n",
+
+		"> Text first.\n> [!NOTE]\n",
+		"<blockquote>\n<p>Text first.\n[!NOTE]</p>\n</blockquote>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_ALERTS)
+
+	// off by default
+	tests = []string{
+		"> [!NOTE]\n> Text.\n",
+		"<blockquote>\n<p>[!NOTE]\nText.</p>\n</blockquote>\n",
+	}
+	doTestsBlock(t, tests, 0)
+
+	// and for renderers without alerts
+	doTestsBlockWithRunner(t, tests, EXTENSION_ALERTS, runBasicRenderer)
+
+	// the Markdown renderer writes the marker back
+	tests = []string{
+		"> [!tip]\n> Text.\n",
+		"> [!TIP]\n> Text.\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_ALERTS, func(input string, extensions int) string {
+		return string(Markdown([]byte(input), MarkdownRenderer(extensions), extensions))
+	})
+}
+
+func TestFencedDivs(t *testing.T) {
+	var tests = []string{
+		"::: warning\nMind the *gap*.\n:::\n",
+		"<div class=\"warning\">\n<p>Mind the <em>gap</em>.</p>\n</div>\n",
+
+		"::: {.note .wide #first}\nText\n:::\n",
+		"<div class=\"note wide\">\n<p>Text</p>\n</div>\n",
+
+		"::: empty :::\n:::\n",
+		"<div class=\"empty\">\n</div>\n",
+
+		"::::: outer\nBefore\n\n::: inner\nInside\n:::\n\nAfter\n:::::\n",
+		"<div class=\"outer\">\n<p>Before</p>\n\n" +
+			"<div class=\"inner\">\n<p>Inside</p>\n</div>\n\n" +
+			"<p>After</p>\n</div>\n",
+
+		"::: outer\nBefore\n::: inner\nInside\n:::\n:::\n",
+		"<div class=\"outer\">\n<p>Before</p>\n\n" +
+			"<div class=\"inner\">\n<p>Inside</p>\n</div>\n</div>\n",
+
+		"::: code\n```\n:::\n```\n:::\n",
+		"<div class=\"code\">\n<pre><code>:::\n</code></pre>\n</div>\n",
+
+		"::: open\nNever closed\n",
+		"<p>::: open\nNever closed</p>\n",
+
+		":::\nNo class\n:::\n",
+		"<p>:::\nNo class\n:::</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_FENCED_CODE, func(input string, extensions int) string {
+		opts := Options{Extensions: extensions, FencedDivs: true}
+		return string(MarkdownOptions([]byte(input), HtmlRenderer(HTML_USE_XHTML, "", ""), opts))
+	})
+
+	tests = []string{
+		"::::: outer\nBefore\n\n::: inner\nInside\n:::\n:::::\n",
+		"::: outer\nBefore\n\n::: inner\nInside\n:::\n:::\n",
+
+		"::: {.note .wide}\nText\n:::\n",
+		"::: {.note .wide}\nText\n:::\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		opts := Options{Extensions: extensions, FencedDivs: true}
+		return string(MarkdownOptions([]byte(input), MarkdownRenderer(extensions), opts))
+	})
+
+	// the inner blocks, unwrapped, for renderers without divs
+	tests = []string{
+		"Before\n\n::: outer\nFirst\n\n::: inner\nSecond\n:::\n:::\n\nAfter\n",
+		"<p>Before</p>\n\n<p>First</p>\n\n<p>Second</p>\n\n<p>After</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		opts := Options{Extensions: extensions, FencedDivs: true}
+		renderer := basicRenderer{HtmlRenderer(HTML_USE_XHTML, "", "")}
+		return string(MarkdownOptions([]byte(input), renderer, opts))
+	})
+}
+
+func TestFrontMatter(t *testing.T) {
+	var tests = []string{
+		"---\ntitle: Hello\ntags: [a, b]\n---\n# Body\n",
+		"[title: Hello\ntags: [a, b]\n]<h1>Body</h1>\n",
+
+		"---\r\ntitle: Hello\r\n---  \r\n\r\nText\r\n",
+		"[title: Hello\r\n]<p>Text</p>\n",
+
+		"---\n---\nText\n",
+		"[]<p>Text</p>\n",
+
+		"---\ntitle: Hello\n---",
+		"[title: Hello\n]",
+
+		// absent
+		"Text\n\n---\nmore: text\n---\n",
+		"<p>Text</p>\n\n<hr>\n\n<h2>more: text</h2>\n",
+
+		" ---\ntitle: Hello\n---\n",
+		"<hr>\n\n<h2>title: Hello</h2>\n",
+
+		// unterminated
+		"---\ntitle: Hello\n",
+		"<hr>\n\n<p>title: Hello</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		var front string
+		output := MarkdownOptions([]byte(input), HtmlRenderer(0, "", ""), Options{
+			Extensions: extensions,
+			FrontMatter: func(yaml []byte) {
+				front = "[" + string(yaml) + "]"
+			},
+		})
+		return front + string(output)
+	})
+
+	// without the option, front matter is just Markdown
+	tests = []string{
+		"---\ntitle: Hello\n---\n",
+		"<hr />\n\n<h2>title: Hello</h2>\n",
+	}
+	doTestsBlock(t, tests, 0)
+}
+
+func TestSkipInline(t *testing.T) {
+	var tests = []string{
+		"# *Title* {#t}\n\nSome *em* and [link](/x) &amp; <b>\nline  \nnext\n",
+		"<h1 id=\"t\">*Title*</h1>\n\n<p>Some *em* and [link](/x) &amp;amp; &lt;b&gt;\nline  \nnext</p>\n",
+
+		"* item `code`\n* two\n\n| a | *b* |\n|---|---|\n| c | d |\n",
+		"<ul>\n<li>item `code`</li>\n<li>two</li>\n</ul>\n\n<table>\n<thead>\n<tr>\n<th>a</th>\n<th>*b*</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>d</td>\n</tr>\n</tbody>\n</table>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES|EXTENSION_HEADER_IDS, func(input string, extensions int) string {
+		return string(MarkdownOptions([]byte(input), HtmlRenderer(0, "", ""), Options{
+			Extensions: extensions,
+			SkipInline: true,
+		}))
+	})
+
+	// the blocks are the same as when the inline markup is parsed
+	blockTags := regexp.MustCompile(`</?(h\d|p|ul|ol|li|blockquote|pre|table|tr|th|td)[ >]`)
+	input, err := ioutil.ReadFile("testdata/Markdown Documentation - Syntax.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := MarkdownOptions(input, HtmlRenderer(0, "", ""), Options{})
+	blocks := MarkdownOptions(input, HtmlRenderer(0, "", ""), Options{SkipInline: true})
+	expected := blockTags.FindAllString(string(full), -1)
+	actual := blockTags.FindAllString(string(blocks), -1)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("block structure differs:\nExpected %v\nActual   %v", expected, actual)
+	}
+}
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestMarkdownWriter(t *testing.T) {
+	input := []byte("# Title\n\nSome *text*.\n")
+	opts := Options{Extensions: EXTENSION_TABLES}
+
+	var buf bytes.Buffer
+	if err := MarkdownWriter(&buf, input, HtmlRenderer(0, "", ""), opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := string(MarkdownOptions(input, HtmlRenderer(0, "", ""), opts))
+	if buf.String() != expected {
+		t.Errorf("\nExpected[%#v]\nActual  [%#v]", expected, buf.String())
+	}
+
+	writeErr := errors.New("connection closed")
+	if err := MarkdownWriter(failingWriter{writeErr}, input, HtmlRenderer(0, "", ""), opts); err != writeErr {
+		t.Errorf("expected the write error, got %v", err)
+	}
+	if err := MarkdownWriter(failingWriter{writeErr}, input, nil, opts); err != nil {
+		t.Errorf("expected no error without a renderer, got %v", err)
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestMarkdownReader(t *testing.T) {
+	input := "# Title\r\n\r\n| a | b |\r\n|---|---|\r\n| c | d |\r\n"
+	opts := Options{Extensions: EXTENSION_TABLES}
+
+	actual, err := MarkdownReader(strings.NewReader(input), HtmlRenderer(0, "", ""), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := MarkdownOptions([]byte(input), HtmlRenderer(0, "", ""), opts)
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("\nExpected[%#v]\nActual  [%#v]", string(expected), string(actual))
+	}
+
+	readErr := errors.New("connection reset")
+	if _, err := MarkdownReader(failingReader{readErr}, HtmlRenderer(0, "", ""), opts); err != readErr {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+func TestTabExpansion(t *testing.T) {
+	var tests = []string{
+		"* a\n\t* b\n\t\t* c\n",
+		"<ul>\n<li>a\n\n<ul>\n<li>b\n\n<ul>\n<li>c</li>\n</ul></li>\n</ul></li>\n</ul>\n",
+
+		"\tcode\n\t\tnested\n",
+		"<pre><code>code\n    nested\n</code></pre>\n",
+
+		// tabs advance to the next tab stop, not by a fixed width
+		"\tab\tc\n\tabc\td\n",
+		"<pre><code>ab  c\nabc d\n</code></pre>\n",
+
+		"  \tcode\n",
+		"<pre><code>code\n</code></pre>\n",
+	}
+	doTestsBlock(t, tests, 0)
+
+	// list items indented with tabs, or spaces and tabs, nest like items
+	// indented with spaces up to the same tab stops
+	tests = []string{
+		"* item\n\n \tcontinued\n\n\t* nested\n",
+		"<ul>\n<li><p>item</p>\n\n<p>continued</p>\n\n<ul>\n<li>nested</li>\n</ul></li>\n</ul>\n",
+
+		"* item\n\n    continued\n\n    * nested\n",
+		"<ul>\n<li><p>item</p>\n\n<p>continued</p>\n\n<ul>\n<li>nested</li>\n</ul></li>\n</ul>\n",
+
+		"* item\n  \t* nested\n* next\n",
+		"<ul>\n<li>item\n\n<ul>\n<li>nested</li>\n</ul></li>\n<li>next</li>\n</ul>\n",
+
+		"1.\tone\n\n\tpara\n\n\t-\tsub\n",
+		"<ol>\n<li><p>one</p>\n\n<p>para</p>\n\n<ul>\n<li>sub</li>\n</ul></li>\n</ol>\n",
+
+		"1.  one\n\n    para\n\n    -   sub\n",
+		"<ol>\n<li><p>one</p>\n\n<p>para</p>\n\n<ul>\n<li>sub</li>\n</ul></li>\n</ol>\n",
+	}
+	doTestsBlock(t, tests, 0)
+}
+
+func TestMathBlock(t *testing.T) {
+	var tests = []string{
+		"$$\n\\sum_{i=1}^n i\n  = \\frac{n(n+1)}{2}\n$$\n",
+		"<div class=\"math display\">\\sum_{i=1}^n i\n  = \\frac{n(n+1)}{2}</div>\n",
+
+		"$$ e^{i\\pi} + 1 = 0 $$\n\nafter\n",
+		"<div class=\"math display\">e^{i\\pi} + 1 = 0</div>\n\n<p>after</p>\n",
+
+		"$$a < b$$ and more\n",
+		"<p>$$a &lt; b$$ and more</p>\n",
+
+		"$$\nunclosed\n",
+		"<p>$$\nunclosed</p>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_MATH)
+
+	// code for renderers without math
+	tests = []string{
+		"$$ e^{i\\pi} + 1 = 0 $$\n\nand $a < b$\n",
+		"<pre><code class=\"language-math\">e^{i\\pi} + 1 = 0\n</code></pre>\n\n<p>and <code>a &lt; b</code></p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_MATH, runBasicRenderer)
+}
+
+func TestLineEndings(t *testing.T) {
+	var tests = []string{
+		"# Title\r\n\r\nPara one\rline two\nline three\r\n",
+		"<h1>Title</h1>\n\n<p>Para one\nline two\nline three</p>\n",
+
+		"``` go\r\ncode\r\n\tindented\n```\r\n",
+		"<pre><code class=\"language-go\">code\n\tindented\n</code></pre>\n",
+
+		"    code\r\n    block\r\n",
+		"<pre><code>code\nblock\n</code></pre>\n",
+
+		"| a | b |\r\n|---|---|\n| 1 | 2 |\r\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"> quote\r> more\r\n\r\n* a\r\n* b\r\n\r\n---\r\n",
+		"<blockquote>\n<p>quote\nmore</p>\n</blockquote>\n\n<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n\n<hr />\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_FENCED_CODE|EXTENSION_TABLES)
+}
+
+func TestFencedCodeBlockAttributes(t *testing.T) {
+	var tests = []string{
+		"``` python\nbare language\n```\n",
+		"<pre><code class=\"language-python\">bare language\n</code></pre>\n",
+
+		"``` python {.numberLines .wide}\nclasses\n```\n",
+		"<pre><code class=\"language-python numberLines wide\">classes\n</code></pre>\n",
+
+		"``` python {.numberLines startFrom=\"5\" hl_lines=1-3}\nattributes\n```\n",
+		"<pre><code class=\"language-python numberLines\" data-hl_lines=\"1-3\" data-startFrom=\"5\">attributes\n</code></pre>\n",
+
+		"``` python {title='a \"quoted\" <name>'}\nescaped values\n```\n",
+		"<pre><code class=\"language-python\" data-title=\"a &quot;quoted&quot; &lt;name&gt;\">escaped values\n</code></pre>\n",
+
+		"~~~ python {}\nno attributes\n~~~\n",
+		"<pre><code class=\"language-python\">no attributes\n</code></pre>\n",
+
+		"``` python {startFrom=\"5}\nunterminated quote\n```\n",
+		"<pre><code class=\"language-python\">unterminated quote\n</code></pre>\n",
+
+		"``` python {bad\" key=1}\nbad key\n```\n",
+		"<pre><code class=\"language-python\">bad key\n</code></pre>\n",
+
+		"``` python .numberLines\nno braces\n```\n",
+		"<pre><code class=\"language-python\">no braces\n</code></pre>\n",
 	}
 	doTestsBlock(t, tests, EXTENSION_FENCED_CODE)
 }
@@ -1186,8 +2407,9 @@ func TestFencedCodeInsideBlockquotes(t *testing.T) {
 <p>foo</p>
 
 <p>quote
-continues
-` + "```" + `</p>
+continues</p>
+
+<pre><code></code></pre>
 </blockquote>
 `,
 		// -------------------------------------------
@@ -1289,6 +2511,31 @@ func TestTable(t *testing.T) {
 			"<tbody>\n<tr>\n<td align=\"left\">e</td>\n<td align=\"right\">f</td>\n" +
 			"<td align=\"center\">g</td>\n<td>h</td>\n</tr>\n</tbody>\n</table>\n",
 
+		"| a |\n|:-:|\n| b |\n",
+		"<table>\n<thead>\n<tr>\n<th align=\"center\">a</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td align=\"center\">b</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"| a |\n|:--|\n| b |\n",
+		"<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td align=\"left\">b</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"| a |\n|--:|\n| b |\n",
+		"<table>\n<thead>\n<tr>\n<th align=\"right\">a</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td align=\"right\">b</td>\n</tr>\n</tbody>\n</table>\n",
+
+		// fewer than three dashes and colons is not a separator
+		"| a |\n|:-|\n| b |\n",
+		"<p>| a |\n|:-|\n| b |</p>\n",
+
+		"a|b|c|d\n::--::|:--|--:|:-:\ne|f|g|h\n",
+		"<table>\n<thead>\n<tr>\n<th style=\"text-align: justify\">a</th>\n<th align=\"left\">b</th>\n" +
+			"<th align=\"right\">c</th>\n<th align=\"center\">d</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td style=\"text-align: justify\">e</td>\n<td align=\"left\">f</td>\n" +
+			"<td align=\"right\">g</td>\n<td align=\"center\">h</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a|b\n::--|---\nc|d\n",
+		"<p>a|b\n::--|---\nc|d</p>\n",
+
 		"a|b|c\n---|---|---\n",
 		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n<th>c</th>\n</tr>\n</thead>\n\n<tbody>\n</tbody>\n</table>\n",
 
@@ -1298,10 +2545,432 @@ func TestTable(t *testing.T) {
 
 		"a|b\\|c|d\n---|---|---\nf|g\\|h|i\n",
 		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b|c</th>\n<th>d</th>\n</tr>\n</thead>\n\n<tbody>\n<tr>\n<td>f</td>\n<td>g|h</td>\n<td>i</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"| a |\n|---|\n| a \\| b |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n\n<tbody>\n<tr>\n<td>a | b</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"| a |\n|---|\n| `x \\| y` |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n\n<tbody>\n<tr>\n<td><code>x | y</code></td>\n</tr>\n</tbody>\n</table>\n",
+
+		"| a |\n|---|\n| x \\\\\\| y \\* z |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n\n<tbody>\n<tr>\n<td>x \\| y * z</td>\n</tr>\n</tbody>\n</table>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_TABLES)
+}
+
+func TestTableOverflow(t *testing.T) {
+	runner := func(overflow int) func(string, int) string {
+		return func(input string, extensions int) string {
+			renderer := HtmlRenderer(0, "", "")
+			opts := Options{Extensions: extensions, TableOverflow: overflow}
+			return string(MarkdownOptions([]byte(input), renderer, opts))
+		}
+	}
+
+	// by default extra cells are dropped
+	var tests = []string{
+		"a | b\n---|--:\nc | d | e\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td align=\"right\">d</td>\n</tr>\n</tbody>\n</table>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES, runner(TABLE_OVERFLOW_DROP))
+
+	tests = []string{
+		"a | b\n---|--:\nc | d | e\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td align=\"right\">d | e</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a | b\n---|--:\n| c | d | e | f |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td align=\"right\">d | e | f</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a | b\n---|--:\nc | d \\|\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td align=\"right\">d |</td>\n</tr>\n</tbody>\n</table>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES, runner(TABLE_OVERFLOW_MERGE))
+
+	tests = []string{
+		"a | b\n---|--:\nc | d | e\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td align=\"right\">d</td>\n<td align=\"right\">e</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a | b\n---|--:\n| c | d | e | f |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td align=\"right\">d</td>\n<td align=\"right\">e</td>\n" +
+			"<td align=\"right\">f</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a | b\n---|--:\n| c | d |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td align=\"right\">d</td>\n</tr>\n</tbody>\n</table>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES, runner(TABLE_OVERFLOW_EXTEND))
+}
+
+func TestTableLineContinuation(t *testing.T) {
+	runner := func(input string, extensions int) string {
+		renderer := HtmlRenderer(0, "", "")
+		opts := Options{Extensions: extensions, TableLineContinuation: true}
+		return string(MarkdownOptions([]byte(input), renderer, opts))
+	}
+	var tests = []string{
+		"a | b\n---|---\nc | long \\\n    text\ne | f\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>long text</td>\n</tr>\n\n" +
+			"<tr>\n<td>e</td>\n<td>f</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a | b\n---|---\nc | d \\\n\\| e\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>d | e</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a | b\n---|---\nc | d\\\\\nnot in the table\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>d\\</td>\n</tr>\n</tbody>\n</table>\n\n" +
+			"<p>not in the table</p>\n",
+
+		"a | b\n---|---\nc | d \\\n\nafter\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>d </td>\n</tr>\n</tbody>\n</table>\n\n" +
+			"<p>after</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES, runner)
+}
+
+func TestTableCaptions(t *testing.T) {
+	var tests = []string{
+		"[Ages of *people*]\nName | Age\n-----|----\nBob  | 27\n",
+		"<table>\n<caption>Ages of <em>people</em></caption>\n<thead>\n<tr>\n<th>Name</th>\n<th>Age</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>Bob</td>\n<td>27</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"Name | Age\n-----|----\nBob  | 27\n[Ages]\n",
+		"<table>\n<caption>Ages</caption>\n<thead>\n<tr>\n<th>Name</th>\n<th>Age</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>Bob</td>\n<td>27</td>\n</tr>\n</tbody>\n</table>\n",
+
+		// a blank line separates the caption from the table
+		"Name | Age\n-----|----\nBob  | 27\n\n[Ages]\n",
+		"<table>\n<thead>\n<tr>\n<th>Name</th>\n<th>Age</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>Bob</td>\n<td>27</td>\n</tr>\n</tbody>\n</table>\n\n<p>[Ages]</p>\n",
+
+		// only the first caption is taken
+		"[Above]\nName | Age\n-----|----\n[Below]\n",
+		"<table>\n<caption>Above</caption>\n<thead>\n<tr>\n<th>Name</th>\n<th>Age</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n</tbody>\n</table>\n\n<p>[Below]</p>\n",
+
+		// links and bracketed text are not captions
+		"Name | Age\n-----|----\n[a](/b) [c]\n",
+		"<table>\n<thead>\n<tr>\n<th>Name</th>\n<th>Age</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n</tbody>\n</table>\n\n<p><a href=\"/b\">a</a> [c]</p>\n",
+
+		"[Not a table]\nJust text\n",
+		"<p>[Not a table]\nJust text</p>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_TABLES|EXTENSION_TABLE_CAPTIONS)
+
+	// captions are plain text without the extension
+	tests = []string{
+		"Name | Age\n-----|----\nBob  | 27\n[Ages]\n",
+		"<table>\n<thead>\n<tr>\n<th>Name</th>\n<th>Age</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>Bob</td>\n<td>27</td>\n</tr>\n</tbody>\n</table>\n\n<p>[Ages]</p>\n",
 	}
 	doTestsBlock(t, tests, EXTENSION_TABLES)
 }
 
+func TestTableEmptyCells(t *testing.T) {
+	var tests = []string{
+		"a |  | c\n---|---|---\n | e |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>&nbsp;</th>\n<th>c</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>&nbsp;</td>\n<td>e</td>\n<td>&nbsp;</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"a | b\n---|---\nc |\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>&nbsp;</td>\n</tr>\n</tbody>\n</table>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES, func(input string, extensions int) string {
+		renderer := HtmlRenderer(HTML_USE_XHTML|HTML_NBSP_EMPTY_TABLE_CELLS, "", "")
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+}
+
+func TestGridTable(t *testing.T) {
+	var tests = []string{
+		"+---+---+\n| a | b |\n+===+===+\n| c | d |\n+---+---+\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>d</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"+-----+-----+\n| *a* | b   |\n+-----+-----+\n| c   | d   |\n+-----+-----+\n",
+		"<table>\n<tbody>\n<tr>\n<td><em>a</em></td>\n<td>b</td>\n</tr>\n\n" +
+			"<tr>\n<td>c</td>\n<td>d</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"+--------+-----------+\n" +
+			"| Name   | Notes     |\n" +
+			"+:=======+==========:+\n" +
+			"| Bob    | first     |\n" +
+			"|        | line, and |\n" +
+			"|        | second    |\n" +
+			"+--------+-----------+\n" +
+			"| Alice  |           |\n" +
+			"+--------+-----------+\n",
+		"<table>\n<thead>\n<tr>\n<th align=\"left\">Name</th>\n<th align=\"right\">Notes</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td align=\"left\">Bob</td>\n<td align=\"right\">first\nline, and\nsecond</td>\n</tr>\n\n" +
+			"<tr>\n<td align=\"left\">Alice</td>\n<td align=\"right\"></td>\n</tr>\n</tbody>\n</table>\n",
+
+		"+---+---+---+\n| a | b | c |\n+===+===+===+\n| span  | d |\n+---+---+---+\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n<th>c</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td colspan=\"2\">span</td>\n<td>d</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"+---+---+\n| a | b |\n+---+---+\n\nAfter the table.\n",
+		"<table>\n<tbody>\n<tr>\n<td>a</td>\n<td>b</td>\n</tr>\n</tbody>\n</table>\n\n<p>After the table.</p>\n",
+
+		"+-----+\n| häj |\n+-----+\n",
+		"<table>\n<tbody>\n<tr>\n<td>häj</td>\n</tr>\n</tbody>\n</table>\n",
+
+		// not grid tables
+		"+---+---+\n| a | b |\n",
+		"<p>+---+---+\n| a | b |</p>\n",
+
+		"+---+---+\n| a | b  |\n+---+---+\n",
+		"<p>+---+---+\n| a | b  |\n+---+---+</p>\n",
+
+		"+---++\n| a ||\n+---++\n",
+		"<p>+---++\n| a ||\n+---++</p>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_GRID_TABLES)
+
+	// pipe tables take precedence
+	tests = []string{
+		"a | b\n---|---\nc | d\n",
+		"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n\n" +
+			"<tbody>\n<tr>\n<td>c</td>\n<td>d</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"+---+---+\n| a | b |\n+---+---+\n",
+		"<table>\n<tbody>\n<tr>\n<td>a</td>\n<td>b</td>\n</tr>\n</tbody>\n</table>\n",
+	}
+	doTestsBlock(t, tests, EXTENSION_TABLES|EXTENSION_GRID_TABLES)
+}
+
+func TestTableToCSV(t *testing.T) {
+	input := "Intro text.\n\n" +
+		"| Name | *Role* | Link |\n" +
+		"|------|--------|------|\n" +
+		"| `a` | **lead** &amp; dev | [site](http://example.com) |\n" +
+		"| b |\n" +
+		"\n" +
+		"a | second\n" +
+		"--|--\n" +
+		"x | y\n"
+	expected := [][]string{
+		{"Name", "Role", "Link"},
+		{"a", "lead & dev", "site"},
+		{"b", "", ""},
+	}
+
+	actual, err := TableToCSV([]byte(input), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("\nExpected%#v\nActual  %#v", expected, actual)
+	}
+
+	if _, err := TableToCSV([]byte("no tables here\n"), 0); err != ErrNoTable {
+		t.Errorf("expected ErrNoTable, got %v", err)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	var tests = []string{
+		"# Title\n\nSome *emphasis* and a [link](http://example.com).\n",
+		"Title\n\nSome emphasis and a link.\n",
+
+		"![alt text](/img.png) &amp; <b>raw</b>\n",
+		"alt text & raw\n",
+
+		"* one\n* two\n\n---\n\nafter\n",
+		"- one\n- two\n\nafter\n",
+
+		"3. three\n4. four\n",
+		"3. three\n4. four\n",
+
+		"1. outer\n   * inner\n2. next\n",
+		"1. outer\n   - inner\n2. next\n",
+
+		"* first line\n  second line\n",
+		"- first line\n  second line\n",
+
+		"> quoted\n",
+		"quoted\n",
+
+		"```go\nfunc main() {\n\tx := 1\n}\n```\n",
+		"func main() {\n\tx := 1\n}\n",
+
+		"| a | b |\n|---|---|\n| c | d |\n",
+		"a\tb\nc\td\n",
+
+		"<div>\nhidden\n</div>\n\ntext\n",
+		"text\n",
+
+		"One[^note] and two^[inline].\n\n[^note]: The note.\n",
+		"One[1] and two[2].\n\n[1] The note.\n[2] inline\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES|EXTENSION_FENCED_CODE|EXTENSION_FOOTNOTES, func(input string, extensions int) string {
+		return string(ToPlainText([]byte(input), Options{Extensions: extensions}))
+	})
+}
+
+func TestAnsi(t *testing.T) {
+	var tests = []string{
+		"# Title\n\nSome *emphasis* and a [link](http://example.com) in a paragraph that\nis reflowed to the width.\n",
+		"Title\n=====\n\nSome emphasis and a link\n(http://example.com) in a paragraph that\nis reflowed to the width.\n",
+
+		"Sub\n---\n\n### Third\n",
+		"Sub\n---\n\nThird\n",
+
+		"> A quote that is long enough to be wrapped inside its bar.\n>\n> Second.\n",
+		"│ A quote that is long enough to be\n│ wrapped inside its bar.\n│\n│ Second.\n",
+
+		"1. an item that is long enough to wrap around\n2. next\n    * nested item\n",
+		"1. an item that is long enough to wrap\n   around\n2. next\n   • nested item\n",
+
+		"Hard  \nbreak and a line that needs to be wrapped.\n",
+		"Hard\nbreak and a line that needs to be\nwrapped.\n",
+
+		"    code is never wrapped, however long its lines are\n",
+		"    code is never wrapped, however long its lines are\n",
+
+		"| Name | Qty |\n|:-----|----:|\n| apple | 3 |\n| kiwi | 12 |\n",
+		"Name   Qty\n─────  ───\napple    3\nkiwi    12\n",
+
+		"&copy; <b>raw</b> `code`\n",
+		"© raw code\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES, func(input string, extensions int) string {
+		return runMarkdownBlockWithRenderer(input, extensions, AnsiRenderer(ANSI_NO_COLOR, 40))
+	})
+
+	// without a width the lines are kept
+	tests = []string{
+		"A paragraph\nwith its lines kept.\n",
+		"A paragraph\nwith its lines kept.\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		return runMarkdownBlockWithRenderer(input, extensions, AnsiRenderer(ANSI_NO_COLOR, 0))
+	})
+
+	tests = []string{
+		"# Title\n\n**bold** *em* `code` [x](/y)\n\n> q\n",
+		"\x1b[1m\x1b[4mTitle\x1b[24m\x1b[22m\n\n" +
+			"\x1b[1mbold\x1b[22m \x1b[3mem\x1b[23m \x1b[36mcode\x1b[39m \x1b[4;34mx\x1b[24;39m (\x1b[90m/y\x1b[39m)\n\n" +
+			"\x1b[90m│\x1b[39m q\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		return string(ToAnsi([]byte(input), 80, Options{Extensions: extensions}))
+	})
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	var tests = []string{
+		"Title\n=====\n\nSub\n---\n",
+		"# Title\n\n## Sub\n",
+
+		"- one\n+ two\n\n3) three\n",
+		"* one\n* two\n\n3) three\n",
+
+		"1. one\n2) two\n\n4\\) not a list\n",
+		"1. one\n\n2) two\n\n4\\) not a list\n",
+
+		"3. three\n1. four\n   - nested\n",
+		"3. three\n4. four\n    * nested\n",
+
+		"* loose\n\n    second paragraph\n\n* next\n",
+		"* loose\n\n    second paragraph\n\n* next\n",
+
+		"    indented code\n\n```go\nfenced\n```\n",
+		"```\nindented code\n```\n\n```go\nfenced\n```\n",
+
+		"~~~\nhas ``` inside\n~~~\n",
+		"````\nhas ``` inside\n````\n",
+
+		"Some *emphasis*, __strong__ and `code`.\n",
+		"Some *emphasis*, **strong** and `code`.\n",
+
+		"[ref][1] and ![img](/i.png \"title\")\n\n[1]: http://example.com/a(b) \"Title\"\n",
+		"[ref](<http://example.com/a(b)> \"Title\") and ![img](/i.png \"title\")\n",
+
+		"\\*not em\\* \\_x\\_ \\[y\\] \\<z\\> \\`w\\` a\\\\b &amp; \\&amp;\n",
+		"\\*not em\\* \\_x\\_ \\[y\\] \\<z> \\`w\\` a\\\\b &amp; \\&amp;\n",
+
+		"\\# not a header\n\n\\- not a list\n\n1\\. not a list\n",
+		"\\# not a header\n\n\\- not a list\n\n1\\. not a list\n",
+
+		"> quote\n>\n> > nested\n",
+		"> quote\n>\n> > nested\n",
+
+		"| a | b | c |\n|:--|--:|:-:|\n| `x\\|y` | *z* | |\n",
+		"| a | b | c |\n| :--- | ---: | :---: |\n| `x\\|y` | *z* |  |\n",
+
+		"line  \nbreak\n\n***\n",
+		"line  \nbreak\n\n---\n",
+
+		"<div>\nhtml\n</div>\n",
+		"<div>\nhtml\n</div>\n",
+
+		"Term 1\nTerm 2\n:   Def a\n:   Def b\n\nTerm 3\n:   Def c\n",
+		"Term 1\nTerm 2\n: Def a\n: Def b\n\nTerm 3\n: Def c\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_TABLES|EXTENSION_FENCED_CODE|EXTENSION_DEFINITION_LISTS, func(input string, extensions int) string {
+		normalized := Markdown([]byte(input), MarkdownRenderer(extensions), extensions)
+
+		// the normalized Markdown must render the same as the input
+		expected := runMarkdownBlock(input, extensions)
+		if actual := runMarkdownBlock(string(normalized), extensions); actual != expected {
+			return fmt.Sprintf("%s(renders as %q instead of %q)", normalized, actual, expected)
+		}
+		return string(normalized)
+	})
+
+	// whitespace is collapsed
+	input := "Runs  of\t spaces\n   and tabs\n"
+	expected := "Runs of spaces\nand tabs\n"
+	if actual := string(Markdown([]byte(input), MarkdownRenderer(0), 0)); actual != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", input, expected, actual)
+	}
+}
+
+func TestMarkdownReferenceLinks(t *testing.T) {
+	var tests = []string{
+		"[a](/x) and [b](/y \"Why\") then [c](/x)\n",
+		"[a][1] and [b][2] then [c][1]\n\n[1]: /x\n[2]: /y \"Why\"\n",
+
+		"![img](/i.png) [same](/x \"T\") [other title](/x)\n\n[ref][r]\n\n[r]: /i.png\n",
+		"![img][1] [same][2] [other title][3]\n\n[ref][1]\n\n[1]: /i.png\n[2]: /x \"T\"\n[3]: /x\n",
+
+		"* [in a list](http://example.com/a(b))\n\n> [quoted](<has space>)\n",
+		"* [in a list][1]\n\n> [quoted](<has space>)\n\n[1]: http://example.com/a(b)\n",
+
+		"No links <http://auto.link>\n",
+		"No links <http://auto.link>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		referenced := Markdown([]byte(input), MarkdownRendererWithFlags(extensions, MD_REFERENCE_LINKS), extensions)
+
+		// the referenced Markdown must render the same links as the input
+		expected := runMarkdownBlock(input, extensions)
+		if actual := runMarkdownBlock(string(referenced), extensions); actual != expected {
+			return fmt.Sprintf("%s(renders as %q instead of %q)", referenced, actual, expected)
+		}
+		return string(referenced)
+	})
+
+	// the references start over with every document
+	renderer := MarkdownRendererWithFlags(0, MD_REFERENCE_LINKS)
+	Markdown([]byte("[a](/a)\n"), renderer, 0)
+	input := "[b](/b)\n"
+	expected := "[b][1]\n\n[1]: /b\n"
+	if actual := string(Markdown([]byte(input), renderer, 0)); actual != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", input, expected, actual)
+	}
+}
+
 func TestUnorderedListWith_EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK(t *testing.T) {
 	var tests = []string{
 		"* Hello\n",
@@ -1536,7 +3205,7 @@ func TestFencedCodeBlock_EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK(t *testing.T) {
 		"<pre><code class=\"language-ocaml\">with extra whitespace\n</code></pre>\n",
 
 		"~ ~~ java\nWith whitespace\n~~~\n",
-		"<p>~ ~~ java\nWith whitespace\n~~~</p>\n",
+		"<p>~ ~~ java\nWith whitespace</p>\n\n<pre><code></code></pre>\n",
 
 		"~~\nonly two\n~~\n",
 		"<p>~~\nonly two\n~~</p>\n",
@@ -1545,25 +3214,25 @@ func TestFencedCodeBlock_EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK(t *testing.T) {
 		"<pre><code class=\"language-python\">extra\n</code></pre>\n",
 
 		"~~~ perl\nthree to start, four to end\n~~~~\n",
-		"<p>~~~ perl\nthree to start, four to end\n~~~~</p>\n",
+		"<pre><code class=\"language-perl\">three to start, four to end\n</code></pre>\n",
 
 		"~~~~ perl\nfour to start, three to end\n~~~\n",
-		"<p>~~~~ perl\nfour to start, three to end\n~~~</p>\n",
+		"<pre><code class=\"language-perl\">four to start, three to end\n~~~\n</code></pre>\n",
 
 		"~~~ bash\ntildes\n~~~\n",
 		"<pre><code class=\"language-bash\">tildes\n</code></pre>\n",
 
 		"``` lisp\nno ending\n",
-		"<p>``` lisp\nno ending</p>\n",
+		"<pre><code class=\"language-lisp\">no ending\n</code></pre>\n",
 
 		"~~~ lisp\nend with language\n~~~ lisp\n",
-		"<p>~~~ lisp\nend with language\n~~~ lisp</p>\n",
+		"<pre><code class=\"language-lisp\">end with language\n~~~ lisp\n</code></pre>\n",
 
 		"```\nmismatched begin and end\n~~~\n",
-		"<p>```\nmismatched begin and end\n~~~</p>\n",
+		"<pre><code>mismatched begin and end\n~~~\n</code></pre>\n",
 
 		"~~~\nmismatched begin and end\n```\n",
-		"<p>~~~\nmismatched begin and end\n```</p>\n",
+		"<pre><code>mismatched begin and end\n```\n</code></pre>\n",
 
 		"   ``` oz\nleading spaces\n```\n",
 		"<pre><code class=\"language-oz\">leading spaces\n</code></pre>\n",
@@ -1649,6 +3318,39 @@ func TestCDATA(t *testing.T) {
 	}, EXTENSION_FENCED_CODE)
 }
 
+func TestIsHRule(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"", false},
+		{" ", false},
+		{"  ", false},
+		{"   ", false},
+		{"\n", false},
+		{"-", false},
+		{"--", false},
+		{"---", true},
+		{"   ***", true},
+		{"_ _ _\n", true},
+		{"    ---\n", false},
+		{"--- x\n", false},
+	}
+	p := new(parser)
+	for _, test := range tests {
+		if got := p.isHRule([]byte(test.data)); got != test.want {
+			t.Errorf("isHRule(%q) = %v, want %v", test.data, got, test.want)
+		}
+	}
+
+	// no prefix of a line may read past its end
+	for _, line := range []string{"   ---\n", " * * *\n", "   \n"} {
+		for end := 0; end <= len(line); end++ {
+			p.isHRule([]byte(line[:end]))
+		}
+	}
+}
+
 func TestIsFenceLine(t *testing.T) {
 	tests := []struct {
 		data            []byte
@@ -1761,6 +3463,33 @@ func TestJoinLines(t *testing.T) {
 	}
 }
 
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Footnote One", "Footnote-One"},
+		{"a  --  b", "a-b"},
+		{"-a-", "a"},
+		{"!!!", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := string(slugify([]byte(test.text))); got != test.want {
+			t.Errorf("slugify(%q):\ngot %q\nwant %q", test.text, got, test.want)
+		}
+	}
+
+	// appendSlug keeps what is already in the buffer
+	buf := appendSlug([]byte("fn:"), []byte("--x y--"))
+	if string(buf) != "fn:x-y" {
+		t.Errorf("appendSlug: got %q, want %q", buf, "fn:x-y")
+	}
+	if buf = appendSlug(buf[:0], []byte("!!!")); len(buf) != 0 {
+		t.Errorf("appendSlug: got %q, want an empty slug", buf)
+	}
+}
+
 func TestSanitizedAnchorName(t *testing.T) {
 	tests := []struct {
 		text string
@@ -1801,3 +3530,219 @@ func TestSanitizedAnchorName(t *testing.T) {
 		}
 	}
 }
+
+func TestMetaTags(t *testing.T) {
+	var tests = []string{
+		"# The *Title* & More\n\nFirst \"paragraph\",\nacross <b>two</b> lines.\n\nSecond paragraph.\n",
+		"<!DOCTYPE html>\n<html>\n<head>\n  <title>Page</title>\n" +
+			"  <meta name=\"GENERATOR\" content=\"Blackfriday Markdown Processor v" + VERSION + "\">\n" +
+			"  <meta charset=\"utf-8\">\n" +
+			"  <meta property=\"og:title\" content=\"The Title &amp; More\">\n" +
+			"  <meta name=\"description\" content=\"First &quot;paragraph&quot;, across two lines.\">\n" +
+			"  <meta property=\"og:description\" content=\"First &quot;paragraph&quot;, across two lines.\">\n" +
+			"</head>\n<body>\n\n" +
+			"<h1>The <em>Title</em> &amp; More</h1>\n\n" +
+			"<p>First &quot;paragraph&quot;,\nacross <b>two</b> lines.</p>\n\n" +
+			"<p>Second paragraph.</p>\n\n" +
+			"</body>\n</html>\n",
+
+		"No header here.\n",
+		"<!DOCTYPE html>\n<html>\n<head>\n  <title>Page</title>\n" +
+			"  <meta name=\"GENERATOR\" content=\"Blackfriday Markdown Processor v" + VERSION + "\">\n" +
+			"  <meta charset=\"utf-8\">\n" +
+			"  <meta name=\"description\" content=\"No header here.\">\n" +
+			"  <meta property=\"og:description\" content=\"No header here.\">\n" +
+			"</head>\n<body>\n\n" +
+			"<p>No header here.</p>\n\n" +
+			"</body>\n</html>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		renderer := HtmlRenderer(HTML_COMPLETE_PAGE|HTML_META_TAGS, "Page", "")
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+
+	// a reused renderer takes the tags from each document anew
+	renderer := HtmlRenderer(HTML_COMPLETE_PAGE|HTML_META_TAGS, "Page", "")
+	Markdown([]byte("# Secret\n\nPrivate text.\n"), renderer, 0)
+	output := string(Markdown([]byte("# Public\n\nOpen text.\n"), renderer, 0))
+	if strings.Contains(output, "Secret") || strings.Contains(output, "Private") {
+		t.Errorf("first document leaked into [%s]", output)
+	}
+	if !strings.Contains(output, `<meta property="og:title" content="Public">`) ||
+		!strings.Contains(output, `<meta name="description" content="Open text.">`) {
+		t.Errorf("meta tags of the second document missing in [%s]", output)
+	}
+}
+
+func TestCompletePage(t *testing.T) {
+	var tests = []string{
+		"Intro.\n\n## Sub\n\n# The *Title* & More\n\n# Second\n",
+		"<!DOCTYPE html>\n<html>\n<head>\n  <title>The Title &amp; More</title>\n" +
+			"  <meta name=\"GENERATOR\" content=\"Blackfriday Markdown Processor v" + VERSION + "\">\n" +
+			"  <meta charset=\"utf-8\">\n" +
+			"  <link rel=\"stylesheet\" type=\"text/css\" href=\"style.css\">\n" +
+			"  <script src=\"app.js\"></script>\n" +
+			"</head>\n<body>\n\n" +
+			"<p>Intro.</p>\n\n" +
+			"<h2>Sub</h2>\n\n" +
+			"<h1>The <em>Title</em> &amp; More</h1>\n\n" +
+			"<h1>Second</h1>\n\n" +
+			"</body>\n</html>\n",
+
+		"No header.\n",
+		"<!DOCTYPE html>\n<html>\n<head>\n  <title></title>\n" +
+			"  <meta name=\"GENERATOR\" content=\"Blackfriday Markdown Processor v" + VERSION + "\">\n" +
+			"  <meta charset=\"utf-8\">\n" +
+			"  <link rel=\"stylesheet\" type=\"text/css\" href=\"style.css\">\n" +
+			"  <script src=\"app.js\"></script>\n" +
+			"</head>\n<body>\n\n" +
+			"<p>No header.</p>\n\n" +
+			"</body>\n</html>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		renderer := HtmlRendererWithParameters(HTML_COMPLETE_PAGE, "", "style.css",
+			HtmlRendererParameters{HeadContent: "  <script src=\"app.js\"></script>"})
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+
+	// a given title wins over the header, and the page is well-formed
+	renderer := HtmlRendererWithParameters(HTML_COMPLETE_PAGE|HTML_USE_XHTML|HTML_META_TAGS|HTML_TOC, "Given", "",
+		HtmlRendererParameters{HeadContent: "<meta name=\"robots\" content=\"noindex\" />\n"})
+	output := string(Markdown([]byte("# Header\n\nText & more.\n"), renderer, 0))
+	if !strings.HasPrefix(output, "<!DOCTYPE html PUBLIC") || !strings.HasSuffix(output, "</body>\n</html>\n") {
+		t.Errorf("page boundaries missing in [%s]", output)
+	}
+	if !strings.Contains(output, "<title>Given</title>") {
+		t.Errorf("given title not used in [%s]", output)
+	}
+	decoder := xml.NewDecoder(strings.NewReader(output[strings.Index(output, "<html"):]))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v in [%s]", err, output)
+		}
+	}
+}
+
+func TestWrapper(t *testing.T) {
+	var tests = []string{
+		"# Title\n\nSome text.\n",
+		"<div class=\"markdown-body\">\n\n<h1>Title</h1>\n\n<p>Some text.</p>\n</div>\n",
+
+		"",
+		"<div class=\"markdown-body\">\n</div>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0,
+		runnerWithRendererParameters(HtmlRendererParameters{WrapperTag: "div", WrapperClass: "markdown-body"}))
+
+	tests = []string{
+		"Some text.\n",
+		"<!DOCTYPE html>\n<html>\n<head>\n  <title></title>\n" +
+			"  <meta name=\"GENERATOR\" content=\"Blackfriday Markdown Processor v" + VERSION + "\">\n" +
+			"  <meta charset=\"utf-8\">\n" +
+			"</head>\n<body>\n" +
+			"<article>\n\n" +
+			"<p>Some text.</p>\n" +
+			"</article>\n\n" +
+			"</body>\n</html>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		renderer := HtmlRendererWithParameters(HTML_COMPLETE_PAGE, "", "",
+			HtmlRendererParameters{WrapperTag: "article"})
+		return runMarkdownBlockWithRenderer(input, extensions, renderer)
+	})
+}
+
+func TestMaxOutputBytes(t *testing.T) {
+	var tests = []string{
+		"First paragraph.\n\n* a list with *emphasis that goes on* and on\n* second item\n\nLast paragraph.\n",
+		"<p>First paragraph.</p>\n\n<ul>\n<li>a list with <em>&hellip;</em></li></ul>\n",
+
+		"Short.\n",
+		"<p>Short.</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0,
+		runnerWithRendererParameters(HtmlRendererParameters{MaxOutputBytes: 50}))
+
+	// entities are not cut in half
+	tests = []string{
+		"Entities &amp; more&hellip; text\n",
+		"<p>Entities &amp; more[...]</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0,
+		runnerWithRendererParameters(HtmlRendererParameters{MaxOutputBytes: 26, TruncationMarker: "[...]"}))
+
+	// the result is well-formed
+	var long bytes.Buffer
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&long, "> Quote %d with **strong _and emphasized_ text**\n>\n> * and a [link](/%d)\n\n", i, i)
+	}
+	for max := 1; max < 2000; max += 7 {
+		renderer := HtmlRendererWithParameters(HTML_USE_XHTML, "", "", HtmlRendererParameters{MaxOutputBytes: max})
+		output := Markdown(long.Bytes(), renderer, 0)
+		decoder := xml.NewDecoder(io.MultiReader(strings.NewReader("<root>"), bytes.NewReader(output), strings.NewReader("</root>")))
+		decoder.Entity = xml.HTMLEntity
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("MaxOutputBytes %d: %v in [%s]", max, err, output)
+			}
+		}
+	}
+}
+
+func TestMaxOutputBytesStopsParsing(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		input.WriteString("# Header x\n\nParagraph x with *emphasis*.\n\n* item\n\n")
+	}
+	calls := 0
+	opts := Options{InlineParsers: map[byte]InlineParser{
+		'x': func(out *bytes.Buffer, r Renderer, data []byte, offset int) int {
+			calls++
+			return 0
+		},
+	}}
+
+	renderer := HtmlRendererWithParameters(0, "", "", HtmlRendererParameters{MaxOutputBytes: 200})
+	output := MarkdownOptions(input.Bytes(), renderer, opts)
+	if !bytes.Contains(output, []byte("&hellip;")) {
+		t.Errorf("no truncation marker in [%s]", output)
+	}
+	if calls > 20 {
+		t.Errorf("parsed %d blocks past the limit, want parsing to stop", calls)
+	}
+	if !renderer.(*Html).Truncated() {
+		t.Error("Truncated() = false after cutting the document off")
+	}
+
+	// the same renderer on a short document
+	calls = 0
+	MarkdownOptions([]byte("Short x.\n"), renderer, opts)
+	if calls != 1 || renderer.(*Html).Truncated() {
+		t.Errorf("short document: %d calls, Truncated() = %v", calls, renderer.(*Html).Truncated())
+	}
+}
+
+func BenchmarkNestedLists(b *testing.B) {
+	var input bytes.Buffer
+	for i := 0; i < 200; i++ {
+		for depth := 0; depth < 8; depth++ {
+			input.WriteString(strings.Repeat("    ", depth))
+			input.WriteString("* item\n\n")
+			input.WriteString(strings.Repeat("    ", depth+1))
+			input.WriteString("> quoted\n\n")
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Markdown(input.Bytes(), HtmlRenderer(0, "", ""), 0)
+	}
+}
@@ -0,0 +1,150 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// Table extraction
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+	"errors"
+	"html"
+)
+
+// ErrNoTable is returned by TableToCSV when the input contains no table.
+var ErrNoTable = errors.New("blackfriday: no table found")
+
+// TableToCSV parses input with the given extensions and returns the first
+// table in the document as rows of cell text, header row first. Inline
+// markup inside the cells is flattened to its text. Short rows are padded
+// with empty cells, so every row has the same length.
+//
+// The result is suitable for encoding/csv:
+//
+//	rows, err := blackfriday.TableToCSV(input, blackfriday.EXTENSION_TABLES)
+//	if err == nil {
+//	    csv.NewWriter(os.Stdout).WriteAll(rows)
+//	}
+//
+// EXTENSION_TABLES is always enabled.
+func TableToCSV(input []byte, extensions int) ([][]string, error) {
+	r := &tableRenderer{}
+	MarkdownOptions(input, r, Options{Extensions: extensions | EXTENSION_TABLES})
+	if len(r.tables) == 0 {
+		return nil, ErrNoTable
+	}
+
+	rows := r.tables[0]
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+
+	return rows, nil
+}
+
+// tableRenderer collects the cells of every table in the document and
+// renders the inline content of the cells as plain text. Everything else is
+// dropped.
+type tableRenderer struct {
+	nullRenderer
+	tables [][][]string
+	rows   [][]string
+	row    []string
+}
+
+func (r *tableRenderer) Table(out *bytes.Buffer, header, body []byte, columnData []int) {
+	r.tables = append(r.tables, r.rows)
+	r.rows = nil
+}
+
+func (r *tableRenderer) TableRow(out *bytes.Buffer, text []byte) {
+	r.rows = append(r.rows, r.row)
+	r.row = nil
+}
+
+func (r *tableRenderer) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	r.row = append(r.row, string(text))
+}
+
+func (r *tableRenderer) TableCell(out *bytes.Buffer, text []byte, flags int) {
+	r.row = append(r.row, string(text))
+}
+
+func (r *tableRenderer) AutoLink(out *bytes.Buffer, link []byte, kind int) {
+	out.Write(link)
+}
+
+func (r *tableRenderer) CodeSpan(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) DoubleEmphasis(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Emphasis(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Image(out *bytes.Buffer, link, title, alt []byte) {
+	out.Write(alt)
+}
+
+func (r *tableRenderer) Link(out *bytes.Buffer, link, title, content []byte) {
+	out.Write(content)
+}
+
+func (r *tableRenderer) TripleEmphasis(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) StrikeThrough(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Superscript(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Subscript(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Highlight(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Math(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (r *tableRenderer) Entity(out *bytes.Buffer, entity []byte) {
+	out.WriteString(html.UnescapeString(string(entity)))
+}
+
+func (r *tableRenderer) NormalText(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
@@ -0,0 +1,172 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// Emoji shortcodes
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+)
+
+// ':': parse an emoji shortcode, as in :smile:, and fall back to looking for
+// an autolink when that is enabled too
+func emoji(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	end := offset + 1
+	for end < len(data) && isEmojiNameChar(data[end]) {
+		end++
+	}
+	if end > offset+1 && end < len(data) && data[end] == ':' {
+		name := string(data[offset+1 : end])
+		e, ok := p.emoji[name]
+		if !ok {
+			e, ok = emojiShortcodes[name]
+		}
+		if ok {
+			p.r.NormalText(out, []byte(e))
+			return end - offset + 1
+		}
+	}
+
+	if p.flags&EXTENSION_AUTOLINK != 0 {
+		return autoLink(p, out, data, offset)
+	}
+	return 0
+}
+
+func isEmojiNameChar(c byte) bool {
+	return isalnum(c) || c == '_' || c == '+' || c == '-'
+}
+
+// emojiShortcodes maps the names of the built-in emoji shortcodes to their
+// emoji. Options.Emoji can add more or override these.
+var emojiShortcodes = map[string]string{
+	"+1":                    "\U0001F44D",
+	"-1":                    "\U0001F44E",
+	"100":                   "\U0001F4AF",
+	"angry":                 "\U0001F620",
+	"apple":                 "\U0001F34E",
+	"arrow_down":            "\u2B07\uFE0F",
+	"arrow_left":            "\u2B05\uFE0F",
+	"arrow_right":           "\u27A1\uFE0F",
+	"arrow_up":              "\u2B06\uFE0F",
+	"baby":                  "\U0001F476",
+	"balloon":               "\U0001F388",
+	"bangbang":              "\u203C\uFE0F",
+	"beer":                  "\U0001F37A",
+	"bell":                  "\U0001F514",
+	"blush":                 "\U0001F60A",
+	"bomb":                  "\U0001F4A3",
+	"book":                  "\U0001F4D6",
+	"broken_heart":          "\U0001F494",
+	"bug":                   "\U0001F41B",
+	"bulb":                  "\U0001F4A1",
+	"cake":                  "\U0001F370",
+	"calendar":              "\U0001F4C6",
+	"camera":                "\U0001F4F7",
+	"cat":                   "\U0001F431",
+	"champagne":             "\U0001F37E",
+	"check":                 "\u2714\uFE0F",
+	"clap":                  "\U0001F44F",
+	"clock1":                "\U0001F550",
+	"cloud":                 "\u2601\uFE0F",
+	"coffee":                "\u2615",
+	"computer":              "\U0001F4BB",
+	"confused":              "\U0001F615",
+	"construction":          "\U0001F6A7",
+	"cool":                  "\U0001F192",
+	"cry":                   "\U0001F622",
+	"dart":                  "\U0001F3AF",
+	"dog":                   "\U0001F436",
+	"dollar":                "\U0001F4B5",
+	"eyes":                  "\U0001F440",
+	"fire":                  "\U0001F525",
+	"flushed":               "\U0001F633",
+	"gem":                   "\U0001F48E",
+	"gift":                  "\U0001F381",
+	"grin":                  "\U0001F601",
+	"grinning":              "\U0001F600",
+	"hammer":                "\U0001F528",
+	"hand":                  "\u270B",
+	"heart":                 "\u2764\uFE0F",
+	"heart_eyes":            "\U0001F60D",
+	"heavy_check_mark":      "\u2714\uFE0F",
+	"hourglass":             "\u231B",
+	"hugs":                  "\U0001F917",
+	"information_source":    "\u2139\uFE0F",
+	"joy":                   "\U0001F602",
+	"key":                   "\U0001F511",
+	"kiss":                  "\U0001F48B",
+	"laughing":              "\U0001F606",
+	"lock":                  "\U0001F512",
+	"love_letter":           "\U0001F48C",
+	"mag":                   "\U0001F50D",
+	"memo":                  "\U0001F4DD",
+	"moon":                  "\U0001F319",
+	"muscle":                "\U0001F4AA",
+	"musical_note":          "\U0001F3B5",
+	"neutral_face":          "\U0001F610",
+	"no_entry":              "\u26D4",
+	"ok":                    "\U0001F197",
+	"ok_hand":               "\U0001F44C",
+	"open_mouth":            "\U0001F62E",
+	"package":               "\U0001F4E6",
+	"pencil":                "\U0001F4DD",
+	"pencil2":               "\u270F\uFE0F",
+	"point_down":            "\U0001F447",
+	"point_left":            "\U0001F448",
+	"point_right":           "\U0001F449",
+	"point_up":              "\u261D\uFE0F",
+	"pray":                  "\U0001F64F",
+	"pushpin":               "\U0001F4CC",
+	"question":              "\u2753",
+	"rage":                  "\U0001F621",
+	"rainbow":               "\U0001F308",
+	"raised_hands":          "\U0001F64C",
+	"recycle":               "\u267B\uFE0F",
+	"relaxed":               "\u263A\uFE0F",
+	"rocket":                "\U0001F680",
+	"rose":                  "\U0001F339",
+	"scream":                "\U0001F631",
+	"see_no_evil":           "\U0001F648",
+	"shipit":                "\U0001F43F\uFE0F",
+	"skull":                 "\U0001F480",
+	"sleeping":              "\U0001F634",
+	"slightly_smiling_face": "\U0001F642",
+	"smile":                 "\U0001F604",
+	"smiley":                "\U0001F603",
+	"smirk":                 "\U0001F60F",
+	"snowflake":             "\u2744\uFE0F",
+	"sob":                   "\U0001F62D",
+	"sparkles":              "\u2728",
+	"star":                  "\u2B50",
+	"stuck_out_tongue":      "\U0001F61B",
+	"sunglasses":            "\U0001F60E",
+	"sunny":                 "\u2600\uFE0F",
+	"sweat_smile":           "\U0001F605",
+	"tada":                  "\U0001F389",
+	"thinking":              "\U0001F914",
+	"thumbsdown":            "\U0001F44E",
+	"thumbsup":              "\U0001F44D",
+	"trophy":                "\U0001F3C6",
+	"umbrella":              "\u2614",
+	"unamused":              "\U0001F612",
+	"warning":               "\u26A0\uFE0F",
+	"wave":                  "\U0001F44B",
+	"white_check_mark":      "\u2705",
+	"wink":                  "\U0001F609",
+	"worried":               "\U0001F61F",
+	"x":                     "\u274C",
+	"zap":                   "\u26A1",
+	"zzz":                   "\U0001F4A4",
+}
@@ -18,9 +18,14 @@ package blackfriday
 import (
 	"bytes"
 	"fmt"
+	"html"
+	"io"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Html renderer configuration options.
@@ -37,13 +42,20 @@ const (
 	HTML_OMIT_CONTENTS                         // skip the main contents (for a standalone table of contents)
 	HTML_COMPLETE_PAGE                         // generate a complete HTML page
 	HTML_USE_XHTML                             // generate XHTML output instead of HTML
-	HTML_USE_SMARTYPANTS                       // enable smart punctuation substitutions
-	HTML_SMARTYPANTS_FRACTIONS                 // enable smart fractions (with HTML_USE_SMARTYPANTS)
-	HTML_SMARTYPANTS_DASHES                    // enable smart dashes (with HTML_USE_SMARTYPANTS)
-	HTML_SMARTYPANTS_LATEX_DASHES              // enable LaTeX-style dashes (with HTML_USE_SMARTYPANTS and HTML_SMARTYPANTS_DASHES)
+	HTML_USE_SMARTYPANTS                       // enable smart quotes, ellipses and other punctuation substitutions
+	HTML_SMARTYPANTS_FRACTIONS                 // enable smart fractions
+	HTML_SMARTYPANTS_DASHES                    // enable smart dashes
+	HTML_SMARTYPANTS_LATEX_DASHES              // enable LaTeX-style dashes (with HTML_SMARTYPANTS_DASHES)
 	HTML_SMARTYPANTS_ANGLED_QUOTES             // enable angled double quotes (with HTML_USE_SMARTYPANTS) for double quotes rendering
 	HTML_SMARTYPANTS_QUOTES_NBSP               // enable "French guillemets" (with HTML_USE_SMARTYPANTS)
 	HTML_FOOTNOTE_RETURN_LINKS                 // generate a link at the end of a footnote to return to the source
+	HTML_FOOTNOTE_ALL_RETURN_LINKS             // generate a return link for every reference to a footnote (with HTML_FOOTNOTE_RETURN_LINKS)
+	HTML_META_TAGS                             // generate title and description meta tags from the document (with HTML_COMPLETE_PAGE)
+	HTML_NBSP_EMPTY_TABLE_CELLS                // fill empty table cells with a non-breaking space
+	HTML_HEADER_DATA_ANCHOR                    // add the header ID as a data-anchor attribute too, for permalinks added by scripts
+	HTML_CODE_LANGUAGE_BADGE                   // label code blocks that have a language with a code-lang-badge span
+	HTML_NOOPENER_LINKS                        // add rel="noopener" to external links, without noreferrer
+	HTML_ESCAPE_HTML                           // escape raw HTML blocks and tags so they show as text (HTML_SKIP_HTML drops them instead)
 )
 
 var (
@@ -71,6 +83,64 @@ type HtmlRendererParameters struct {
 	HeaderIDPrefix string
 	// If set, add this text to the back of each Header ID, to ensure uniqueness.
 	HeaderIDSuffix string
+	// If set, wrap the rendered document in an element with this tag name,
+	// inside <body> if HTML_COMPLETE_PAGE is enabled.
+	WrapperTag string
+	// If set, the class of the WrapperTag element.
+	WrapperClass string
+	// If set, cut the rendered document off after this many bytes, then
+	// close the elements left open so the HTML stays well-formed. Once the
+	// output is past the limit, the text of the top-level headers and
+	// paragraphs that follow is not parsed any more. Html.Truncated reports
+	// whether the document was cut off.
+	MaxOutputBytes int
+	// Add this text where the document was cut off by MaxOutputBytes. If
+	// blank, &hellip; is used.
+	TruncationMarker string
+	// If set, only headers of this level or deeper are listed in the table
+	// of contents generated by HTML_TOC. The shallowest level listed forms
+	// the outer list.
+	TocMinLevel int
+	// If set, only headers of this level or shallower are listed in the
+	// table of contents.
+	TocMaxLevel int
+	// Links to these hosts, like relative links, are not external: they get
+	// no rel attributes or blank target. Hosts match case-insensitively and
+	// without the port.
+	InternalHosts []string
+	// If set, called for each code block with the language, the first word
+	// of the info string of a fenced block, which may be empty. Returning
+	// true means the block was rendered to w, for instance by a syntax
+	// highlighter; returning false falls back to the usual <pre><code>
+	// output, and anything written to w is discarded.
+	CodeBlockRenderer func(w io.Writer, lang string, code []byte) (handled bool)
+	// If set, raw HTML passes through this function before it is written,
+	// so that it can be sanitized. It is called separately for each HTML
+	// block and each inline tag, not on the whole output, and only for the
+	// HTML that the HTML_SKIP_* flags leave in.
+	HtmlFilter func(html []byte) []byte
+	// Add this to the level of each header, so that documents embedded in a
+	// page can start at <h2>. Levels are kept within 1 to 6. Header IDs and
+	// the TOC level bounds are unaffected.
+	HeaderLevelOffset int
+	// If set, called with the destination of each link and the source of
+	// each image, after reference links are resolved, and its result is
+	// used in their place, before AbsolutePrefix and HTML_SAFELINK are
+	// applied. isImage tells images from links. Every destination is
+	// passed, absolute URLs, #anchors and mailto: included, and it may be
+	// returned unchanged. Autolinks are rewritten too, email addresses
+	// with mailto:, but their text is left as it is.
+	LinkRewriter func(link []byte, isImage bool) []byte
+	// If set, written as it is at the end of the <head> element when
+	// HTML_COMPLETE_PAGE is enabled, for extra stylesheets, scripts or
+	// meta tags.
+	HeadContent string
+	// If set, added to the class of the checked items of task lists, as in
+	// class="task-list-item completed", so that they can be styled.
+	TaskCheckedClass string
+	// Strike the text of the checked items of task lists through with
+	// <del>. In an item made of blocks, only the first paragraph is.
+	TaskCheckedDel bool
 }
 
 // Html is a type that implements the Renderer interface for HTML output.
@@ -90,9 +160,35 @@ type Html struct {
 	currentLevel int
 	toc          *bytes.Buffer
 
+	// the buffer of the top-level blocks, and where a [TOC] paragraph in it
+	// asks for the table of contents to go, or -1
+	document       *bytes.Buffer
+	tocPlaceholder int
+
 	// Track header IDs to prevent ID collision in a single generation.
 	headerIDs map[string]int
 
+	// Count the references to each footnote, so that every reference gets a
+	// unique anchor to return to.
+	footnoteRefs map[string]int
+
+	// scratch space for the footnote slugs
+	slug []byte
+
+	// meta tag data: the text of the first header and paragraph, and where
+	// in the page header to put the tags once they are known
+	metaMarker      int
+	metaTitle       string
+	metaDescription string
+
+	// the text of the first level 1 header, and where to put it in the page
+	// header as the title if none was given
+	titleMarker int
+	pageTitle   string
+
+	// whether the document was cut off by MaxOutputBytes
+	truncated bool
+
 	smartypants *smartypantsRenderer
 }
 
@@ -107,7 +203,8 @@ const (
 // flags is a set of HTML_* options ORed together.
 // title is the title of the document, and css is a URL for the document's
 // stylesheet.
-// title and css are only used when HTML_COMPLETE_PAGE is selected.
+// title and css are only used when HTML_COMPLETE_PAGE is selected. If title
+// is empty, the text of the first level 1 header is used.
 func HtmlRenderer(flags int, title string, css string) Renderer {
 	return HtmlRendererWithParameters(flags, title, css, HtmlRendererParameters{})
 }
@@ -124,6 +221,10 @@ func HtmlRendererWithParameters(flags int, title string,
 		renderParameters.FootnoteReturnLinkContents = `<sup>[return]</sup>`
 	}
 
+	if renderParameters.TruncationMarker == "" {
+		renderParameters.TruncationMarker = "&hellip;"
+	}
+
 	return &Html{
 		flags:      flags,
 		closeTag:   closeTag,
@@ -131,12 +232,15 @@ func HtmlRendererWithParameters(flags int, title string,
 		css:        css,
 		parameters: renderParameters,
 
-		headerCount:  0,
-		currentLevel: 0,
-		toc:          new(bytes.Buffer),
+		headerCount:    0,
+		currentLevel:   0,
+		toc:            new(bytes.Buffer),
+		tocPlaceholder: -1,
 
 		headerIDs: make(map[string]int),
 
+		footnoteRefs: make(map[string]int),
+
 		smartypants: smartypants(flags),
 	}
 }
@@ -201,9 +305,19 @@ func (options *Html) TitleBlock(out *bytes.Buffer, text []byte) {
 }
 
 func (options *Html) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	if options.pastMaxOutput(out) {
+		return
+	}
 	marker := out.Len()
 	doubleSpace(out)
 
+	tagLevel := level + options.parameters.HeaderLevelOffset
+	if tagLevel < 1 {
+		tagLevel = 1
+	} else if tagLevel > 6 {
+		tagLevel = 6
+	}
+
 	if id == "" && options.flags&HTML_TOC != 0 {
 		id = fmt.Sprintf("toc_%d", options.headerCount)
 	}
@@ -219,9 +333,13 @@ func (options *Html) Header(out *bytes.Buffer, text func() bool, level int, id s
 			id = id + options.parameters.HeaderIDSuffix
 		}
 
-		out.WriteString(fmt.Sprintf("<h%d id=\"%s\">", level, id))
+		if options.flags&HTML_HEADER_DATA_ANCHOR != 0 {
+			out.WriteString(fmt.Sprintf("<h%d id=\"%s\" data-anchor=\"%s\">", tagLevel, id, id))
+		} else {
+			out.WriteString(fmt.Sprintf("<h%d id=\"%s\">", tagLevel, id))
+		}
 	} else {
-		out.WriteString(fmt.Sprintf("<h%d>", level))
+		out.WriteString(fmt.Sprintf("<h%d>", tagLevel))
 	}
 
 	tocMarker := out.Len()
@@ -230,12 +348,26 @@ func (options *Html) Header(out *bytes.Buffer, text func() bool, level int, id s
 		return
 	}
 
+	if options.flags&HTML_META_TAGS != 0 && options.metaTitle == "" {
+		options.metaTitle = htmlToText(out.Bytes()[tocMarker:])
+	}
+	if options.flags&HTML_COMPLETE_PAGE != 0 && level == 1 && options.pageTitle == "" {
+		options.pageTitle = htmlToText(out.Bytes()[tocMarker:])
+	}
+
 	// are we building a table of contents?
-	if options.flags&HTML_TOC != 0 {
-		options.TocHeaderWithAnchor(out.Bytes()[tocMarker:], level, id)
+	if options.flags&HTML_TOC != 0 && options.inTocLevels(level) {
+		tocLevel := level
+		if options.parameters.TocMinLevel > 1 {
+			tocLevel -= options.parameters.TocMinLevel - 1
+		}
+		options.TocHeaderWithAnchor(out.Bytes()[tocMarker:], tocLevel, id)
+	} else if options.flags&HTML_TOC != 0 {
+		// keep the generated toc_ IDs unique
+		options.headerCount++
 	}
 
-	out.WriteString(fmt.Sprintf("</h%d>\n", level))
+	out.WriteString(fmt.Sprintf("</h%d>\n", tagLevel))
 }
 
 func (options *Html) BlockHtml(out *bytes.Buffer, text []byte) {
@@ -243,6 +375,18 @@ func (options *Html) BlockHtml(out *bytes.Buffer, text []byte) {
 		return
 	}
 
+	if options.flags&HTML_ESCAPE_HTML != 0 {
+		doubleSpace(out)
+		out.WriteString("<p>")
+		attrEscape(out, bytes.TrimRight(text, "\n"))
+		out.WriteString("</p>\n")
+		return
+	}
+
+	if options.parameters.HtmlFilter != nil {
+		text = options.parameters.HtmlFilter(text)
+	}
+
 	doubleSpace(out)
 	out.Write(text)
 	out.WriteByte('\n')
@@ -263,17 +407,58 @@ func (options *Html) BlockCode(out *bytes.Buffer, text []byte, info string) {
 		endOfLang = len(info)
 	}
 	lang := info[:endOfLang]
+	classes, attrs, _ := parseAttributes(info[endOfLang:])
+
+	// let the highlighter render the block if it wants to
+	if options.parameters.CodeBlockRenderer != nil {
+		var highlighted bytes.Buffer
+		if options.parameters.CodeBlockRenderer(&highlighted, lang, text) {
+			out.Write(highlighted.Bytes())
+			return
+		}
+	}
+
 	if len(lang) == 0 || lang == "." {
-		out.WriteString("<pre><code>")
+		out.WriteString("<pre><code")
 	} else {
-		out.WriteString("<pre><code class=\"language-")
-		attrEscape(out, []byte(lang))
-		out.WriteString("\">")
+		out.WriteString("<pre>")
+		if options.flags&HTML_CODE_LANGUAGE_BADGE != 0 {
+			out.WriteString("<span class=\"code-lang-badge\">")
+			attrEscape(out, []byte(lang))
+			out.WriteString("</span>")
+		}
+		out.WriteString("<code")
+		classes = append([]string{"language-" + lang}, classes...)
+	}
+	if len(classes) > 0 {
+		out.WriteString(" class=\"")
+		attrEscape(out, []byte(strings.Join(classes, " ")))
+		out.WriteString("\"")
 	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out.WriteString(" data-")
+		out.WriteString(key)
+		out.WriteString("=\"")
+		attrEscape(out, []byte(attrs[key]))
+		out.WriteString("\"")
+	}
+	out.WriteString(">")
 	attrEscape(out, text)
 	out.WriteString("</code></pre>\n")
 }
 
+func (options *Html) MathBlock(out *bytes.Buffer, text []byte) {
+	doubleSpace(out)
+	out.WriteString("<div class=\"math display\">")
+	attrEscape(out, text)
+	out.WriteString("</div>\n")
+}
+
 func (options *Html) BlockQuote(out *bytes.Buffer, text []byte) {
 	doubleSpace(out)
 	out.WriteString("<blockquote>\n")
@@ -281,11 +466,63 @@ func (options *Html) BlockQuote(out *bytes.Buffer, text []byte) {
 	out.WriteString("</blockquote>\n")
 }
 
+func (options *Html) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {
+	doubleSpace(out)
+	out.WriteString("<div class=\"admonition ")
+	attrEscape(out, []byte(kind))
+	out.WriteString("\">\n")
+	if len(title) > 0 {
+		out.WriteString("<p class=\"admonition-title\">")
+		out.Write(title)
+		out.WriteString("</p>\n")
+	}
+	out.Write(text)
+	out.WriteString("</div>\n")
+}
+
+func (options *Html) Alert(out *bytes.Buffer, text []byte, kind string) {
+	doubleSpace(out)
+	out.WriteString("<div class=\"markdown-alert markdown-alert-")
+	attrEscape(out, []byte(kind))
+	out.WriteString("\">\n")
+	out.WriteString("<p class=\"markdown-alert-title\">")
+	attrEscape(out, alertTitle(kind))
+	out.WriteString("</p>\n")
+	out.Write(text)
+	out.WriteString("</div>\n")
+}
+
+func (options *Html) Div(out *bytes.Buffer, text []byte, class string) {
+	doubleSpace(out)
+	out.WriteString("<div")
+	if class != "" {
+		out.WriteString(" class=\"")
+		attrEscape(out, []byte(class))
+		out.WriteString("\"")
+	}
+	out.WriteString(">\n")
+	out.Write(text)
+	out.WriteString("</div>\n")
+}
+
 func (options *Html) Table(out *bytes.Buffer, header []byte, body []byte, columnData []int) {
+	options.CaptionedTable(out, header, body, columnData, nil)
+}
+
+func (options *Html) CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte) {
 	doubleSpace(out)
-	out.WriteString("<table>\n<thead>\n")
-	out.Write(header)
-	out.WriteString("</thead>\n\n<tbody>\n")
+	out.WriteString("<table>\n")
+	if len(caption) > 0 {
+		out.WriteString("<caption>")
+		out.Write(caption)
+		out.WriteString("</caption>\n")
+	}
+	if len(header) > 0 {
+		out.WriteString("<thead>\n")
+		out.Write(header)
+		out.WriteString("</thead>\n\n")
+	}
+	out.WriteString("<tbody>\n")
 	out.Write(body)
 	out.WriteString("</tbody>\n</table>\n")
 }
@@ -297,41 +534,49 @@ func (options *Html) TableRow(out *bytes.Buffer, text []byte) {
 	out.WriteString("\n</tr>\n")
 }
 
-func (options *Html) TableHeaderCell(out *bytes.Buffer, text []byte, align int) {
-	doubleSpace(out)
-	switch align {
-	case TABLE_ALIGNMENT_LEFT:
-		out.WriteString("<th align=\"left\">")
-	case TABLE_ALIGNMENT_RIGHT:
-		out.WriteString("<th align=\"right\">")
-	case TABLE_ALIGNMENT_CENTER:
-		out.WriteString("<th align=\"center\">")
-	default:
-		out.WriteString("<th>")
-	}
+func (options *Html) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	options.tableCell(out, "th", text, flags)
+}
 
-	out.Write(text)
-	out.WriteString("</th>")
+func (options *Html) TableCell(out *bytes.Buffer, text []byte, flags int) {
+	options.tableCell(out, "td", text, flags)
 }
 
-func (options *Html) TableCell(out *bytes.Buffer, text []byte, align int) {
+func (options *Html) tableCell(out *bytes.Buffer, tag string, text []byte, flags int) {
 	doubleSpace(out)
-	switch align {
+	out.WriteString("<")
+	out.WriteString(tag)
+	switch flags & TABLE_ALIGNMENT_MASK {
 	case TABLE_ALIGNMENT_LEFT:
-		out.WriteString("<td align=\"left\">")
+		out.WriteString(" align=\"left\"")
 	case TABLE_ALIGNMENT_RIGHT:
-		out.WriteString("<td align=\"right\">")
+		out.WriteString(" align=\"right\"")
 	case TABLE_ALIGNMENT_CENTER:
-		out.WriteString("<td align=\"center\">")
-	default:
-		out.WriteString("<td>")
+		out.WriteString(" align=\"center\"")
+	case TABLE_ALIGNMENT_JUSTIFY:
+		out.WriteString(" style=\"text-align: justify\"")
 	}
+	if span := flags >> TABLE_CELL_SPAN_SHIFT; span > 0 {
+		out.WriteString(" colspan=\"")
+		out.WriteString(strconv.Itoa(span + 1))
+		out.WriteString("\"")
+	}
+	out.WriteString(">")
 
-	out.Write(text)
-	out.WriteString("</td>")
+	if options.flags&HTML_NBSP_EMPTY_TABLE_CELLS != 0 && len(bytes.TrimSpace(text)) == 0 {
+		out.WriteString("&nbsp;")
+	} else {
+		out.Write(text)
+	}
+	out.WriteString("</")
+	out.WriteString(tag)
+	out.WriteString(">")
 }
 
 func (options *Html) Footnotes(out *bytes.Buffer, text func() bool) {
+	if options.pastMaxOutput(out) {
+		return
+	}
 	out.WriteString("<div class=\"footnotes\">\n")
 	options.HRule(out)
 	options.List(out, text, LIST_TYPE_ORDERED)
@@ -342,7 +587,8 @@ func (options *Html) FootnoteItem(out *bytes.Buffer, name, text []byte, flags in
 	if flags&LIST_ITEM_CONTAINS_BLOCK != 0 || flags&LIST_ITEM_BEGINNING_OF_LIST != 0 {
 		doubleSpace(out)
 	}
-	slug := slugify(name)
+	options.slug = appendSlug(options.slug[:0], name)
+	slug := options.slug
 	out.WriteString(`<li id="`)
 	out.WriteString(`fn:`)
 	out.WriteString(options.parameters.FootnoteAnchorPrefix)
@@ -350,25 +596,45 @@ func (options *Html) FootnoteItem(out *bytes.Buffer, name, text []byte, flags in
 	out.WriteString(`">`)
 	out.Write(text)
 	if options.flags&HTML_FOOTNOTE_RETURN_LINKS != 0 {
-		out.WriteString(` <a class="footnote-return" href="#`)
-		out.WriteString(`fnref:`)
-		out.WriteString(options.parameters.FootnoteAnchorPrefix)
-		out.Write(slug)
-		out.WriteString(`">`)
-		out.WriteString(options.parameters.FootnoteReturnLinkContents)
-		out.WriteString(`</a>`)
+		refs := 1
+		if options.flags&HTML_FOOTNOTE_ALL_RETURN_LINKS != 0 && options.footnoteRefs[string(slug)] > 1 {
+			refs = options.footnoteRefs[string(slug)]
+		}
+		for i := 1; i <= refs; i++ {
+			out.WriteString(` <a class="footnote-return" href="#`)
+			out.WriteString(`fnref:`)
+			out.WriteString(options.parameters.FootnoteAnchorPrefix)
+			out.Write(slug)
+			if i > 1 {
+				out.WriteByte(':')
+				out.WriteString(strconv.Itoa(i))
+			}
+			out.WriteString(`">`)
+			out.WriteString(options.parameters.FootnoteReturnLinkContents)
+			out.WriteString(`</a>`)
+		}
 	}
 	out.WriteString("</li>\n")
 }
 
 func (options *Html) List(out *bytes.Buffer, text func() bool, flags int) {
+	options.NumberedList(out, text, flags, 1)
+}
+
+func (options *Html) NumberedList(out *bytes.Buffer, text func() bool, flags, start int) {
 	marker := out.Len()
 	doubleSpace(out)
 
 	if flags&LIST_TYPE_DEFINITION != 0 {
 		out.WriteString("<dl>")
 	} else if flags&LIST_TYPE_ORDERED != 0 {
-		out.WriteString("<ol>")
+		if start != 1 {
+			out.WriteString("<ol start=\"")
+			out.WriteString(strconv.Itoa(start))
+			out.WriteString("\">")
+		} else {
+			out.WriteString("<ol>")
+		}
 	} else {
 		out.WriteString("<ul>")
 	}
@@ -394,9 +660,41 @@ func (options *Html) ListItem(out *bytes.Buffer, text []byte, flags int) {
 		out.WriteString("<dt>")
 	} else if flags&LIST_TYPE_DEFINITION != 0 {
 		out.WriteString("<dd>")
+	} else if flags&LIST_ITEM_TASK != 0 {
+		out.WriteString("<li class=\"task-list-item")
+		if flags&LIST_ITEM_CHECKED != 0 && options.parameters.TaskCheckedClass != "" {
+			out.WriteByte(' ')
+			attrEscape(out, []byte(options.parameters.TaskCheckedClass))
+		}
+		out.WriteString("\">")
 	} else {
 		out.WriteString("<li>")
 	}
+	if flags&LIST_ITEM_TASK != 0 {
+		// the checkbox goes into the first paragraph of a block item
+		paragraph := bytes.HasPrefix(text, []byte("<p>"))
+		if paragraph {
+			out.WriteString("<p>")
+			text = text[len("<p>"):]
+		}
+		out.WriteString("<input type=\"checkbox\" disabled=\"\"")
+		if flags&LIST_ITEM_CHECKED != 0 {
+			out.WriteString(" checked=\"\"")
+		}
+		out.WriteString(options.closeTag)
+		out.WriteString(" ")
+
+		if flags&LIST_ITEM_CHECKED != 0 && options.parameters.TaskCheckedDel {
+			end := len(text)
+			if i := bytes.Index(text, []byte("</p>")); paragraph && i >= 0 {
+				end = i
+			}
+			out.WriteString("<del>")
+			out.Write(text[:end])
+			out.WriteString("</del>")
+			text = text[end:]
+		}
+	}
 	out.Write(text)
 	if flags&LIST_TYPE_TERM != 0 {
 		out.WriteString("</dt>\n")
@@ -408,20 +706,41 @@ func (options *Html) ListItem(out *bytes.Buffer, text []byte, flags int) {
 }
 
 func (options *Html) Paragraph(out *bytes.Buffer, text func() bool) {
+	if options.pastMaxOutput(out) {
+		return
+	}
 	marker := out.Len()
 	doubleSpace(out)
 
 	out.WriteString("<p>")
+	textMarker := out.Len()
 	if !text() {
 		out.Truncate(marker)
 		return
 	}
+	// a top-level [TOC] paragraph marks where the table of contents goes
+	if options.flags&HTML_TOC != 0 && out == options.document && options.tocPlaceholder < 0 &&
+		string(out.Bytes()[textMarker:]) == "[TOC]" {
+		out.Truncate(marker)
+		options.tocPlaceholder = marker
+		return
+	}
+	if options.flags&HTML_META_TAGS != 0 && options.metaDescription == "" {
+		options.metaDescription = htmlToText(out.Bytes()[textMarker:])
+	}
 	out.WriteString("</p>\n")
 }
 
 func (options *Html) AutoLink(out *bytes.Buffer, link []byte, kind int) {
 	skipRanges := htmlEntity.FindAllIndex(link, -1)
-	if options.flags&HTML_SAFELINK != 0 && !isSafeLink(link) && kind != LINK_TYPE_EMAIL {
+	href := link
+	if kind == LINK_TYPE_EMAIL {
+		href = append([]byte("mailto:"), link...)
+	}
+	if options.parameters.LinkRewriter != nil {
+		href = options.parameters.LinkRewriter(href, false)
+	}
+	if options.flags&HTML_SAFELINK != 0 && !isSafeLink(href) && kind != LINK_TYPE_EMAIL {
 		// mark it but don't link it if it is not a safe link: no smartypants
 		out.WriteString("<tt>")
 		entityEscapeWithSkip(out, link, skipRanges)
@@ -430,29 +749,10 @@ func (options *Html) AutoLink(out *bytes.Buffer, link []byte, kind int) {
 	}
 
 	out.WriteString("<a href=\"")
-	if kind == LINK_TYPE_EMAIL {
-		out.WriteString("mailto:")
-	} else {
-		options.maybeWriteAbsolutePrefix(out, link)
-	}
-
-	entityEscapeWithSkip(out, link, skipRanges)
-
-	var relAttrs []string
-	if options.flags&HTML_NOFOLLOW_LINKS != 0 && !isRelativeLink(link) {
-		relAttrs = append(relAttrs, "nofollow")
-	}
-	if options.flags&HTML_NOREFERRER_LINKS != 0 && !isRelativeLink(link) {
-		relAttrs = append(relAttrs, "noreferrer")
-	}
-	if len(relAttrs) > 0 {
-		out.WriteString(fmt.Sprintf("\" rel=\"%s", strings.Join(relAttrs, " ")))
-	}
+	options.maybeWriteAbsolutePrefix(out, href)
+	entityEscapeWithSkip(out, href, htmlEntity.FindAllIndex(href, -1))
 
-	// blank target only add to external link
-	if options.flags&HTML_HREF_TARGET_BLANK != 0 && !isRelativeLink(link) {
-		out.WriteString("\" target=\"_blank")
-	}
+	options.writeLinkAttrs(out, href, nil)
 
 	out.WriteString("\">")
 
@@ -472,7 +772,17 @@ func (options *Html) AutoLink(out *bytes.Buffer, link []byte, kind int) {
 }
 
 func (options *Html) CodeSpan(out *bytes.Buffer, text []byte) {
-	out.WriteString("<code>")
+	options.LanguageCodeSpan(out, text, "")
+}
+
+func (options *Html) LanguageCodeSpan(out *bytes.Buffer, text []byte, lang string) {
+	if lang == "" {
+		out.WriteString("<code>")
+	} else {
+		out.WriteString("<code class=\"language-")
+		attrEscape(out, []byte(lang))
+		out.WriteString("\">")
+	}
 	attrEscape(out, text)
 	out.WriteString("</code>")
 }
@@ -506,6 +816,10 @@ func (options *Html) Image(out *bytes.Buffer, link []byte, title []byte, alt []b
 		return
 	}
 
+	if options.parameters.LinkRewriter != nil {
+		link = options.parameters.LinkRewriter(link, true)
+	}
+
 	out.WriteString("<img src=\"")
 	options.maybeWriteAbsolutePrefix(out, link)
 	attrEscape(out, link)
@@ -529,6 +843,14 @@ func (options *Html) LineBreak(out *bytes.Buffer) {
 }
 
 func (options *Html) Link(out *bytes.Buffer, link []byte, title []byte, content []byte) {
+	options.AttributedLink(out, link, title, content, nil, nil)
+}
+
+// AttributedLink writes a link with the classes and attributes that followed
+// it. A rel attribute adds to the rel values set by the flags, and target
+// wins over HTML_HREF_TARGET_BLANK; href, title and event handler attributes
+// are dropped.
+func (options *Html) AttributedLink(out *bytes.Buffer, link []byte, title []byte, content []byte, classes []string, attrs map[string]string) {
 	if options.flags&HTML_SKIP_LINKS != 0 {
 		// write the link text out but don't link it, just mark it with typewriter font
 		out.WriteString("<tt>")
@@ -537,6 +859,10 @@ func (options *Html) Link(out *bytes.Buffer, link []byte, title []byte, content
 		return
 	}
 
+	if options.parameters.LinkRewriter != nil {
+		link = options.parameters.LinkRewriter(link, false)
+	}
+
 	if options.flags&HTML_SAFELINK != 0 && !isSafeLink(link) {
 		// write the link text out but don't link it, just mark it with typewriter font
 		out.WriteString("<tt>")
@@ -552,20 +878,26 @@ func (options *Html) Link(out *bytes.Buffer, link []byte, title []byte, content
 		out.WriteString("\" title=\"")
 		attrEscape(out, title)
 	}
-	var relAttrs []string
-	if options.flags&HTML_NOFOLLOW_LINKS != 0 && !isRelativeLink(link) {
-		relAttrs = append(relAttrs, "nofollow")
-	}
-	if options.flags&HTML_NOREFERRER_LINKS != 0 && !isRelativeLink(link) {
-		relAttrs = append(relAttrs, "noreferrer")
-	}
-	if len(relAttrs) > 0 {
-		out.WriteString(fmt.Sprintf("\" rel=\"%s", strings.Join(relAttrs, " ")))
+	if len(classes) > 0 {
+		out.WriteString("\" class=\"")
+		attrEscape(out, []byte(strings.Join(classes, " ")))
 	}
+	options.writeLinkAttrs(out, link, attrs)
 
-	// blank target only add to external link
-	if options.flags&HTML_HREF_TARGET_BLANK != 0 && !isRelativeLink(link) {
-		out.WriteString("\" target=\"_blank")
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		switch lower := strings.ToLower(key); {
+		case lower == "href", lower == "title", lower == "rel", lower == "target", strings.HasPrefix(lower, "on"):
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out.WriteString("\" ")
+		out.WriteString(key)
+		out.WriteString("=\"")
+		attrEscape(out, []byte(attrs[key]))
 	}
 
 	out.WriteString("\">")
@@ -587,6 +919,13 @@ func (options *Html) RawHtmlTag(out *bytes.Buffer, text []byte) {
 	if options.flags&HTML_SKIP_IMAGES != 0 && isHtmlTag(text, "img") {
 		return
 	}
+	if options.flags&HTML_ESCAPE_HTML != 0 {
+		attrEscape(out, text)
+		return
+	}
+	if options.parameters.HtmlFilter != nil {
+		text = options.parameters.HtmlFilter(text)
+	}
 	out.Write(text)
 }
 
@@ -602,12 +941,57 @@ func (options *Html) StrikeThrough(out *bytes.Buffer, text []byte) {
 	out.WriteString("</del>")
 }
 
+func (options *Html) Superscript(out *bytes.Buffer, text []byte) {
+	out.WriteString("<sup>")
+	out.Write(text)
+	out.WriteString("</sup>")
+}
+
+func (options *Html) Subscript(out *bytes.Buffer, text []byte) {
+	out.WriteString("<sub>")
+	out.Write(text)
+	out.WriteString("</sub>")
+}
+
+func (options *Html) Highlight(out *bytes.Buffer, text []byte) {
+	out.WriteString("<mark>")
+	out.Write(text)
+	out.WriteString("</mark>")
+}
+
+func (options *Html) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	out.WriteString("<abbr")
+	if len(title) > 0 {
+		out.WriteString(" title=\"")
+		attrEscape(out, title)
+		out.WriteString("\"")
+	}
+	out.WriteString(">")
+	out.Write(text)
+	out.WriteString("</abbr>")
+}
+
+func (options *Html) Math(out *bytes.Buffer, text []byte) {
+	out.WriteString("<span class=\"math inline\">")
+	attrEscape(out, text)
+	out.WriteString("</span>")
+}
+
 func (options *Html) FootnoteRef(out *bytes.Buffer, ref []byte, id int) {
-	slug := slugify(ref)
+	options.slug = appendSlug(options.slug[:0], ref)
+	slug := options.slug
 	out.WriteString(`<sup class="footnote-ref" id="`)
 	out.WriteString(`fnref:`)
 	out.WriteString(options.parameters.FootnoteAnchorPrefix)
 	out.Write(slug)
+	if options.flags&HTML_FOOTNOTE_ALL_RETURN_LINKS != 0 {
+		// every reference after the first needs its own anchor
+		options.footnoteRefs[string(slug)]++
+		if n := options.footnoteRefs[string(slug)]; n > 1 {
+			out.WriteByte(':')
+			out.WriteString(strconv.Itoa(n))
+		}
+	}
 	out.WriteString(`"><a href="#`)
 	out.WriteString(`fn:`)
 	out.WriteString(options.parameters.FootnoteAnchorPrefix)
@@ -622,7 +1006,7 @@ func (options *Html) Entity(out *bytes.Buffer, entity []byte) {
 }
 
 func (options *Html) NormalText(out *bytes.Buffer, text []byte) {
-	if options.flags&HTML_USE_SMARTYPANTS != 0 {
+	if options.flags&(HTML_USE_SMARTYPANTS|HTML_SMARTYPANTS_DASHES|HTML_SMARTYPANTS_FRACTIONS) != 0 {
 		options.Smartypants(out, text)
 	} else {
 		attrEscape(out, text)
@@ -659,7 +1043,20 @@ func (options *Html) Smartypants(out *bytes.Buffer, text []byte) {
 }
 
 func (options *Html) DocumentHeader(out *bytes.Buffer) {
+	options.document = out
+	options.truncated = false
+	options.metaTitle = ""
+	options.metaDescription = ""
+	options.footnoteRefs = make(map[string]int)
+
+	// the renderer can be reused: start a new table of contents
+	options.tocPlaceholder = -1
+	options.toc.Reset()
+	options.headerCount = 0
+	options.currentLevel = 0
 	if options.flags&HTML_COMPLETE_PAGE == 0 {
+		options.wrapperHeader(out)
+		options.tocMarker = out.Len()
 		return
 	}
 
@@ -675,6 +1072,8 @@ func (options *Html) DocumentHeader(out *bytes.Buffer) {
 	}
 	out.WriteString("<head>\n")
 	out.WriteString("  <title>")
+	options.titleMarker = out.Len()
+	options.pageTitle = ""
 	options.NormalText(out, []byte(options.title))
 	out.WriteString("</title>\n")
 	out.WriteString("  <meta name=\"GENERATOR\" content=\"Blackfriday Markdown Processor v")
@@ -692,15 +1091,52 @@ func (options *Html) DocumentHeader(out *bytes.Buffer) {
 		out.WriteString(ending)
 		out.WriteString(">\n")
 	}
+	if options.parameters.HeadContent != "" {
+		out.WriteString(options.parameters.HeadContent)
+		if !strings.HasSuffix(options.parameters.HeadContent, "\n") {
+			out.WriteByte('\n')
+		}
+	}
+	options.metaMarker = out.Len()
 	out.WriteString("</head>\n")
 	out.WriteString("<body>\n")
+	options.wrapperHeader(out)
 
 	options.tocMarker = out.Len()
 }
 
+func (options *Html) wrapperHeader(out *bytes.Buffer) {
+	if options.parameters.WrapperTag == "" {
+		return
+	}
+	out.WriteString("<")
+	out.WriteString(options.parameters.WrapperTag)
+	if options.parameters.WrapperClass != "" {
+		out.WriteString(" class=\"")
+		attrEscape(out, []byte(options.parameters.WrapperClass))
+		out.WriteString("\"")
+	}
+	out.WriteString(">\n")
+}
+
 func (options *Html) DocumentFooter(out *bytes.Buffer) {
 	// finalize and insert the table of contents
-	if options.flags&HTML_TOC != 0 {
+	if options.flags&HTML_TOC != 0 && options.tocPlaceholder >= 0 && options.flags&HTML_OMIT_CONTENTS == 0 {
+		options.TocFinalize()
+
+		// the table of contents replaces the [TOC] paragraph
+		var temp bytes.Buffer
+		temp.Write(out.Bytes()[options.tocPlaceholder:])
+		out.Truncate(options.tocPlaceholder)
+		doubleSpace(out)
+		out.WriteString("<nav>\n")
+		out.Write(options.toc.Bytes())
+		out.WriteString("</nav>\n")
+		if temp.Len() > 0 && temp.Bytes()[0] != '\n' {
+			out.WriteByte('\n')
+		}
+		out.Write(temp.Bytes())
+	} else if options.flags&HTML_TOC != 0 {
 		options.TocFinalize()
 
 		// now we have to insert the table of contents into the document
@@ -733,13 +1169,178 @@ func (options *Html) DocumentFooter(out *bytes.Buffer) {
 		}
 	}
 
+	if max := options.parameters.MaxOutputBytes; max > 0 && out.Len()-options.tocMarker > max {
+		body := truncateHTML(out.Bytes()[options.tocMarker:], max, options.parameters.TruncationMarker)
+		out.Truncate(options.tocMarker)
+		out.Write(body)
+		options.truncated = true
+	}
+
+	if options.parameters.WrapperTag != "" {
+		out.WriteString("</")
+		out.WriteString(options.parameters.WrapperTag)
+		out.WriteString(">\n")
+	}
+
 	if options.flags&HTML_COMPLETE_PAGE != 0 {
+		if options.flags&HTML_META_TAGS != 0 {
+			options.insertMetaTags(out)
+		}
+		if options.title == "" && options.pageTitle != "" {
+			var temp bytes.Buffer
+			temp.Write(out.Bytes()[options.titleMarker:])
+			out.Truncate(options.titleMarker)
+			attrEscape(out, []byte(options.pageTitle))
+			out.Write(temp.Bytes())
+		}
+
 		out.WriteString("\n</body>\n")
 		out.WriteString("</html>\n")
 	}
 
 }
 
+// insertMetaTags adds the title and description meta tags, now that the
+// whole document has been seen, to the end of the page header.
+func (options *Html) insertMetaTags(out *bytes.Buffer) {
+	var meta bytes.Buffer
+	tag := func(attr, name, content string) {
+		if content == "" {
+			return
+		}
+		meta.WriteString("  <meta ")
+		meta.WriteString(attr)
+		meta.WriteString("=\"")
+		meta.WriteString(name)
+		meta.WriteString("\" content=\"")
+		attrEscape(&meta, []byte(content))
+		meta.WriteString("\"")
+		if options.flags&HTML_USE_XHTML != 0 {
+			meta.WriteString(" /")
+		}
+		meta.WriteString(">\n")
+	}
+	tag("property", "og:title", options.metaTitle)
+	tag("name", "description", options.metaDescription)
+	tag("property", "og:description", options.metaDescription)
+
+	var temp bytes.Buffer
+	temp.Write(out.Bytes()[options.metaMarker:])
+	out.Truncate(options.metaMarker)
+	out.Write(meta.Bytes())
+	out.Write(temp.Bytes())
+}
+
+// elements that have no closing tag
+var voidElements = map[string]struct{}{
+	"area": {}, "base": {}, "br": {}, "col": {}, "embed": {}, "hr": {}, "img": {},
+	"input": {}, "link": {}, "meta": {}, "source": {}, "track": {}, "wbr": {},
+}
+
+// pastMaxOutput reports whether out is the document and has grown past
+// MaxOutputBytes already, so that the top-level blocks that follow would be
+// cut off anyway. The text of headers, paragraphs and footnotes is then not
+// parsed at all, which bounds the work done on documents that expand a lot.
+// Lists are still parsed, since the parser needs their items to know where
+// they end.
+func (options *Html) pastMaxOutput(out *bytes.Buffer) bool {
+	max := options.parameters.MaxOutputBytes
+	return max > 0 && out == options.document && out.Len()-options.tocMarker > max
+}
+
+// Truncated reports whether the last document rendered was cut off by
+// MaxOutputBytes.
+func (options *Html) Truncated() bool {
+	return options.truncated
+}
+
+// truncateHTML cuts rendered HTML off after at most max bytes, without
+// breaking a tag, an entity or a UTF-8 sequence, adds the marker and closes
+// the elements that are still open.
+func truncateHTML(data []byte, max int, marker string) []byte {
+	var open []string
+	i := 0
+	for i < len(data) {
+		size := 1
+		switch {
+		case bytes.HasPrefix(data[i:], []byte("<!--")):
+			if end := bytes.Index(data[i:], []byte("-->")); end >= 0 {
+				size = end + 3
+			}
+		case data[i] == '<':
+			if end := bytes.IndexByte(data[i:], '>'); end >= 0 {
+				size = end + 1
+			}
+		case data[i] == '&':
+			if end := bytes.IndexByte(data[i:], ';'); end > 0 && end < 10 {
+				size = end + 1
+			}
+		default:
+			_, size = utf8.DecodeRune(data[i:])
+		}
+		if i+size > max {
+			break
+		}
+
+		// keep track of the open elements
+		if size > 2 && data[i] == '<' && data[i+size-1] == '>' {
+			tag := data[i+1 : i+size-1]
+			closing := len(tag) > 0 && tag[0] == '/'
+			if closing {
+				tag = tag[1:]
+			}
+			n := 0
+			for n < len(tag) && isalnum(tag[n]) {
+				n++
+			}
+			name := strings.ToLower(string(tag[:n]))
+			_, void := voidElements[name]
+			switch {
+			case name == "" || void || bytes.HasSuffix(tag, []byte("/")):
+			case closing:
+				for k := len(open) - 1; k >= 0; k-- {
+					if open[k] == name {
+						open = open[:k]
+						break
+					}
+				}
+			default:
+				open = append(open, name)
+			}
+		}
+		i += size
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:i])
+	out.WriteString(marker)
+	for k := len(open) - 1; k >= 0; k-- {
+		out.WriteString("</")
+		out.WriteString(open[k])
+		out.WriteString(">")
+	}
+	out.WriteByte('\n')
+	return out.Bytes()
+}
+
+// htmlToText strips the tags from rendered HTML and decodes the entities,
+// collapsing the whitespace, to get back the plain text.
+func htmlToText(data []byte) string {
+	var text bytes.Buffer
+	inTag := false
+	for _, c := range data {
+		switch {
+		case c == '<':
+			inTag = true
+		case c == '>' && inTag:
+			inTag = false
+		case !inTag:
+			text.WriteByte(c)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(text.String())), " ")
+}
+
 func (options *Html) TocHeaderWithAnchor(text []byte, level int, anchor string) {
 	for level > options.currentLevel {
 		switch {
@@ -781,6 +1382,12 @@ func (options *Html) TocHeaderWithAnchor(text []byte, level int, anchor string)
 	options.toc.WriteString("</a></li>\n")
 }
 
+// reports whether headers of level are listed in the table of contents
+func (options *Html) inTocLevels(level int) bool {
+	min, max := options.parameters.TocMinLevel, options.parameters.TocMaxLevel
+	return (min <= 0 || level >= min) && (max <= 0 || level <= max)
+}
+
 func (options *Html) TocHeader(text []byte, level int) {
 	options.TocHeaderWithAnchor(text, level, "")
 }
@@ -889,7 +1496,73 @@ func doubleSpace(out *bytes.Buffer) {
 	}
 }
 
+// write the rel and target attributes of a link, which only apply to
+// external links
+func (options *Html) writeLinkAttrs(out *bytes.Buffer, link []byte, attrs map[string]string) {
+	var relAttrs []string
+	target := ""
+	if options.isExternalLink(link) {
+		if options.flags&HTML_NOFOLLOW_LINKS != 0 {
+			relAttrs = append(relAttrs, "nofollow")
+		}
+		if options.flags&HTML_NOREFERRER_LINKS != 0 {
+			relAttrs = append(relAttrs, "noreferrer")
+		}
+		if options.flags&HTML_NOOPENER_LINKS != 0 {
+			relAttrs = append(relAttrs, "noopener")
+		}
+		if options.flags&HTML_HREF_TARGET_BLANK != 0 {
+			target = "_blank"
+		}
+	}
+
+	// the link's own attributes
+	for _, rel := range strings.Fields(attrs["rel"]) {
+		if !containsString(relAttrs, rel) {
+			relAttrs = append(relAttrs, rel)
+		}
+	}
+	if t, ok := attrs["target"]; ok {
+		target = t
+	}
+
+	if len(relAttrs) > 0 {
+		out.WriteString("\" rel=\"")
+		attrEscape(out, []byte(strings.Join(relAttrs, " ")))
+	}
+	if target != "" {
+		out.WriteString("\" target=\"")
+		attrEscape(out, []byte(target))
+	}
+}
+
+// reports whether link leads away from the site: it is not relative, and
+// not to one of the InternalHosts
+func (options *Html) isExternalLink(link []byte) bool {
+	if isRelativeLink(link) {
+		return false
+	}
+	if len(options.parameters.InternalHosts) == 0 {
+		return true
+	}
+	u, err := url.Parse(string(link))
+	if err != nil {
+		return true
+	}
+	host := u.Hostname()
+	for _, internal := range options.parameters.InternalHosts {
+		if strings.EqualFold(host, internal) {
+			return false
+		}
+	}
+	return true
+}
+
 func isRelativeLink(link []byte) (yes bool) {
+	if len(link) == 0 {
+		return false
+	}
+
 	// a tag begin with '#'
 	if link[0] == '#' {
 		return true
@@ -918,6 +1591,15 @@ func isRelativeLink(link []byte) (yes bool) {
 	return false
 }
 
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (options *Html) ensureUniqueHeaderID(id string) string {
 	for count, found := options.headerIDs[id]; found; count, found = options.headerIDs[id] {
 		tmp := fmt.Sprintf("%s-%d", id, count+1)
@@ -17,6 +17,8 @@ import (
 	"bytes"
 	"regexp"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
@@ -43,7 +45,7 @@ func (p *parser) inline(out *bytes.Buffer, data []byte) {
 			end++
 		}
 
-		p.r.NormalText(out, data[i:end])
+		p.normalText(out, data, i, end)
 
 		if end >= len(data) {
 			break
@@ -65,16 +67,67 @@ func (p *parser) inline(out *bytes.Buffer, data []byte) {
 	p.nesting--
 }
 
+// isWordChar tells whether c can be part of a word, for finding whole words.
+// Bytes of multi-byte UTF-8 characters count as letters.
+func isWordChar(c byte) bool {
+	return isalnum(c) || c == '_' || c >= utf8.RuneSelf
+}
+
+// normalText renders data[beg:end] as normal text, marking up the whole-word
+// occurrences of defined abbreviations in it. The bytes of data around it
+// tell whether it starts or ends inside a word.
+func (p *parser) normalText(out *bytes.Buffer, data []byte, beg, end int) {
+	// renderers without abbreviations get only normal text
+	r, ok := p.r.(AbbreviationRenderer)
+	if !ok {
+		p.r.NormalText(out, p.normalize(data[beg:end]))
+		return
+	}
+
+	start := beg
+	for i := beg; i < end && len(p.abbrs) > 0; i++ {
+		if i > 0 && isWordChar(data[i-1]) {
+			continue
+		}
+		for _, abbr := range p.abbrs {
+			n := i + len(abbr)
+			if n > end || !bytes.HasPrefix(data[i:], []byte(abbr)) ||
+				(n < len(data) && isWordChar(data[n])) {
+				continue
+			}
+			p.r.NormalText(out, p.normalize(data[start:i]))
+			var work bytes.Buffer
+			p.r.NormalText(&work, p.normalize(data[i:n]))
+			r.Abbreviation(out, work.Bytes(), p.abbreviations[abbr])
+			start = n
+			i = n - 1
+			break
+		}
+	}
+	p.r.NormalText(out, p.normalize(data[start:end]))
+}
+
 // single and double emphasis parsing
 func emphasis(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	// strikethrough only opens with exactly two tildes: the rest of a longer
+	// run is not tried again, so ~~~x~~~ stays as it is
+	if data[offset] == '~' && offset > 0 && data[offset-1] == '~' {
+		return 0
+	}
+
 	data = data[offset:]
 	c := data[0]
 	ret := 0
 
+	// without support in the renderer highlights stay normal text
+	if _, ok := p.r.(HighlightRenderer); c == '=' && !ok {
+		return 0
+	}
+
 	if len(data) > 2 && data[1] != c {
 		// whitespace cannot follow an opening emphasis;
-		// strikethrough only takes two characters '~~'
-		if c == '~' || isspace(data[1]) {
+		// strikethrough and highlight only take two characters '~~' and '=='
+		if c == '~' || c == '=' || isspace(data[1]) {
 			return 0
 		}
 		if ret = helperEmphasis(p, out, data[1:], c); ret == 0 {
@@ -96,7 +149,7 @@ func emphasis(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 	}
 
 	if len(data) > 4 && data[1] == c && data[2] == c && data[3] != c {
-		if c == '~' || isspace(data[3]) {
+		if c == '~' || c == '=' || isspace(data[3]) {
 			return 0
 		}
 		if ret = helperTripleEmphasis(p, out, data, 3, c); ret == 0 {
@@ -145,15 +198,45 @@ func codeSpan(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 		fEnd--
 	}
 
+	// look for a language attribute: `code`{.lang}
+	lang := ""
+	if p.codeSpanLanguages {
+		if n, l := codeSpanLanguage(data[end:]); n > 0 {
+			lang = l
+			end += n
+		}
+	}
+
 	// render the code span
 	if fBegin != fEnd {
-		p.r.CodeSpan(out, data[fBegin:fEnd])
+		text := p.normalizeCode(data[fBegin:fEnd])
+		if r, ok := p.r.(CodeSpanLanguageRenderer); ok && lang != "" {
+			r.LanguageCodeSpan(out, text, lang)
+		} else {
+			p.r.CodeSpan(out, text)
+		}
 	}
 
 	return end
 
 }
 
+// codeSpanLanguage parses a {.lang} attribute right after a code span and
+// returns its length and the language, or 0 if there is none.
+func codeSpanLanguage(data []byte) (int, string) {
+	if len(data) < 3 || data[0] != '{' || data[1] != '.' {
+		return 0, ""
+	}
+	i := 2
+	for i < len(data) && (isalnum(data[i]) || data[i] == '-' || data[i] == '_' || data[i] == '+') {
+		i++
+	}
+	if i == 2 || i >= len(data) || data[i] != '}' {
+		return 0, ""
+	}
+	return i + 1, string(data[2:i])
+}
+
 // newline preceded by two spaces becomes <br>
 // newline without two spaces works when EXTENSION_HARD_LINE_BREAK is enabled
 func lineBreak(p *parser, out *bytes.Buffer, data []byte, offset int) int {
@@ -167,15 +250,23 @@ func lineBreak(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 	out.Truncate(eol)
 
 	precededByTwoSpaces := offset >= 2 && data[offset-2] == ' ' && data[offset-1] == ' '
-	precededByBackslash := offset >= 1 && data[offset-1] == '\\' // see http://spec.commonmark.org/0.18/#example-527
+	precededByBackslash := offset >= 1 && data[offset-1] == '\\' && !isBackslashEscaped(data, offset-1) // see http://spec.commonmark.org/0.18/#example-527
 	precededByBackslash = precededByBackslash && p.flags&EXTENSION_BACKSLASH_LINE_BREAK != 0
 
 	if p.flags&EXTENSION_JOIN_LINES != 0 {
 		return 1
 	}
 
+	// a break at the end of the text would end its block, where it means
+	// nothing
+	if offset == len(data)-1 {
+		return 0
+	}
+
+	hardLineBreak := p.flags&EXTENSION_HARD_LINE_BREAK != 0 || (p.paragraphLineBreaks && p.inParagraph)
+
 	// should there be a hard line break here?
-	if p.flags&EXTENSION_HARD_LINE_BREAK == 0 && !precededByTwoSpaces && !precededByBackslash {
+	if !hardLineBreak && !precededByTwoSpaces && !precededByBackslash {
 		return 0
 	}
 
@@ -468,33 +559,51 @@ func link(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 			// create a new reference
 			noteId = len(p.notes) + 1
 
-			var fragment []byte
-			if len(id) > 0 {
-				if len(id) < 16 {
-					fragment = make([]byte, len(id))
-				} else {
-					fragment = make([]byte, 16)
-				}
-				copy(fragment, slugify(id))
-			} else {
+			// notes with no letters or digits have no slug, and are
+			// named by their number instead
+			fragment := slugify(id)
+			if len(fragment) > 16 {
+				fragment = fragment[:16]
+			} else if len(fragment) == 0 {
 				fragment = append([]byte("footnote-"), []byte(strconv.Itoa(noteId))...)
 			}
 
-			ref := &reference{
-				noteId:   noteId,
-				hasBlock: false,
-				link:     fragment,
-				title:    id,
-			}
+			// the same note given again is the same footnote; notes are
+			// told apart by their whole text, so notes that only share the
+			// start of it need their own fragments
+			ref, ok := p.inlineNotes[string(id)]
+			if !ok {
+				if p.notesRecord[string(fragment)] != nil {
+					fragment = append(fragment, '-')
+					fragment = append(fragment, strconv.Itoa(noteId)...)
+				}
 
-			p.notes = append(p.notes, ref)
-			p.notesRecord[string(ref.link)] = struct{}{}
+				// copy the text: the footnotes are rendered after the
+				// buffer holding it has been reused
+				ref = &reference{
+					noteId:   noteId,
+					hasBlock: false,
+					link:     fragment,
+					title:    append([]byte(nil), id...),
+				}
+
+				p.notes = append(p.notes, ref)
+				p.notesRecord[string(ref.link)] = ref
+				p.inlineNotes[string(ref.title)] = ref
+			}
 
 			link = ref.link
 			title = ref.title
+			noteId = ref.noteId
 		} else {
 			// find the reference with matching id
-			lr, ok := p.getRef(string(id))
+			var lr *reference
+			var ok bool
+			if t == linkDeferredFootnote {
+				lr, ok = p.getFootnoteRef(string(id))
+			} else {
+				lr, ok = p.getRef(string(id))
+			}
 			if !ok {
 				return 0
 			}
@@ -502,7 +611,7 @@ func link(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 			if t == linkDeferredFootnote && !p.isFootnote(lr) {
 				lr.noteId = len(p.notes) + 1
 				p.notes = append(p.notes, lr)
-				p.notesRecord[string(lr.link)] = struct{}{}
+				p.notesRecord[string(lr.link)] = lr
 			}
 
 			// keep link and title from reference
@@ -548,7 +657,15 @@ func link(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 	switch t {
 	case linkNormal:
 		if len(altContent) > 0 {
-			p.r.Link(out, uLink, title, altContent)
+			content.Reset()
+			content.Write(altContent)
+		}
+		// attributes after the link go to renderers that take them, and
+		// are dropped for the others
+		classes, attrs, n := p.linkAttributes(data[i:])
+		i += n
+		if r, ok := p.r.(LinkAttributesRenderer); ok && n > 0 {
+			r.AttributedLink(out, uLink, title, content.Bytes(), classes, attrs)
 		} else {
 			p.r.Link(out, uLink, title, content.Bytes())
 		}
@@ -581,6 +698,42 @@ func link(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 	return i
 }
 
+// '[[': parse a wiki link, as in [[Page Name]] or [[Page Name|text]]. The
+// link goes to the page name made into a slug with the header ID slugifier,
+// and its text is the page name or the text after the pipe. Anything else
+// starting with '[' is parsed as a regular link.
+func wikiLink(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	if p.insideLink || offset > 0 && data[offset-1] == '!' ||
+		len(data) < offset+2 || data[offset+1] != '[' {
+		return link(p, out, data, offset)
+	}
+	end := bytes.Index(data[offset:], []byte("]]"))
+	if end < 0 || bytes.IndexAny(data[offset+2:offset+end], "[\n") >= 0 {
+		return link(p, out, data, offset)
+	}
+
+	name, text := data[offset+2:offset+end], []byte(nil)
+	if bar := bytes.IndexByte(name, '|'); bar >= 0 {
+		name, text = name[:bar], bytes.TrimSpace(name[bar+1:])
+	}
+	name = bytes.TrimSpace(name)
+	if len(name) == 0 {
+		return link(p, out, data, offset)
+	}
+	if len(text) == 0 {
+		text = name
+	}
+	slug := p.headerIDSlugifier(string(name))
+	if slug == "" {
+		return link(p, out, data, offset)
+	}
+
+	var content bytes.Buffer
+	p.r.NormalText(&content, p.normalize(text))
+	p.r.Link(out, []byte(slug), nil, content.Bytes())
+	return end + 2
+}
+
 func (p *parser) inlineHTMLComment(out *bytes.Buffer, data []byte) int {
 	if len(data) < 5 {
 		return 0
@@ -630,7 +783,7 @@ func escape(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 	data = data[offset:]
 
 	if len(data) > 1 {
-		if bytes.IndexByte(escapeChars, data[1]) < 0 {
+		if bytes.IndexByte(escapeChars, data[1]) < 0 && !p.isExtensionEscape(data[1]) {
 			return 0
 		}
 
@@ -640,6 +793,158 @@ func escape(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 	return 2
 }
 
+// '^': parse a superscript, as in x^2^
+func superscript(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	data = data[offset:]
+
+	// ^[text] is an inline footnote
+	if p.flags&EXTENSION_FOOTNOTES != 0 && len(data) > 1 && data[1] == '[' {
+		return 0
+	}
+
+	// without support in the renderer it stays normal text
+	r, ok := p.r.(ScriptRenderer)
+	if !ok {
+		return 0
+	}
+
+	end := scriptEnd(data)
+	if end == 0 {
+		return 0
+	}
+
+	var work bytes.Buffer
+	p.inline(&work, unescapeSpaces(data[1:end]))
+	r.Superscript(out, work.Bytes())
+	return end + 1
+}
+
+// '~': parse a subscript, as in H~2~O. A single tilde opens a subscript; two
+// are strikethrough, if that is enabled, and tildes that follow another tilde
+// never open a subscript, so ~~~ and longer runs are left alone.
+func subscript(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	if offset > 0 && data[offset-1] == '~' {
+		return 0
+	}
+	if len(data) > offset+1 && data[offset+1] == '~' {
+		if p.flags&EXTENSION_STRIKETHROUGH != 0 {
+			return emphasis(p, out, data, offset)
+		}
+		return 0
+	}
+
+	r, ok := p.r.(ScriptRenderer)
+	if !ok {
+		return 0
+	}
+
+	data = data[offset:]
+	end := scriptEnd(data)
+	if end == 0 {
+		return 0
+	}
+
+	var work bytes.Buffer
+	p.inline(&work, unescapeSpaces(data[1:end]))
+	r.Subscript(out, work.Bytes())
+	return end + 1
+}
+
+// scriptEnd returns the position of the delimiter that closes the superscript
+// or subscript opened by data[0], or 0 if it isn't closed on the same line or
+// is empty. Spaces must be escaped, except inside parentheses, as in
+// 2^(n + 1)^.
+func scriptEnd(data []byte) int {
+	depth := 0
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ' ', '\t':
+			if depth == 0 {
+				return 0
+			}
+		case '\n':
+			return 0
+		case data[0]:
+			if i == 1 {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
+
+// unescapeSpaces replaces escaped spaces with plain ones
+func unescapeSpaces(data []byte) []byte {
+	if bytes.Index(data, []byte("\\ ")) < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\\' && i+1 < len(data) && data[i+1] == ' ' && !isBackslashEscaped(data, i) {
+			continue
+		}
+		out = append(out, data[i])
+	}
+	return out
+}
+
+// isExtensionEscape reports whether c can be escaped because an extension
+// makes it special.
+func (p *parser) isExtensionEscape(c byte) bool {
+	switch c {
+	case '^':
+		return p.flags&EXTENSION_SUPERSCRIPT != 0
+	case '=':
+		return p.flags&EXTENSION_HIGHLIGHT != 0
+	case '$':
+		return p.flags&EXTENSION_MATH != 0
+	}
+	return false
+}
+
+// '$': parse inline math, $a+b$. As in pandoc, the opening $ must be followed
+// by a non-space and the closing $ must be preceded by a non-space and not
+// followed by a digit, so prices like $5 and $10 are left alone. Runs of
+// dollar signs are not delimiters.
+func inlineMath(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	if offset > 0 && data[offset-1] == '$' {
+		return 0
+	}
+	data = data[offset:]
+	if len(data) < 3 || data[1] == '$' || isspace(data[1]) {
+		return 0
+	}
+
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '\n':
+			return 0
+		case '$':
+			if isspace(data[i-1]) || (i+1 < len(data) && (data[i+1] == '$' || isdigit(data[i+1]))) {
+				continue
+			}
+			if r, ok := p.r.(MathRenderer); ok {
+				r.Math(out, data[1:i])
+			} else {
+				p.r.CodeSpan(out, data[1:i])
+			}
+			return i + 1
+		}
+	}
+	return 0
+}
+
 func unescapeText(ob *bytes.Buffer, src []byte) {
 	i := 0
 	for i < len(src) {
@@ -693,6 +998,12 @@ func linkEndsWithEntity(data []byte, linkEnd int) bool {
 }
 
 func autoLink(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	if len(p.autolinkSchemes) > 0 && !p.insideLink {
+		if n := schemeAutoLink(p, out, data, offset); n > 0 {
+			return n
+		}
+	}
+
 	// quick check to rule out most false hits on ':'
 	if p.insideLink || len(data) < offset+3 || data[offset+1] != '/' || data[offset+2] != '/' {
 		return 0
@@ -817,6 +1128,142 @@ func autoLink(p *parser, out *bytes.Buffer, data []byte, offset int) int {
 	return linkEnd - rewind
 }
 
+// 'w': parse a host name starting with www. as a link, as in www.example.com.
+// The link goes to http:// and the host name as typed; trailing punctuation
+// and unbalanced closing parentheses are not part of it.
+func wwwAutoLink(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	if p.insideLink {
+		return 0
+	}
+	if offset > 0 && !isspace(data[offset-1]) && bytes.IndexByte([]byte("*_~(\"'"), data[offset-1]) < 0 {
+		return 0
+	}
+	data = data[offset:]
+	if len(data) < 5 || !bytes.EqualFold(data[:4], []byte("www.")) || !isalnum(data[4]) {
+		return 0
+	}
+
+	linkEnd := bareLinkEnd(data, 4)
+
+	var content bytes.Buffer
+	p.r.NormalText(&content, data[:linkEnd])
+
+	var link bytes.Buffer
+	link.WriteString("http://")
+	unescapeText(&link, data[:linkEnd])
+
+	p.r.Link(out, link.Bytes(), nil, content.Bytes())
+	return linkEnd
+}
+
+// parse a link with one of the extra schemes of Options.AutolinkSchemes,
+// triggered on the ':' after the scheme
+func schemeAutoLink(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	if offset+1 >= len(data) || isEndOfLink(data[offset+1]) {
+		return 0
+	}
+
+	// scan backward for the scheme, which must start a word
+	rewind := 0
+	for offset-rewind > 0 && rewind <= 32 && isSchemeChar(data[offset-rewind-1]) {
+		rewind++
+	}
+	if rewind == 0 || offset-rewind > 0 && (isalnum(data[offset-rewind-1]) || data[offset-rewind-1] == '_') {
+		return 0
+	}
+	scheme := data[offset-rewind : offset]
+	found := false
+	for _, s := range p.autolinkSchemes {
+		if bytes.EqualFold(scheme, []byte(s)) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0
+	}
+
+	data = data[offset-rewind:]
+	linkEnd := bareLinkEnd(data, rewind+1)
+	if linkEnd <= rewind+1 {
+		return 0
+	}
+
+	// we were triggered on the ':', so we need to rewind the output a bit
+	if out.Len() >= rewind {
+		out.Truncate(out.Len() - rewind)
+	}
+
+	var uLink bytes.Buffer
+	unescapeText(&uLink, data[:linkEnd])
+	p.r.AutoLink(out, uLink.Bytes(), LINK_TYPE_NORMAL)
+
+	return linkEnd - rewind
+}
+
+func isSchemeChar(c byte) bool {
+	return isalnum(c) || c == '+' || c == '-' || c == '.'
+}
+
+// returns the end of a link without delimiters that starts at data[0], with
+// at least start bytes: the link runs to the next space, but punctuation and
+// unbalanced closing parentheses at its end belong to the text around it
+func bareLinkEnd(data []byte, start int) int {
+	end := start
+	for end < len(data) && !isEndOfLink(data[end]) {
+		end++
+	}
+	for end > start {
+		c := data[end-1]
+		if bytes.IndexByte([]byte(".,:;!?\"'*_~"), c) < 0 &&
+			(c != ')' || bytes.Count(data[:end], []byte("(")) >= bytes.Count(data[:end], []byte(")"))) {
+			break
+		}
+		end--
+	}
+	return end
+}
+
+// '@': parse a mention, as in @username, and link it to p.mentionURL.
+func mention(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	return nameLink(p, out, data, offset, p.mentionURL)
+}
+
+// '#': parse a hashtag, as in #tag, and link it to p.hashtagURL.
+func hashtag(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+	return nameLink(p, out, data, offset, p.hashtagURL)
+}
+
+// nameLink parses the name after a @ or # trigger, made of letters, digits,
+// underscores and dashes, and links it to url(name). The trigger has to start
+// a word, so the @ of an email address is left alone, and a name of only
+// digits, as in issue #12, is not linked.
+func nameLink(p *parser, out *bytes.Buffer, data []byte, offset int, url func(string) string) int {
+	if p.insideLink {
+		return 0
+	}
+	if offset > 0 && !isspace(data[offset-1]) && bytes.IndexByte([]byte("*_~(\"'"), data[offset-1]) < 0 {
+		return 0
+	}
+	data = data[offset:]
+
+	end, letters := 1, 0
+	for end < len(data) && (isalnum(data[end]) || data[end] == '_' || data[end] == '-') {
+		if !isdigit(data[end]) {
+			letters++
+		}
+		end++
+	}
+	if letters == 0 || end < len(data) && (data[end] == '@' || data[end] == '#') {
+		return 0
+	}
+
+	var content bytes.Buffer
+	p.r.NormalText(&content, data[:end])
+	p.r.Link(out, []byte(url(string(data[1:end]))), nil, content.Bytes())
+	return end
+}
+
 func isEndOfLink(char byte) bool {
 	return isspace(char) || char == '<'
 }
@@ -960,6 +1407,90 @@ func isMailtoAutoLink(data []byte) int {
 	return 0
 }
 
+// linkAttributes parses the {key=value .class} attribute block that may
+// follow a link, returning its classes, its other attributes and its
+// length. A brace that does not start a valid, non-empty block is left alone.
+func (p *parser) linkAttributes(data []byte) ([]string, map[string]string, int) {
+	if !p.linkAttrs || len(data) == 0 || data[0] != '{' {
+		return nil, nil, 0
+	}
+	end := bytes.IndexAny(data, "}\n")
+	if end < 0 || data[end] != '}' {
+		return nil, nil, 0
+	}
+	classes, attrs, ok := parseAttributes(string(data[:end+1]))
+	if !ok || len(classes)+len(attrs) == 0 {
+		return nil, nil, 0
+	}
+	return classes, attrs, end + 1
+}
+
+// parseAttributes parses an attribute block, like {.button target=_blank},
+// as found after a link or after the language of a fenced code block:
+// classes start with a dot, and other attributes are key=value pairs whose
+// values may be quoted. ok is false if s is not in that form, in which case
+// it should be treated as opaque.
+func parseAttributes(s string) (classes []string, attrs map[string]string, ok bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, nil, false
+	}
+	s = s[1 : len(s)-1]
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return classes, attrs, true
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		if s[0] == '.' {
+			if end == 1 {
+				return nil, nil, false
+			}
+			classes = append(classes, s[1:end])
+			s = s[end:]
+			continue
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || eq > end || !isAttributeName(s[:eq]) {
+			return nil, nil, false
+		}
+		key, value := s[:eq], ""
+		s = s[eq+1:]
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			closing := strings.IndexByte(s[1:], s[0])
+			if closing < 0 {
+				return nil, nil, false
+			}
+			value = s[1 : closing+1]
+			s = s[closing+2:]
+		} else {
+			end = strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[key] = value
+	}
+}
+
+func isAttributeName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !isalnum(name[i]) && name[i] != '-' && name[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
 // look for the next emph char, skipping other constructs
 func helperFindEmphChar(data []byte, c byte) int {
 	i := 0
@@ -1035,42 +1566,84 @@ func helperFindEmphChar(data []byte, c byte) int {
 	return 0
 }
 
-func helperEmphasis(p *parser, out *bytes.Buffer, data []byte, c byte) int {
-	i := 0
-
-	// skip one symbol if coming from emph3
-	if len(data) > 1 && data[0] == c && data[1] == c {
-		i = 1
+// emphRun returns the length of the run of c starting at data[i]
+func emphRun(data []byte, i int, c byte) int {
+	run := 0
+	for i+run < len(data) && data[i+run] == c {
+		run++
 	}
+	return run
+}
 
+// helperFindDoubleClose returns the offset and length of the run of c that
+// closes a double emphasis opened just before data, or 0 if there is none
+func helperFindDoubleClose(data []byte, c byte) (int, int) {
+	i := 0
 	for i < len(data) {
-		length := helperFindEmphChar(data[i:], c)
-		if length == 0 {
-			return 0
+		if data[i] != c {
+			length := helperFindEmphChar(data[i:], c)
+			if length == 0 {
+				return 0, 0
+			}
+			i += length
 		}
-		i += length
-		if i >= len(data) {
-			return 0
+
+		run := emphRun(data, i, c)
+		if run >= 2 && i > 0 && !isspace(data[i-1]) {
+			return i, run
 		}
+		i += run
+	}
+	return 0, 0
+}
 
-		if i+1 < len(data) && data[i+1] == c {
-			i++
-			continue
+func helperEmphasis(p *parser, out *bytes.Buffer, data []byte, c byte) int {
+	i := 0
+
+	for i < len(data) {
+		if data[i] != c {
+			length := helperFindEmphChar(data[i:], c)
+			if length == 0 {
+				return 0
+			}
+			i += length
 		}
 
-		if data[i] == c && !isspace(data[i-1]) {
+		run := emphRun(data, i, c)
+		end := i + run - 1
 
-			if p.flags&EXTENSION_NO_INTRA_EMPHASIS != 0 {
-				if !(i+1 == len(data) || isspace(data[i+1]) || ispunct(data[i+1])) {
-					continue
-				}
+		if i == 0 || isspace(data[i-1]) {
+			// only an inner double emphasis may open here; skip past its
+			// closer, the last symbol of which may close this emphasis
+			if run != 2 {
+				return 0
+			}
+			j, closeRun := helperFindDoubleClose(data[i+2:], c)
+			if j == 0 {
+				return 0
+			}
+			i += 2 + j
+			if closeRun < 3 {
+				i += closeRun
+				continue
 			}
+			end = i + closeRun - 1
+		} else if run == 2 {
+			i += run
+			continue
+		}
 
-			var work bytes.Buffer
-			p.inline(&work, data[:i])
-			p.r.Emphasis(out, work.Bytes())
-			return i + 1
+		if p.flags&EXTENSION_NO_INTRA_EMPHASIS != 0 {
+			if !(end+1 == len(data) || isspace(data[end+1]) || ispunct(data[end+1])) {
+				i = end + 1
+				continue
+			}
 		}
+
+		var work bytes.Buffer
+		p.inline(&work, data[:end])
+		p.r.Emphasis(out, work.Bytes())
+		return end + 1
 	}
 
 	return 0
@@ -1080,27 +1653,41 @@ func helperDoubleEmphasis(p *parser, out *bytes.Buffer, data []byte, c byte) int
 	i := 0
 
 	for i < len(data) {
-		length := helperFindEmphChar(data[i:], c)
-		if length == 0 {
-			return 0
+		if data[i] != c {
+			length := helperFindEmphChar(data[i:], c)
+			if length == 0 {
+				return 0
+			}
+			i += length
 		}
-		i += length
 
-		if i+1 < len(data) && data[i] == c && data[i+1] == c && i > 0 && !isspace(data[i-1]) {
-			var work bytes.Buffer
-			p.inline(&work, data[:i])
+		run := emphRun(data, i, c)
+		if run < 2 || i == 0 || isspace(data[i-1]) {
+			i += run
+			continue
+		}
 
-			if work.Len() > 0 {
-				// pick the right renderer
-				if c == '~' {
-					p.r.StrikeThrough(out, work.Bytes())
-				} else {
-					p.r.DoubleEmphasis(out, work.Bytes())
-				}
+		// the last two symbols of a run of three close this emphasis,
+		// after the inner emphasis the first one closes
+		if run == 3 && (c == '*' || c == '_') {
+			i++
+		}
+
+		var work bytes.Buffer
+		p.inline(&work, data[:i])
+
+		if work.Len() > 0 {
+			// pick the right renderer
+			switch c {
+			case '~':
+				p.r.StrikeThrough(out, work.Bytes())
+			case '=':
+				p.r.(HighlightRenderer).Highlight(out, work.Bytes())
+			default:
+				p.r.DoubleEmphasis(out, work.Bytes())
 			}
-			return i + 2
 		}
-		i++
+		return i + 2
 	}
 	return 0
 }
@@ -1118,12 +1705,14 @@ func helperTripleEmphasis(p *parser, out *bytes.Buffer, data []byte, offset int,
 		i += length
 
 		// skip whitespace preceded symbols
-		if data[i] != c || isspace(data[i-1]) {
+		run := emphRun(data, i, c)
+		if isspace(data[i-1]) {
+			i += run
 			continue
 		}
 
 		switch {
-		case i+2 < len(data) && data[i+1] == c && data[i+2] == c:
+		case run >= 3:
 			// triple symbol found
 			var work bytes.Buffer
 
@@ -1132,7 +1721,7 @@ func helperTripleEmphasis(p *parser, out *bytes.Buffer, data []byte, offset int,
 				p.r.TripleEmphasis(out, work.Bytes())
 			}
 			return i + 3
-		case (i+1 < len(data) && data[i+1] == c):
+		case run == 2:
 			// double symbol found, hand over to emph1
 			length = helperEmphasis(p, out, origData[offset-2:], c)
 			if length == 0 {
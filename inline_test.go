@@ -14,6 +14,8 @@
 package blackfriday
 
 import (
+	"bytes"
+	"fmt"
 	"regexp"
 	"strings"
 	"testing"
@@ -327,6 +329,55 @@ func TestEmphasisMix(t *testing.T) {
 	doTestsInline(t, tests)
 }
 
+func TestNestedEmphasis(t *testing.T) {
+	var tests = []string{
+		"***x***\n",
+		"<p><strong><em>x</em></strong></p>\n",
+
+		"*a **b** c*\n",
+		"<p><em>a <strong>b</strong> c</em></p>\n",
+
+		"**a *b* c**\n",
+		"<p><strong>a <em>b</em> c</strong></p>\n",
+
+		"***a** b*\n",
+		"<p><em><strong>a</strong> b</em></p>\n",
+
+		"***a* b**\n",
+		"<p><strong><em>a</em> b</strong></p>\n",
+
+		"*a **b***\n",
+		"<p><em>a <strong>b</strong></em></p>\n",
+
+		"**a *b***\n",
+		"<p><strong>a <em>b</em></strong></p>\n",
+
+		"_a __b__ c_\n",
+		"<p><em>a <strong>b</strong> c</em></p>\n",
+
+		"*foo**bar*\n",
+		"<p><em>foo**bar</em></p>\n",
+
+		"*a `**` b*\n",
+		"<p><em>a <code>**</code> b</em></p>\n",
+	}
+	doTestsInline(t, tests)
+
+	tests = []string{
+		"foo_bar_baz\n",
+		"<p>foo_bar_baz</p>\n",
+
+		"foo _bar_ baz\n",
+		"<p>foo <em>bar</em> baz</p>\n",
+
+		"*a **b** c*\n",
+		"<p><em>a <strong>b</strong> c</em></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{
+		Extensions: EXTENSION_NO_INTRA_EMPHASIS},
+		0, HtmlRendererParameters{})
+}
+
 func TestEmphasisLink(t *testing.T) {
 	var tests = []string{
 		"[first](before) *text[second] (inside)text* [third](after)\n",
@@ -369,8 +420,339 @@ func TestStrikeThrough(t *testing.T) {
 
 		"odd ~~number\nof~~ markers~~ here\n",
 		"<p>odd <del>number\nof</del> markers~~ here</p>\n",
+
+		"single ~tilde~ and a~b~c\n",
+		"<p>single ~tilde~ and a~b~c</p>\n",
+
+		"~~~x~~~ and ~~~~x~~~~\n",
+		"<p>~~~x~~~ and ~~~~x~~~~</p>\n",
+
+		"a ~~~x~~~ b ~~del~~\n",
+		"<p>a ~~~x~~~ b <del>del</del></p>\n",
 	}
 	doTestsInline(t, tests)
+
+	// the same with subscript, apart from the single tildes
+	tests = []string{
+		"single ~tilde~ and ~~del~~\n",
+		"<p>single <sub>tilde</sub> and <del>del</del></p>\n",
+
+		"~~~x~~~ and ~~~~x~~~~\n",
+		"<p>~~~x~~~ and ~~~~x~~~~</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_SUBSCRIPT}, 0, HtmlRendererParameters{})
+}
+
+// basicRenderer hides the optional methods of the renderer it wraps, so only
+// the Renderer interface is left
+type basicRenderer struct {
+	Renderer
+}
+
+func runBasicRenderer(input string, extensions int) string {
+	renderer := basicRenderer{HtmlRenderer(HTML_USE_XHTML, "", "")}
+	return string(Markdown([]byte(input), renderer, extensions))
+}
+
+func TestSuperscript(t *testing.T) {
+	var tests = []string{
+		"x^2^ and E=mc^2^\n",
+		"<p>x<sup>2</sup> and E=mc<sup>2</sup></p>\n",
+
+		"2^(n + 1)^ and a^b\\ c^\n",
+		"<p>2<sup>(n + 1)</sup> and a<sup>b c</sup></p>\n",
+
+		"a^*em*^\n",
+		"<p>a<sup><em>em</em></sup></p>\n",
+
+		"a lone ^ caret, a ^b c^ and ^^\n",
+		"<p>a lone ^ caret, a ^b c^ and ^^</p>\n",
+
+		"unclosed x^2\n",
+		"<p>unclosed x^2</p>\n",
+
+		"escaped x\\^2^\n",
+		"<p>escaped x^2^</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_SUPERSCRIPT}, 0, HtmlRendererParameters{})
+
+	// footnotes still work
+	tests = []string{
+		"x^2^ and a note^[inline] and [^1]\n\n[^1]: deferred\n",
+		"<p>x<sup>2</sup> and a note<sup class=\"footnote-ref\" id=\"fnref:inline\"><a href=\"#fn:inline\">1</a></sup>" +
+			" and <sup class=\"footnote-ref\" id=\"fnref:1\"><a href=\"#fn:1\">2</a></sup></p>\n" +
+			"<div class=\"footnotes\">\n\n<hr />\n\n<ol>\n<li id=\"fn:inline\">inline</li>\n<li id=\"fn:1\">deferred\n</li>\n</ol>\n</div>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_SUPERSCRIPT | EXTENSION_FOOTNOTES}, 0, HtmlRendererParameters{})
+
+	// off by default
+	doTestsInline(t, []string{"x^2^\n", "<p>x^2^</p>\n"})
+
+	// left alone for renderers without superscripts
+	doTestsBlockWithRunner(t, []string{"x^2^ and H~2~O\n", "<p>x^2^ and H~2~O</p>\n"},
+		EXTENSION_SUPERSCRIPT|EXTENSION_SUBSCRIPT, runBasicRenderer)
+}
+
+func TestSubscript(t *testing.T) {
+	var tests = []string{
+		"H~2~O and CO~2~\n",
+		"<p>H<sub>2</sub>O and CO<sub>2</sub></p>\n",
+
+		"x~(i + 1)~ and x~a\\ b~\n",
+		"<p>x<sub>(i + 1)</sub> and x<sub>a b</sub></p>\n",
+
+		"~~del~~ and H~2~O\n",
+		"<p><del>del</del> and H<sub>2</sub>O</p>\n",
+
+		"~~~ and ~~~x~~~\n",
+		"<p>~~~ and ~~~x~~~</p>\n",
+
+		"a lone ~ tilde and ~a b~\n",
+		"<p>a lone ~ tilde and ~a b~</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_SUBSCRIPT}, 0, HtmlRendererParameters{})
+}
+
+func TestHighlight(t *testing.T) {
+	var tests = []string{
+		"==a==\n",
+		"<p><mark>a</mark></p>\n",
+
+		"an ==important **bold**== word\n",
+		"<p>an <mark>important <strong>bold</strong></mark> word</p>\n",
+
+		"==**bold**==\n",
+		"<p><mark><strong>bold</strong></mark></p>\n",
+
+		"a == b and a = b and =single=\n",
+		"<p>a == b and a = b and =single=</p>\n",
+
+		"unterminated ==mark\n",
+		"<p>unterminated ==mark</p>\n",
+
+		"\\==escaped==\n",
+		"<p>==escaped==</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_HIGHLIGHT}, 0, HtmlRendererParameters{})
+
+	// off by default
+	doTestsInline(t, []string{"==a==\n", "<p>==a==</p>\n"})
+
+	// left alone for renderers without highlights
+	tests = []string{
+		"an ==important **bold**== word\n",
+		"<p>an ==important <strong>bold</strong>== word</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, EXTENSION_HIGHLIGHT, runBasicRenderer)
+}
+
+func TestInlineMath(t *testing.T) {
+	var tests = []string{
+		"$a+b$\n",
+		"<p><span class=\"math inline\">a+b</span></p>\n",
+
+		"where $x < y$ and $\\$$\n",
+		"<p>where <span class=\"math inline\">x &lt; y</span> and <span class=\"math inline\">\\$</span></p>\n",
+
+		"it costs $5 and $10\n",
+		"<p>it costs $5 and $10</p>\n",
+
+		"$5, $10 and $20\n",
+		"<p>$5, $10 and $20</p>\n",
+
+		"no $ space$ and $across\nlines$\n",
+		"<p>no $ space$ and $across\nlines$</p>\n",
+
+		"\\$a$\n",
+		"<p>$a$</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_MATH}, 0, HtmlRendererParameters{})
+}
+
+func TestEmoji(t *testing.T) {
+	var tests = []string{
+		"ship it :rocket: :+1:\n",
+		"<p>ship it \U0001F680 \U0001F44D</p>\n",
+
+		"a :notareal: code\n",
+		"<p>a :notareal: code</p>\n",
+
+		"a lone : colon, :: and :: here, and a :smile\n",
+		"<p>a lone : colon, :: and :: here, and a :smile</p>\n",
+
+		"see http://example.com:8080/a:b:c\n",
+		"<p>see <a href=\"http://example.com:8080/a:b:c\">http://example.com:8080/a:b:c</a></p>\n",
+
+		"`:smile:` stays in code\n",
+		"<p><code>:smile:</code> stays in code</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_EMOJI}, 0, HtmlRendererParameters{})
+
+	// custom shortcodes
+	tests = []string{
+		":gopher: :smile: :rocket:\n",
+		"<p>G :) \U0001F680</p>\n",
+	}
+	opts := Options{
+		Extensions: EXTENSION_EMOJI,
+		Emoji:      map[string]string{"gopher": "G", "smile": ":)"},
+	}
+	doTestsInlineParam(t, tests, opts, 0, HtmlRendererParameters{})
+}
+
+func TestAutolinkSchemes(t *testing.T) {
+	var tests = []string{
+		"call tel:+15551234.\n",
+		"<p>call <a href=\"tel:+15551234\">tel:+15551234</a>.</p>\n",
+
+		"open MyApp:settings/profile or sms:123\n",
+		"<p>open <a href=\"MyApp:settings/profile\">MyApp:settings/profile</a> or <a href=\"sms:123\">sms:123</a></p>\n",
+
+		"hotel:5 and xtel:123 and tel: alone\n",
+		"<p>hotel:5 and xtel:123 and tel: alone</p>\n",
+
+		"other:thing and http://example.com\n",
+		"<p>other:thing and <a href=\"http://example.com\">http://example.com</a></p>\n",
+
+		"[tel:123](/x)\n",
+		"<p><a href=\"/x\">tel:123</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{AutolinkSchemes: []string{"tel", "sms", "myapp"}}, 0, HtmlRendererParameters{})
+}
+
+func TestAutolinkWWW(t *testing.T) {
+	var tests = []string{
+		"visit www.example.com today\n",
+		"<p>visit <a href=\"http://www.example.com\">www.example.com</a> today</p>\n",
+
+		"visit www.example.com/a?b=c.\n",
+		"<p>visit <a href=\"http://www.example.com/a?b=c\">www.example.com/a?b=c</a>.</p>\n",
+
+		"(see WWW.Example.com/Foo_(bar)), wwwfoo and www.\n",
+		"<p>(see <a href=\"http://WWW.Example.com/Foo_(bar)\">WWW.Example.com/Foo_(bar)</a>), wwwfoo and www.</p>\n",
+
+		"http://www.example.com and awww.example.com\n",
+		"<p><a href=\"http://www.example.com\">http://www.example.com</a> and awww.example.com</p>\n",
+
+		"[www.example.com](/x)\n",
+		"<p><a href=\"/x\">www.example.com</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_AUTOLINK_WWW}, 0, HtmlRendererParameters{})
+
+	// off by default
+	doTestsInline(t, []string{"www.example.com\n", "<p>www.example.com</p>\n"})
+}
+
+func TestWikiLinks(t *testing.T) {
+	var tests = []string{
+		"see [[Page Name]] first\n",
+		"<p>see <a href=\"page-name\">Page Name</a> first</p>\n",
+
+		"see [[Page Name|the page]] first\n",
+		"<p>see <a href=\"page-name\">the page</a> first</p>\n",
+
+		"[[ Tom & Jerry | T&J ]]\n",
+		"<p><a href=\"tom-jerry\">T&amp;J</a></p>\n",
+
+		"[a link](/x) and [[Page]]\n",
+		"<p><a href=\"/x\">a link</a> and <a href=\"page\">Page</a></p>\n",
+
+		"[[|text]] and [[]]\n",
+		"<p>[[|text]] and [[]]</p>\n",
+
+		"[[unclosed\n",
+		"<p>[[unclosed</p>\n",
+
+		"![alt](/img.png)\n",
+		"<p><img src=\"/img.png\" alt=\"alt\" /></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_WIKI_LINKS}, HTML_USE_XHTML, HtmlRendererParameters{})
+
+	slug := func(text string) string { return "/wiki/" + strings.Replace(text, " ", "_", -1) }
+	tests = []string{
+		"[[Page Name]]\n",
+		"<p><a href=\"/wiki/Page_Name\">Page Name</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_WIKI_LINKS, HeaderIDSlugifier: slug}, 0, HtmlRendererParameters{})
+
+	// off by default
+	doTestsInline(t, []string{"[[Page Name]]\n", "<p>[[Page Name]]</p>\n"})
+}
+
+func TestMentionsAndHashtags(t *testing.T) {
+	var tests = []string{
+		"@alice said hi\n",
+		"<p><a href=\"/alice\">@alice</a> said hi</p>\n",
+
+		"thanks to @bob_smith-2, and (@carol)!\n",
+		"<p>thanks to <a href=\"/bob_smith-2\">@bob_smith-2</a>, and (<a href=\"/carol\">@carol</a>)!</p>\n",
+
+		"mail bob@example.com or @ alone\n",
+		"<p>mail bob@example.com or @ alone</p>\n",
+
+		"code `@alice #go` stays\n",
+		"<p>code <code>@alice #go</code> stays</p>\n",
+
+		"posted in #golang, see issue #12 and a#b\n",
+		"<p>posted in <a href=\"/tags/golang\">#golang</a>, see issue #12 and a#b</p>\n",
+
+		"[@alice](/x) and &#35;tag\n",
+		"<p><a href=\"/x\">@alice</a> and &#35;tag</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_MENTIONS | EXTENSION_HASHTAGS}, 0, HtmlRendererParameters{})
+
+	opts := Options{
+		Extensions: EXTENSION_MENTIONS | EXTENSION_HASHTAGS,
+		MentionURL: func(name string) string { return "https://example.com/u/" + name },
+		HashtagURL: func(tag string) string { return "/search?q=%23" + tag },
+	}
+	tests = []string{
+		"@alice likes #go\n",
+		"<p><a href=\"https://example.com/u/alice\">@alice</a> likes <a href=\"/search?q=%23go\">#go</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, opts, 0, HtmlRendererParameters{})
+
+	// each extension on its own, and off by default
+	doTestsInlineParam(t, []string{"@alice #go\n", "<p><a href=\"/alice\">@alice</a> #go</p>\n"},
+		Options{Extensions: EXTENSION_MENTIONS}, 0, HtmlRendererParameters{})
+	doTestsInline(t, []string{"@alice #go\n", "<p>@alice #go</p>\n"})
+}
+
+func TestAbbreviations(t *testing.T) {
+	var tests = []string{
+		"The HTML spec, and more HTML.\n\n*[HTML]: HyperText Markup Language\n",
+		"<p>The <abbr title=\"HyperText Markup Language\">HTML</abbr> spec, " +
+			"and more <abbr title=\"HyperText Markup Language\">HTML</abbr>.</p>\n",
+
+		"The W3C writes specs.\n\n*[HTML]: HyperText Markup Language\n",
+		"<p>The W3C writes specs.</p>\n",
+
+		"*[HTML]: HyperText Markup Language\n\nHTML5, XHTML, html and `HTML` stay, *HTML* does not.\n",
+		"<p>HTML5, XHTML, html and <code>HTML</code> stay, " +
+			"<em><abbr title=\"HyperText Markup Language\">HTML</abbr></em> does not.</p>\n",
+
+		"Markdown Extra and Markdown.\n\n*[Markdown]: a markup language\n*[Markdown Extra]: an extension of \"Markdown\"\n",
+		"<p><abbr title=\"an extension of &quot;Markdown&quot;\">Markdown Extra</abbr> " +
+			"and <abbr title=\"a markup language\">Markdown</abbr>.</p>\n",
+
+		"Not a *[HTML] definition\n",
+		"<p>Not a *[HTML] definition</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Abbreviations: true}, 0, HtmlRendererParameters{})
+
+	// definitions are left alone without the option
+	doTestsInline(t, []string{"*[HTML]: HyperText Markup Language\n",
+		"<p>*[HTML]: HyperText Markup Language</p>\n"})
+
+	// the definitions are still removed for renderers without abbreviations
+	tests = []string{
+		"The HTML spec.\n\n*[HTML]: HyperText Markup Language\n",
+		"<p>The HTML spec.</p>\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		renderer := basicRenderer{HtmlRenderer(HTML_USE_XHTML, "", "")}
+		return string(MarkdownOptions([]byte(input), renderer, Options{Abbreviations: true}))
+	})
 }
 
 func TestCodeSpan(t *testing.T) {
@@ -411,6 +793,41 @@ func TestCodeSpan(t *testing.T) {
 	doTestsInline(t, tests)
 }
 
+func TestCodeSpanLanguage(t *testing.T) {
+	var tests = []string{
+		"`fmt.Println()`{.go}\n",
+		"<p><code class=\"language-go\">fmt.Println()</code></p>\n",
+
+		"call ``a `b` c``{.c++} here\n",
+		"<p>call <code class=\"language-c++\">a `b` c</code> here</p>\n",
+
+		"`plain code`\n",
+		"<p><code>plain code</code></p>\n",
+
+		"`a | b`\n",
+		"<p><code>a | b</code></p>\n",
+
+		"`go|fmt.Println()`\n",
+		"<p><code>go|fmt.Println()</code></p>\n",
+
+		"`code`{not a language}\n",
+		"<p><code>code</code>{not a language}</p>\n",
+
+		"`code`{.}\n",
+		"<p><code>code</code>{.}</p>\n",
+
+		"`code` {.go}\n",
+		"<p><code>code</code> {.go}</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{CodeSpanLanguages: true}, 0, HtmlRendererParameters{})
+
+	// without the option the attribute is just text
+	doTestsInline(t, []string{
+		"`fmt.Println()`{.go}\n",
+		"<p><code>fmt.Println()</code>{.go}</p>\n",
+	})
+}
+
 func TestLineBreak(t *testing.T) {
 	var tests = []string{
 		"this line  \nhas a break\n",
@@ -445,10 +862,125 @@ func TestLineBreak(t *testing.T) {
 
 		"this has an   \nextra space\n",
 		"<p>this has an<br />\nextra space</p>\n",
+
+		"an escaped backslash\\\\\ndoes not\n",
+		"<p>an escaped backslash\\\ndoes not</p>\n",
+
+		"* a list item\\\n",
+		"<ul>\n<li>a list item\\</li>\n</ul>\n",
 	}
 	doTestsInlineParam(t, tests, Options{
 		Extensions: EXTENSION_BACKSLASH_LINE_BREAK},
 		0, HtmlRendererParameters{})
+
+	// breaks at the end of a block mean nothing
+	tests = []string{
+		"the end  \n",
+		"<p>the end</p>\n",
+
+		"* item  \n  text  \n* next\n",
+		"<ul>\n<li>item<br />\ntext</li>\n<li>next</li>\n</ul>\n",
+
+		"# header  \n",
+		"<h1>header</h1>\n",
+	}
+	doTestsInline(t, tests)
+
+	tests = []string{
+		"every\nline\n",
+		"<p>every<br />\nline</p>\n",
+
+		"* tight\n  item\n* next\n",
+		"<ul>\n<li>tight<br />\nitem</li>\n<li>next</li>\n</ul>\n",
+	}
+	doTestsInlineParam(t, tests, Options{
+		Extensions: EXTENSION_HARD_LINE_BREAK},
+		0, HtmlRendererParameters{})
+}
+
+func TestParagraphLineBreaks(t *testing.T) {
+	var tests = []string{
+		"hard-wrapped\nparagraph text\n",
+		"<p>hard-wrapped<br />\nparagraph text</p>\n",
+
+		"> quoted\n> lines\n",
+		"<blockquote>\n<p>quoted<br />\nlines</p>\n</blockquote>\n",
+
+		"* tight list\n  item\n",
+		"<ul>\n<li>tight list\nitem</li>\n</ul>\n",
+
+		"Header\nline\n===\n",
+		"<p>Header</p>\n\n<h1>line</h1>\n",
+	}
+	doTestsInlineParam(t, tests, Options{ParagraphLineBreaks: true}, 0, HtmlRendererParameters{})
+
+	// by default the lines are joined
+	tests = []string{
+		"hard-wrapped\nparagraph text\n",
+		"<p>hard-wrapped\nparagraph text</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{}, 0, HtmlRendererParameters{})
+}
+
+func TestSoftBreaks(t *testing.T) {
+	input := "first line\nsecond line\n"
+
+	// soft breaks are kept as newlines, joined away or made hard
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "<p>first line\nsecond line</p>\n"},
+		{Options{Extensions: EXTENSION_JOIN_LINES}, "<p>first linesecond line</p>\n"},
+		{Options{Extensions: EXTENSION_HARD_LINE_BREAK}, "<p>first line<br>\nsecond line</p>\n"},
+		{Options{ParagraphLineBreaks: true}, "<p>first line<br>\nsecond line</p>\n"},
+	}
+
+	// the options only apply to the call they are passed to, even with a
+	// shared renderer
+	renderer := HtmlRenderer(0, "", "")
+	for i := 0; i < 2; i++ {
+		for _, test := range tests {
+			got := string(MarkdownOptions([]byte(input), renderer, test.opts))
+			if got != test.want {
+				t.Errorf("%+v:\nExpected[%#v]\nActual  [%#v]", test.opts, test.want, got)
+			}
+		}
+	}
+}
+
+func TestNormalizeUnicode(t *testing.T) {
+	var tests = []string{
+		"Cafe\u0301 cre\u0300me\n",
+		"<p>Caf\u00e9 cr\u00e8me</p>\n",
+
+		"Vie\u0323\u0302t and Vie\u0302\u0323t\n",
+		"<p>Vi\u1ec7t and Vi\u1ec7t</p>\n",
+
+		"already caf\u00e9, a\u0336 and \u0301 alone\n",
+		"<p>already caf\u00e9, a\u0336 and \u0301 alone</p>\n",
+
+		"`cafe\u0301`\n",
+		"<p><code>caf\u00e9</code></p>\n",
+
+		"# Cafe\u0301\n",
+		"<h1 id=\"caf\u00e9\">Caf\u00e9</h1>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_AUTO_HEADER_IDS, NormalizeUnicode: true}, 0, HtmlRendererParameters{})
+
+	// code can be left alone
+	tests = []string{
+		"cafe\u0301 `cafe\u0301`\n",
+		"<p>caf\u00e9 <code>cafe\u0301</code></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{NormalizeUnicode: true, NormalizeUnicodeSkipCode: true}, 0, HtmlRendererParameters{})
+
+	// off by default
+	tests = []string{
+		"cafe\u0301\n",
+		"<p>cafe\u0301</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{}, 0, HtmlRendererParameters{})
 }
 
 func TestInlineLink(t *testing.T) {
@@ -582,6 +1114,41 @@ func TestInlineLink(t *testing.T) {
 
 }
 
+func TestForwardReference(t *testing.T) {
+	var tests = []string{
+		"See [the docs][Docs], [docs][] and [DOCS].\n\n[docs]: /docs \"Docs\"\n",
+		"<p>See <a href=\"/docs\" title=\"Docs\">the docs</a>, <a href=\"/docs\" title=\"Docs\">docs</a> " +
+			"and <a href=\"/docs\" title=\"Docs\">DOCS</a>.</p>\n",
+
+		"[first][a] and [second][b]\n\n[b]: /b\n\n[a]: /a\n",
+		"<p><a href=\"/a\">first</a> and <a href=\"/b\">second</a></p>\n",
+
+		"[Multi  Word][]\n\n[multi word]: /mw\n",
+		"<p><a href=\"/mw\">Multi  Word</a></p>\n",
+	}
+	doLinkTestsInline(t, tests)
+}
+
+func TestReferenceImage(t *testing.T) {
+	var tests = []string{
+		"![alt][logo]\n\n[logo]: /logo.png \"The logo\"\n",
+		"<p><img src=\"/logo.png\" alt=\"alt\" title=\"The logo\" /></p>\n",
+
+		"![logo][]\n\n[logo]: /logo.png\n",
+		"<p><img src=\"/logo.png\" alt=\"logo\" /></p>\n",
+
+		"![logo]\n\n[logo]: /logo.png\n",
+		"<p><img src=\"/logo.png\" alt=\"logo\" /></p>\n",
+
+		"[![alt][logo]](/home)\n\n[logo]: /logo.png\n",
+		"<p><a href=\"/home\"><img src=\"/logo.png\" alt=\"alt\" /></a></p>\n",
+
+		"![alt][missing]\n",
+		"<p>![alt][missing]</p>\n",
+	}
+	doLinkTestsInline(t, tests)
+}
+
 func TestRelAttrLink(t *testing.T) {
 	var nofollowTests = []string{
 		"[foo](http://bar.com/foo/)\n",
@@ -653,6 +1220,168 @@ func TestHrefTargetBlank(t *testing.T) {
 	doTestsInlineParam(t, tests, Options{}, HTML_SAFELINK|HTML_HREF_TARGET_BLANK, HtmlRendererParameters{})
 }
 
+func TestLinkAttributes(t *testing.T) {
+	var tests = []string{
+		"[foo](http://example.com/){target=_blank}\n",
+		"<p><a href=\"http://example.com/\" target=\"_blank\">foo</a></p>\n",
+
+		"[foo](/bar/ \"Bar\"){target=_blank rel=nofollow}\n",
+		"<p><a href=\"/bar/\" title=\"Bar\" rel=\"nofollow\" target=\"_blank\">foo</a></p>\n",
+
+		"[foo](/bar/){.button .large data-id='x \"y\" <z>'}\n",
+		"<p><a href=\"/bar/\" class=\"button large\" data-id=\"x &quot;y&quot; &lt;z&gt;\">foo</a></p>\n",
+
+		"[foo][ref]{target=_top} and [foo]{target=_top}\n\n[ref]: /ref/\n[foo]: /foo/\n",
+		"<p><a href=\"/ref/\" target=\"_top\">foo</a> and <a href=\"/foo/\" target=\"_top\">foo</a></p>\n",
+
+		"[foo](/bar/){onclick=alert(1) href=/other/ title=x}\n",
+		"<p><a href=\"/bar/\">foo</a></p>\n",
+
+		// only a valid block right after the link is taken
+		"[foo](/bar/){not attributes}\n",
+		"<p><a href=\"/bar/\">foo</a>{not attributes}</p>\n",
+
+		"[foo](/bar/) {target=_blank}\n",
+		"<p><a href=\"/bar/\">foo</a> {target=_blank}</p>\n",
+
+		"[foo](/bar/){}\n",
+		"<p><a href=\"/bar/\">foo</a>{}</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{LinkAttributes: true}, 0, HtmlRendererParameters{})
+
+	// the link's own attributes add to and win over the flags
+	tests = []string{
+		"[foo](http://example.com/){rel=external target=_self}\n",
+		"<p><a href=\"http://example.com/\" rel=\"nofollow external\" target=\"_self\">foo</a></p>\n",
+
+		"[foo](http://example.com/){rel=nofollow}\n",
+		"<p><a href=\"http://example.com/\" rel=\"nofollow\" target=\"_blank\">foo</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{LinkAttributes: true},
+		HTML_NOFOLLOW_LINKS|HTML_HREF_TARGET_BLANK, HtmlRendererParameters{})
+
+	// left alone without the option
+	doTestsInline(t, []string{"[foo](/bar/){target=_blank}\n",
+		"<p><a href=\"/bar/\">foo</a>{target=_blank}</p>\n"})
+
+	// written back by the Markdown renderer
+	tests = []string{
+		"[foo](/bar/){target=_blank .button title='say \"hi\"'}\n",
+		"[foo](/bar/){.button target=\"_blank\" title='say \"hi\"'}\n",
+	}
+	doTestsBlockWithRunner(t, tests, 0, func(input string, extensions int) string {
+		opts := Options{Extensions: extensions, LinkAttributes: true}
+		return string(MarkdownOptions([]byte(input), MarkdownRenderer(extensions), opts))
+	})
+}
+
+func TestCustomInlineParsers(t *testing.T) {
+	// @name links to the user's page
+	mention := func(out *bytes.Buffer, r Renderer, data []byte, offset int) int {
+		if offset > 0 && isalnum(data[offset-1]) {
+			return 0
+		}
+		end := offset + 1
+		for end < len(data) && isalnum(data[end]) {
+			end++
+		}
+		if end == offset+1 {
+			return 0
+		}
+		r.Link(out, append([]byte("/users/"), data[offset+1:end]...), nil, data[offset:end])
+		return end - offset
+	}
+	// &me is replaced, other entities are left to the built-in parser
+	me := func(out *bytes.Buffer, r Renderer, data []byte, offset int) int {
+		if !bytes.HasPrefix(data[offset:], []byte("&me")) {
+			return 0
+		}
+		r.NormalText(out, []byte("the author"))
+		return 3
+	}
+
+	var tests = []string{
+		"hi @alice and @bob!\n",
+		"<p>hi <a href=\"/users/alice\">@alice</a> and <a href=\"/users/bob\">@bob</a>!</p>\n",
+
+		"mail bob@example.com or just @\n",
+		"<p>mail bob@example.com or just @</p>\n",
+
+		"*@alice*\n",
+		"<p><em><a href=\"/users/alice\">@alice</a></em></p>\n",
+
+		"&me &amp; &copy;\n",
+		"<p>the author &amp; &copy;</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{
+		InlineParsers: map[byte]InlineParser{'@': mention, '&': me},
+	}, 0, HtmlRendererParameters{})
+}
+
+func TestExternalLinkAttrs(t *testing.T) {
+	var tests = []string{
+		"[foo](http://example.com/)\n",
+		"<p><a href=\"http://example.com/\" rel=\"nofollow noopener\" target=\"_blank\">foo</a></p>\n",
+
+		"<http://example.com/>\n",
+		"<p><a href=\"http://example.com/\" rel=\"nofollow noopener\" target=\"_blank\">http://example.com/</a></p>\n",
+
+		"[foo](/bar/)\n",
+		"<p><a href=\"/bar/\">foo</a></p>\n",
+
+		"[foo](https://Internal.example.org:8080/page)\n",
+		"<p><a href=\"https://Internal.example.org:8080/page\">foo</a></p>\n",
+
+		"[foo](https://other.example.org/page)\n",
+		"<p><a href=\"https://other.example.org/page\" rel=\"nofollow noopener\" target=\"_blank\">foo</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{}, HTML_NOFOLLOW_LINKS|HTML_NOOPENER_LINKS|HTML_HREF_TARGET_BLANK,
+		HtmlRendererParameters{InternalHosts: []string{"internal.example.org"}})
+
+	tests = []string{
+		"[foo](http://example.com/)\n",
+		"<p><a href=\"http://example.com/\" rel=\"noopener\">foo</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{}, HTML_NOOPENER_LINKS, HtmlRendererParameters{})
+}
+
+func TestLinkRewriter(t *testing.T) {
+	var seen []string
+	rewrite := func(link []byte, isImage bool) []byte {
+		seen = append(seen, fmt.Sprintf("%s %v", link, isImage))
+		if bytes.HasPrefix(link, []byte("/")) {
+			return append([]byte("https://cdn.example.com"), link...)
+		}
+		return link
+	}
+	params := HtmlRendererParameters{LinkRewriter: rewrite}
+
+	var tests = []string{
+		"![a](/img/a.png)\n",
+		"<p><img src=\"https://cdn.example.com/img/a.png\" alt=\"a\" /></p>\n",
+
+		"[doc](/docs/x.html \"Doc\")\n",
+		"<p><a href=\"https://cdn.example.com/docs/x.html\" title=\"Doc\">doc</a></p>\n",
+
+		"[ref][r]\n\n[r]: /from/ref\n",
+		"<p><a href=\"https://cdn.example.com/from/ref\">ref</a></p>\n",
+
+		"[top](#top), [mail](mailto:a@example.com) and [abs](http://example.com/a)\n",
+		"<p><a href=\"#top\">top</a>, <a href=\"mailto:a@example.com\">mail</a> and <a href=\"http://example.com/a\">abs</a></p>\n",
+
+		"<a@example.com>\n",
+		"<p><a href=\"mailto:a@example.com\">a@example.com</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{}, HTML_USE_XHTML, params)
+
+	seen = nil
+	runMarkdownInline("![i](/i.png) [l](/l) [t](#top) <mailto:a@example.com>\n", Options{}, 0, params)
+	want := []string{"/i.png true", "/l false", "#top false", "mailto:a@example.com false"}
+	if strings.Join(seen, ", ") != strings.Join(want, ", ") {
+		t.Errorf("LinkRewriter saw %q, want %q", seen, want)
+	}
+}
+
 func TestSafeInlineLink(t *testing.T) {
 	var tests = []string{
 		"[foo](/bar/)\n",
@@ -741,6 +1470,32 @@ func TestTags(t *testing.T) {
 	doTestsInline(t, tests)
 }
 
+func TestEscapeHtml(t *testing.T) {
+	input := "a <img src=\"x\" onerror=\"alert(1)\"> and <b>bold</b> &amp; <!-- note -->\n"
+
+	// passed through
+	doTestsInline(t, []string{input,
+		"<p>a <img src=\"x\" onerror=\"alert(1)\"> and <b>bold</b> &amp; <!-- note --></p>\n"})
+
+	// escaped
+	doTestsInlineParam(t, []string{input,
+		"<p>a &lt;img src=&quot;x&quot; onerror=&quot;alert(1)&quot;&gt; and &lt;b&gt;bold&lt;/b&gt; &amp; &lt;!-- note --&gt;</p>\n"},
+		Options{}, HTML_ESCAPE_HTML, HtmlRendererParameters{})
+
+	// dropped
+	doTestsInlineParam(t, []string{input,
+		"<p>a  and bold &amp; </p>\n"},
+		Options{}, HTML_SKIP_HTML, HtmlRendererParameters{})
+	doTestsInlineParam(t, []string{input,
+		"<p>a  and bold &amp; </p>\n"},
+		Options{}, HTML_SKIP_HTML|HTML_ESCAPE_HTML, HtmlRendererParameters{})
+
+	// HTML blocks are escaped too
+	doTestsInlineParam(t, []string{"<div onclick=\"x()\">\nhi\n</div>\n",
+		"<p>&lt;div onclick=&quot;x()&quot;&gt;\nhi\n&lt;/div&gt;</p>\n"},
+		Options{}, HTML_ESCAPE_HTML, HtmlRendererParameters{})
+}
+
 func TestAutoLink(t *testing.T) {
 	var tests = []string{
 		"http://foo.com/\n",
@@ -1077,6 +1832,24 @@ func TestFootnotesWithParameters(t *testing.T) {
 	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES}, HTML_FOOTNOTE_RETURN_LINKS, params)
 }
 
+func TestInlineFootnotesInBlocks(t *testing.T) {
+	// the notes are rendered last, after the list items and quotes that
+	// held their text are done with
+	var tests = []string{
+		"* one^[first note]\n* two^[second note]\n* three and some longer text\n\n> quoted^[third note]\n\n> another quote with longer text\n",
+		"<ul>\n<li>one<sup class=\"footnote-ref\" id=\"fnref:first-note\"><a href=\"#fn:first-note\">1</a></sup></li>\n" +
+			"<li>two<sup class=\"footnote-ref\" id=\"fnref:second-note\"><a href=\"#fn:second-note\">2</a></sup></li>\n" +
+			"<li>three and some longer text</li>\n</ul>\n\n" +
+			"<blockquote>\n<p>quoted<sup class=\"footnote-ref\" id=\"fnref:third-note\"><a href=\"#fn:third-note\">3</a></sup></p>\n\n" +
+			"<p>another quote with longer text</p>\n</blockquote>\n" +
+			"<div class=\"footnotes\">\n\n<hr />\n\n<ol>\n" +
+			"<li id=\"fn:first-note\">first note</li>\n" +
+			"<li id=\"fn:second-note\">second note</li>\n" +
+			"<li id=\"fn:third-note\">third note</li>\n</ol>\n</div>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES}, 0, HtmlRendererParameters{})
+}
+
 func TestNestedFootnotes(t *testing.T) {
 	var tests = []string{
 		`Paragraph.[^fn1]
@@ -1132,6 +1905,278 @@ This uses footnote C.[^C]
 		HtmlRendererParameters{})
 }
 
+func TestMultiParagraphFootnotes(t *testing.T) {
+	var tests = []string{
+		`Text.[^1]
+
+[^1]: First paragraph,
+    continued.
+
+    Second paragraph.
+
+        code
+
+    * one
+    * two
+After.
+`,
+		`<p>Text.<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup></p>
+
+<p>After.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:1"><p>First paragraph,
+continued.</p>
+
+<p>Second paragraph.</p>
+
+<pre><code>code
+</code></pre>
+
+<ul>
+<li>one</li>
+<li>two</li>
+</ul>
+</li>
+</ol>
+</div>
+`,
+
+		// a blank line followed by an unindented line ends the footnote
+		"Text.[^1]\n\n[^1]: Only paragraph.\n\nAfter.\n\n    code\n",
+		`<p>Text.<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup></p>
+
+<p>After.</p>
+
+<pre><code>code
+</code></pre>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:1">Only paragraph.
+</li>
+</ol>
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES}, 0, HtmlRendererParameters{})
+}
+
+func TestFootnoteLabels(t *testing.T) {
+	var tests = []string{
+		"Upper[^Note] and lower[^note].\n\n[^Note]: Upper case.\n[^note]: Lower case.\n[^unused]: Dropped.\n",
+		`<p>Upper<sup class="footnote-ref" id="fnref:Note"><a href="#fn:Note">1</a></sup> and lower<sup class="footnote-ref" id="fnref:note"><a href="#fn:note">2</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:Note">Upper case.
+</li>
+<li id="fn:note">Lower case.
+</li>
+</ol>
+</div>
+`,
+
+		"Wrong case[^NOTE].\n\n[^note]: Lower case.\n",
+		"<p>Wrong case[^NOTE].</p>\n",
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES}, 0, HtmlRendererParameters{})
+}
+
+func TestSortedFootnotes(t *testing.T) {
+	var tests = []string{
+		`First[^zeta] and second[^alpha] and inline^[beta note].
+
+[^zeta]: Last by label.
+[^alpha]: First by label.
+[^unused]: Never referenced.
+`,
+		`<p>First<sup class="footnote-ref" id="fnref:zeta"><a href="#fn:zeta">3</a></sup> and second<sup class="footnote-ref" id="fnref:alpha"><a href="#fn:alpha">1</a></sup> and inline<sup class="footnote-ref" id="fnref:beta-note"><a href="#fn:beta-note">2</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:alpha">First by label.
+</li>
+<li id="fn:beta-note">beta note</li>
+<li id="fn:zeta">Last by label.
+</li>
+</ol>
+</div>
+`,
+
+		`Only in the body[^b].
+
+[^b]: Refers to a note.[^a]
+[^a]: Only referenced from a note.
+`,
+		`<p>Only in the body<sup class="footnote-ref" id="fnref:b"><a href="#fn:b">2</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:a">Only referenced from a note.
+</li>
+<li id="fn:b">Refers to a note.<sup class="footnote-ref" id="fnref:a"><a href="#fn:a">1</a></sup>
+</li>
+</ol>
+</div>
+`,
+
+		// inline notes that only start alike are different notes
+		"One^[This is a long footnote about apples] two^[This is a long footnote about pears].\n",
+		`<p>One<sup class="footnote-ref" id="fnref:This-is-a-long-f"><a href="#fn:This-is-a-long-f">1</a></sup> two<sup class="footnote-ref" id="fnref:This-is-a-long-f-2"><a href="#fn:This-is-a-long-f-2">2</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:This-is-a-long-f">This is a long footnote about apples</li>
+<li id="fn:This-is-a-long-f-2">This is a long footnote about pears</li>
+</ol>
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES, SortFootnotes: true}, 0,
+		HtmlRendererParameters{})
+
+	// the default order is still the order of first reference
+	tests = []string{
+		tests[0],
+		`<p>First<sup class="footnote-ref" id="fnref:zeta"><a href="#fn:zeta">1</a></sup> and second<sup class="footnote-ref" id="fnref:alpha"><a href="#fn:alpha">2</a></sup> and inline<sup class="footnote-ref" id="fnref:beta-note"><a href="#fn:beta-note">3</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:zeta">Last by label.
+</li>
+<li id="fn:alpha">First by label.
+</li>
+<li id="fn:beta-note">beta note</li>
+</ol>
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES}, 0,
+		HtmlRendererParameters{})
+}
+
+func TestRepeatedInlineFootnotes(t *testing.T) {
+	// the same note given again is one footnote, sorted or not
+	var tests = []string{
+		"One^[same] two^[other] three^[same] four^[same].\n",
+		`<p>One<sup class="footnote-ref" id="fnref:same"><a href="#fn:same">1</a></sup> two<sup class="footnote-ref" id="fnref:other"><a href="#fn:other">2</a></sup> three<sup class="footnote-ref" id="fnref:same"><a href="#fn:same">1</a></sup> four<sup class="footnote-ref" id="fnref:same"><a href="#fn:same">1</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:same">same</li>
+<li id="fn:other">other</li>
+</ol>
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES}, 0,
+		HtmlRendererParameters{})
+
+	tests = []string{
+		tests[0],
+		`<p>One<sup class="footnote-ref" id="fnref:same"><a href="#fn:same">2</a></sup> two<sup class="footnote-ref" id="fnref:other"><a href="#fn:other">1</a></sup> three<sup class="footnote-ref" id="fnref:same"><a href="#fn:same">2</a></sup> four<sup class="footnote-ref" id="fnref:same"><a href="#fn:same">2</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:other">other</li>
+<li id="fn:same">same</li>
+</ol>
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES, SortFootnotes: true}, 0,
+		HtmlRendererParameters{})
+}
+
+func TestInlineFootnoteWithoutSlug(t *testing.T) {
+	// notes with no letters or digits are named by their number
+	var tests = []string{
+		"text^[!!!] and more^[a!].\n",
+		`<p>text<sup class="footnote-ref" id="fnref:footnote-1"><a href="#fn:footnote-1">1</a></sup> and more<sup class="footnote-ref" id="fnref:a"><a href="#fn:a">2</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:footnote-1">!!!</li>
+<li id="fn:a">a!</li>
+</ol>
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES}, 0,
+		HtmlRendererParameters{})
+}
+
+func TestFootnoteAllReturnLinks(t *testing.T) {
+	var tests = []string{
+		`One[^a], two[^a] and three[^a].
+
+[^a]: The note.
+`,
+		`<p>One<sup class="footnote-ref" id="fnref:a"><a href="#fn:a">1</a></sup>, two<sup class="footnote-ref" id="fnref:a:2"><a href="#fn:a">1</a></sup> and three<sup class="footnote-ref" id="fnref:a:3"><a href="#fn:a">1</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:a">The note.
+ <a class="footnote-return" href="#fnref:a">ret</a> <a class="footnote-return" href="#fnref:a:2">ret</a> <a class="footnote-return" href="#fnref:a:3">ret</a></li>
+</ol>
+</div>
+`,
+
+		`Just once[^b].
+
+[^b]: Another note.
+`,
+		`<p>Just once<sup class="footnote-ref" id="fnref:b"><a href="#fn:b">1</a></sup>.</p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:b">Another note.
+ <a class="footnote-return" href="#fnref:b">ret</a></li>
+</ol>
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, Options{Extensions: EXTENSION_FOOTNOTES},
+		HTML_FOOTNOTE_RETURN_LINKS|HTML_FOOTNOTE_ALL_RETURN_LINKS,
+		HtmlRendererParameters{FootnoteReturnLinkContents: "ret"})
+
+	// a reused renderer counts the references of each document anew
+	renderer := HtmlRendererWithParameters(HTML_USE_XHTML|HTML_FOOTNOTE_RETURN_LINKS|HTML_FOOTNOTE_ALL_RETURN_LINKS, "", "",
+		HtmlRendererParameters{FootnoteReturnLinkContents: "ret"})
+	input := []byte(tests[0])
+	opts := Options{Extensions: EXTENSION_FOOTNOTES}
+	MarkdownOptions(input, renderer, opts)
+	if actual := string(MarkdownOptions(input, renderer, opts)); actual != tests[1] {
+		t.Errorf("second rendering:\nExpected[%#v]\nActual  [%#v]", tests[1], actual)
+	}
+}
+
 func TestInlineComments(t *testing.T) {
 	var tests = []string{
 		"Hello <!-- there ->\n",
@@ -1212,7 +2257,7 @@ func TestSmartAngledDoubleQuotesNbsp(t *testing.T) {
 func TestSmartFractions(t *testing.T) {
 	var tests = []string{
 		"1/2, 1/4 and 3/4; 1/4th and 3/4ths\n",
-		"<p>&frac12;, &frac14; and &frac34;; &frac14;th and &frac34;ths</p>\n",
+		"<p>1/2, 1/4 and 3/4; 1/4th and 3/4ths</p>\n",
 		"1/2/2015, 1/4/2015, 3/4/2015; 2015/1/2, 2015/1/4, 2015/3/4.\n",
 		"<p>1/2/2015, 1/4/2015, 3/4/2015; 2015/1/2, 2015/1/4, 2015/3/4.</p>\n"}
 
@@ -1265,6 +2310,28 @@ func TestDisableSmartDashes(t *testing.T) {
 		HtmlRendererParameters{})
 }
 
+func TestSmartypantsFlagsIndependent(t *testing.T) {
+	input := "\"quoted\" -- 1/2 done...\n"
+	var tests = []struct {
+		flags int
+		want  string
+	}{
+		{0, "<p>&quot;quoted&quot; -- 1/2 done...</p>\n"},
+		{HTML_USE_SMARTYPANTS, "<p>&ldquo;quoted&rdquo; -- 1/2 done&hellip;</p>\n"},
+		{HTML_SMARTYPANTS_DASHES, "<p>&quot;quoted&quot; &mdash; 1/2 done...</p>\n"},
+		{HTML_SMARTYPANTS_DASHES | HTML_SMARTYPANTS_LATEX_DASHES, "<p>&quot;quoted&quot; &ndash; 1/2 done...</p>\n"},
+		{HTML_SMARTYPANTS_FRACTIONS, "<p>&quot;quoted&quot; -- <sup>1</sup>&frasl;<sub>2</sub> done...</p>\n"},
+		{HTML_USE_SMARTYPANTS | HTML_SMARTYPANTS_DASHES, "<p>&ldquo;quoted&rdquo; &mdash; 1/2 done&hellip;</p>\n"},
+		{HTML_USE_SMARTYPANTS | HTML_SMARTYPANTS_FRACTIONS, "<p>&ldquo;quoted&rdquo; -- <sup>1</sup>&frasl;<sub>2</sub> done&hellip;</p>\n"},
+	}
+	for _, test := range tests {
+		got := runMarkdownInline(input, Options{}, test.flags, HtmlRendererParameters{})
+		if got != test.want {
+			t.Errorf("flags %#x:\nExpected[%#v]\nActual  [%#v]", test.flags, test.want, got)
+		}
+	}
+}
+
 func BenchmarkSmartDoubleQuotes(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		runMarkdownInline("this should be normal \"quoted\" text.\n", Options{}, HTML_USE_SMARTYPANTS, HtmlRendererParameters{})
@@ -0,0 +1,478 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// JSON rendering backend
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Json is a type that implements the Renderer interface for JSON output. It
+// exports the parsed document as a tree of nodes for processing by other
+// tools:
+//
+//	{"type":"Document","children":[
+//	  {"type":"Header","level":1,"children":[
+//	    {"type":"Text","literal":"Title"}]}]}
+//
+// Each node has a "type", named after the Renderer method that produced it
+// (text is "Text"), followed by its attributes in a fixed order and its
+// "children". Strings are escaped so that the output is always valid JSON,
+// whatever the input.
+//
+// Do not create this directly, instead use the JsonRenderer function.
+type Json struct {
+}
+
+// JsonRenderer creates and configures a Json object, which satisfies the
+// Renderer interface.
+//
+// flags is a set of JSON_* options ORed together (currently no such options
+// are defined).
+func JsonRenderer(flags int) Renderer {
+	return &Json{}
+}
+
+func (options *Json) GetFlags() int {
+	return 0
+}
+
+// The parser edits the text it has written to out, removing the ! in front
+// of images for instance, so text is written raw, escaped and marked with
+// textMark, and turned into Text nodes once the document is complete.
+const textMark = '\x01'
+
+// write s as the contents of a JSON string; brackets are escaped too, so
+// that they only ever appear in the structure of the output
+func jsonEscape(out *bytes.Buffer, s []byte) {
+	for len(s) > 0 {
+		r, size := utf8.DecodeRune(s)
+		switch {
+		case r == '"' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '\n':
+			out.WriteString("\\n")
+		case r == '\t':
+			out.WriteString("\\t")
+		case r < 0x20 || r == '{' || r == '}' || r == '[' || r == ']':
+			fmt.Fprintf(out, "\\u%04x", r)
+		default:
+			// invalid UTF-8 becomes U+FFFD
+			out.WriteRune(r)
+		}
+		s = s[size:]
+	}
+}
+
+func jsonString(out *bytes.Buffer, key string, value []byte) {
+	out.WriteString(",\"")
+	out.WriteString(key)
+	out.WriteString("\":\"")
+	jsonEscape(out, value)
+	out.WriteByte('"')
+}
+
+func jsonInt(out *bytes.Buffer, key string, value int) {
+	out.WriteString(",\"")
+	out.WriteString(key)
+	out.WriteString("\":")
+	out.WriteString(strconv.Itoa(value))
+}
+
+func jsonAlign(flags int) []byte {
+	switch flags {
+	case TABLE_ALIGNMENT_LEFT:
+		return []byte("left")
+	case TABLE_ALIGNMENT_RIGHT:
+		return []byte("right")
+	case TABLE_ALIGNMENT_CENTER:
+		return []byte("center")
+	case TABLE_ALIGNMENT_JUSTIFY:
+		return []byte("justify")
+	}
+	return nil
+}
+
+// start a node; its attributes follow
+func openNode(out *bytes.Buffer, typ string) {
+	out.WriteString("{\"type\":\"")
+	out.WriteString(typ)
+	out.WriteByte('"')
+}
+
+// write the children of a node and close it
+func closeNode(out *bytes.Buffer, children []byte) {
+	out.WriteString(",\"children\":[")
+	out.Write(children)
+	out.WriteString("]}")
+}
+
+// write a node whose children are rendered by text
+func writeNode(out *bytes.Buffer, text func() bool) bool {
+	out.WriteString(",\"children\":[")
+	if !text() {
+		return false
+	}
+	out.WriteString("]}")
+	return true
+}
+
+// write a node with only a literal
+func literalNode(out *bytes.Buffer, typ string, literal []byte) {
+	openNode(out, typ)
+	jsonString(out, "literal", literal)
+	out.WriteByte('}')
+}
+
+func (options *Json) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	openNode(out, "BlockCode")
+	jsonString(out, "info", []byte(info))
+	jsonString(out, "literal", text)
+	out.WriteByte('}')
+}
+
+func (options *Json) BlockQuote(out *bytes.Buffer, text []byte) {
+	openNode(out, "BlockQuote")
+	closeNode(out, text)
+}
+
+func (options *Json) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {
+	openNode(out, "Admonition")
+	jsonString(out, "kind", []byte(kind))
+	out.WriteString(`,"title":[`)
+	out.Write(title)
+	out.WriteByte(']')
+	closeNode(out, text)
+}
+
+func (options *Json) Alert(out *bytes.Buffer, text []byte, kind string) {
+	openNode(out, "Alert")
+	jsonString(out, "kind", []byte(kind))
+	closeNode(out, text)
+}
+
+func (options *Json) Div(out *bytes.Buffer, text []byte, class string) {
+	openNode(out, "Div")
+	jsonString(out, "class", []byte(class))
+	closeNode(out, text)
+}
+
+func (options *Json) BlockHtml(out *bytes.Buffer, text []byte) {
+	literalNode(out, "BlockHtml", text)
+}
+
+func (options *Json) MathBlock(out *bytes.Buffer, text []byte) {
+	literalNode(out, "MathBlock", text)
+}
+
+func (options *Json) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	marker := out.Len()
+	openNode(out, "Header")
+	jsonInt(out, "level", level)
+	if id != "" {
+		jsonString(out, "id", []byte(id))
+	}
+	if !writeNode(out, text) {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Json) HRule(out *bytes.Buffer) {
+	openNode(out, "HRule")
+	out.WriteByte('}')
+}
+
+func (options *Json) List(out *bytes.Buffer, text func() bool, flags int) {
+	options.NumberedList(out, text, flags, 1)
+}
+
+func (options *Json) NumberedList(out *bytes.Buffer, text func() bool, flags, start int) {
+	marker := out.Len()
+	openNode(out, "List")
+	jsonInt(out, "listFlags", flags)
+	if flags&LIST_TYPE_ORDERED != 0 {
+		jsonInt(out, "start", start)
+	}
+	if !writeNode(out, text) {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Json) ListItem(out *bytes.Buffer, text []byte, flags int) {
+	// the parser strips the newlines from the end of the item, but they are
+	// escaped here
+	for {
+		text = bytes.TrimRight(text, string(textMark))
+		if !bytes.HasSuffix(text, []byte("\\n")) {
+			break
+		}
+		backslashes := 0
+		for i := len(text) - 2; i >= 0 && text[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			break
+		}
+		text = text[:len(text)-2]
+	}
+
+	openNode(out, "ListItem")
+	jsonInt(out, "listFlags", flags)
+	closeNode(out, text)
+}
+
+func (options *Json) Paragraph(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	openNode(out, "Paragraph")
+	if !writeNode(out, text) {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Json) Table(out *bytes.Buffer, header []byte, body []byte, columnData []int) {
+	options.CaptionedTable(out, header, body, columnData, nil)
+}
+
+func (options *Json) CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte) {
+	openNode(out, "Table")
+	out.WriteString(",\"align\":[")
+	for i, align := range columnData {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteByte('"')
+		out.Write(jsonAlign(align))
+		out.WriteByte('"')
+	}
+	out.WriteByte(']')
+	if len(caption) > 0 {
+		out.WriteString(",\"caption\":[")
+		out.Write(caption)
+		out.WriteByte(']')
+	}
+	out.WriteString(",\"children\":[")
+	openNode(out, "TableHead")
+	closeNode(out, header)
+	out.WriteByte(',')
+	openNode(out, "TableBody")
+	closeNode(out, body)
+	out.WriteString("]}")
+}
+
+func (options *Json) TableRow(out *bytes.Buffer, text []byte) {
+	openNode(out, "TableRow")
+	closeNode(out, text)
+}
+
+func (options *Json) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	openNode(out, "TableHeaderCell")
+	if align := jsonAlign(flags); align != nil {
+		jsonString(out, "align", align)
+	}
+	closeNode(out, text)
+}
+
+func (options *Json) TableCell(out *bytes.Buffer, text []byte, flags int) {
+	openNode(out, "TableCell")
+	if align := jsonAlign(flags); align != nil {
+		jsonString(out, "align", align)
+	}
+	closeNode(out, text)
+}
+
+func (options *Json) Footnotes(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	openNode(out, "Footnotes")
+	if !writeNode(out, text) {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Json) FootnoteItem(out *bytes.Buffer, name, text []byte, flags int) {
+	openNode(out, "FootnoteItem")
+	jsonString(out, "name", name)
+	closeNode(out, text)
+}
+
+func (options *Json) TitleBlock(out *bytes.Buffer, text []byte) {
+	literalNode(out, "TitleBlock", text)
+}
+
+func (options *Json) AutoLink(out *bytes.Buffer, link []byte, kind int) {
+	openNode(out, "AutoLink")
+	jsonString(out, "destination", link)
+	if kind == LINK_TYPE_EMAIL {
+		jsonString(out, "kind", []byte("email"))
+	}
+	out.WriteByte('}')
+}
+
+func (options *Json) CodeSpan(out *bytes.Buffer, text []byte) {
+	options.LanguageCodeSpan(out, text, "")
+}
+
+func (options *Json) LanguageCodeSpan(out *bytes.Buffer, text []byte, lang string) {
+	openNode(out, "CodeSpan")
+	if lang != "" {
+		jsonString(out, "info", []byte(lang))
+	}
+	jsonString(out, "literal", text)
+	out.WriteByte('}')
+}
+
+func (options *Json) DoubleEmphasis(out *bytes.Buffer, text []byte) {
+	openNode(out, "DoubleEmphasis")
+	closeNode(out, text)
+}
+
+func (options *Json) Emphasis(out *bytes.Buffer, text []byte) {
+	openNode(out, "Emphasis")
+	closeNode(out, text)
+}
+
+func (options *Json) Image(out *bytes.Buffer, link []byte, title []byte, alt []byte) {
+	openNode(out, "Image")
+	jsonString(out, "destination", link)
+	if len(title) > 0 {
+		jsonString(out, "title", title)
+	}
+	jsonString(out, "literal", alt)
+	out.WriteByte('}')
+}
+
+func (options *Json) LineBreak(out *bytes.Buffer) {
+	openNode(out, "LineBreak")
+	out.WriteByte('}')
+}
+
+func (options *Json) Link(out *bytes.Buffer, link []byte, title []byte, content []byte) {
+	openNode(out, "Link")
+	jsonString(out, "destination", link)
+	if len(title) > 0 {
+		jsonString(out, "title", title)
+	}
+	closeNode(out, content)
+}
+
+func (options *Json) RawHtmlTag(out *bytes.Buffer, tag []byte) {
+	literalNode(out, "RawHtmlTag", tag)
+}
+
+func (options *Json) TripleEmphasis(out *bytes.Buffer, text []byte) {
+	openNode(out, "TripleEmphasis")
+	closeNode(out, text)
+}
+
+func (options *Json) StrikeThrough(out *bytes.Buffer, text []byte) {
+	openNode(out, "StrikeThrough")
+	closeNode(out, text)
+}
+
+func (options *Json) Superscript(out *bytes.Buffer, text []byte) {
+	openNode(out, "Superscript")
+	closeNode(out, text)
+}
+
+func (options *Json) Subscript(out *bytes.Buffer, text []byte) {
+	openNode(out, "Subscript")
+	closeNode(out, text)
+}
+
+func (options *Json) Highlight(out *bytes.Buffer, text []byte) {
+	openNode(out, "Highlight")
+	closeNode(out, text)
+}
+
+func (options *Json) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	openNode(out, "Abbreviation")
+	jsonString(out, "title", title)
+	closeNode(out, text)
+}
+
+func (options *Json) Math(out *bytes.Buffer, text []byte) {
+	literalNode(out, "Math", text)
+}
+
+func (options *Json) FootnoteRef(out *bytes.Buffer, ref []byte, id int) {
+	openNode(out, "FootnoteRef")
+	jsonString(out, "name", ref)
+	jsonInt(out, "id", id)
+	out.WriteByte('}')
+}
+
+func (options *Json) Entity(out *bytes.Buffer, entity []byte) {
+	literalNode(out, "Entity", entity)
+}
+
+func (options *Json) NormalText(out *bytes.Buffer, text []byte) {
+	if len(text) == 0 {
+		return
+	}
+	out.WriteByte(textMark)
+	jsonEscape(out, text)
+}
+
+func (options *Json) DocumentHeader(out *bytes.Buffer) {
+	openNode(out, "Document")
+	out.WriteString(",\"children\":[")
+}
+
+func (options *Json) DocumentFooter(out *bytes.Buffer) {
+	out.WriteString("]}")
+
+	// turn the marked text into Text nodes, merging adjacent runs, and
+	// separate sibling nodes with commas
+	var doc bytes.Buffer
+	data := out.Bytes()
+	needComma := false
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c != textMark {
+			if c == '{' && needComma {
+				doc.WriteByte(',')
+			}
+			doc.WriteByte(c)
+			needComma = c == '}'
+			i++
+			continue
+		}
+
+		var text bytes.Buffer
+		for i < len(data) && data[i] == textMark {
+			i++
+			for i < len(data) && data[i] != '{' && data[i] != ']' && data[i] != textMark {
+				text.WriteByte(data[i])
+				i++
+			}
+		}
+		if text.Len() > 0 {
+			if needComma {
+				doc.WriteByte(',')
+			}
+			doc.WriteString("{\"type\":\"Text\",\"literal\":\"")
+			doc.Write(text.Bytes())
+			doc.WriteString("\"}")
+			needComma = true
+		}
+	}
+	doc.WriteByte('\n')
+
+	out.Reset()
+	out.Write(doc.Bytes())
+}
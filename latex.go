@@ -17,26 +17,37 @@ package blackfriday
 
 import (
 	"bytes"
+	"html"
+	"strconv"
 	"strings"
 )
 
+// Latex renderer configuration options.
+const (
+	LATEX_COMPLETE_PAGE = 1 << iota // generate a complete article document
+)
+
 // Latex is a type that implements the Renderer interface for LaTeX output.
 //
 // Do not create this directly, instead use the LatexRenderer function.
 type Latex struct {
+	flags int // LATEX_* options
+
+	enumerateDepth int // nesting level of the enumerate being rendered
 }
 
 // LatexRenderer creates and configures a Latex object, which
 // satisfies the Renderer interface.
 //
-// flags is a set of LATEX_* options ORed together (currently no such options
-// are defined).
+// flags is a set of LATEX_* options ORed together. Without
+// LATEX_COMPLETE_PAGE only the body is rendered, ready to be included in
+// another document.
 func LatexRenderer(flags int) Renderer {
-	return &Latex{}
+	return &Latex{flags: flags}
 }
 
 func (options *Latex) GetFlags() int {
-	return 0
+	return options.flags
 }
 
 // render code chunks using verbatim, or listings if we have a language
@@ -61,12 +72,37 @@ func (options *Latex) TitleBlock(out *bytes.Buffer, text []byte) {
 
 }
 
+func (options *Latex) MathBlock(out *bytes.Buffer, text []byte) {
+	out.WriteString("\n\\[\n")
+	out.Write(text)
+	out.WriteString("\n\\]\n")
+}
+
 func (options *Latex) BlockQuote(out *bytes.Buffer, text []byte) {
 	out.WriteString("\n\\begin{quotation}\n")
 	out.Write(text)
 	out.WriteString("\n\\end{quotation}\n")
 }
 
+func (options *Latex) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {
+	out.WriteString("\n\\begin{quotation}\n")
+	if len(title) > 0 {
+		out.WriteString("\\textbf{")
+		out.Write(title)
+		out.WriteString("}\n")
+	}
+	out.Write(text)
+	out.WriteString("\n\\end{quotation}\n")
+}
+
+func (options *Latex) Alert(out *bytes.Buffer, text []byte, kind string) {
+	options.Admonition(out, text, kind, alertTitle(kind))
+}
+
+func (options *Latex) Div(out *bytes.Buffer, text []byte, class string) {
+	out.Write(text)
+}
+
 func (options *Latex) BlockHtml(out *bytes.Buffer, text []byte) {
 	// a pretty lame thing to do...
 	out.WriteString("\n\\begin{verbatim}\n")
@@ -103,9 +139,28 @@ func (options *Latex) HRule(out *bytes.Buffer) {
 }
 
 func (options *Latex) List(out *bytes.Buffer, text func() bool, flags int) {
+	options.NumberedList(out, text, flags, 1)
+}
+
+func (options *Latex) NumberedList(out *bytes.Buffer, text func() bool, flags, start int) {
 	marker := out.Len()
 	if flags&LIST_TYPE_ORDERED != 0 {
+		options.enumerateDepth++
+		defer func() { options.enumerateDepth-- }()
+
 		out.WriteString("\n\\begin{enumerate}\n")
+		if start != 1 {
+			// each level of nested enumerate has its own counter
+			out.WriteString("\\setcounter{enum")
+			depth := options.enumerateDepth
+			if depth > len(enumerateCounters) {
+				depth = len(enumerateCounters)
+			}
+			out.WriteString(enumerateCounters[depth-1])
+			out.WriteString("}{")
+			out.WriteString(strconv.Itoa(start - 1))
+			out.WriteString("}\n")
+		}
 	} else {
 		out.WriteString("\n\\begin{itemize}\n")
 	}
@@ -120,8 +175,18 @@ func (options *Latex) List(out *bytes.Buffer, text func() bool, flags int) {
 	}
 }
 
+// the roman numerals LaTeX names the enumerate counters with, enumi to enumiv
+var enumerateCounters = []string{"i", "ii", "iii", "iv"}
+
 func (options *Latex) ListItem(out *bytes.Buffer, text []byte, flags int) {
-	out.WriteString("\n\\item ")
+	switch {
+	case flags&LIST_ITEM_CHECKED != 0:
+		out.WriteString("\n\\item[$\\boxtimes$] ")
+	case flags&LIST_ITEM_TASK != 0:
+		out.WriteString("\n\\item[$\\square$] ")
+	default:
+		out.WriteString("\n\\item ")
+	}
 	out.Write(text)
 }
 
@@ -136,10 +201,19 @@ func (options *Latex) Paragraph(out *bytes.Buffer, text func() bool) {
 }
 
 func (options *Latex) Table(out *bytes.Buffer, header []byte, body []byte, columnData []int) {
+	options.CaptionedTable(out, header, body, columnData, nil)
+}
+
+func (options *Latex) CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte) {
+	if len(caption) > 0 {
+		out.WriteString("\n\\begin{table}[h]\n\\centering\n\\caption{")
+		out.Write(caption)
+		out.WriteString("}")
+	}
 	out.WriteString("\n\\begin{tabular}{")
 	for _, elt := range columnData {
 		switch elt {
-		case TABLE_ALIGNMENT_LEFT:
+		case TABLE_ALIGNMENT_LEFT, TABLE_ALIGNMENT_JUSTIFY:
 			out.WriteByte('l')
 		case TABLE_ALIGNMENT_RIGHT:
 			out.WriteByte('r')
@@ -148,10 +222,15 @@ func (options *Latex) Table(out *bytes.Buffer, header []byte, body []byte, colum
 		}
 	}
 	out.WriteString("}\n")
-	out.Write(header)
-	out.WriteString(" \\\\\n\\hline\n")
+	if len(header) > 0 {
+		out.Write(header)
+		out.WriteString(" \\\\\n\\hline\n")
+	}
 	out.Write(body)
 	out.WriteString("\n\\end{tabular}\n")
+	if len(caption) > 0 {
+		out.WriteString("\\end{table}\n")
+	}
 }
 
 func (options *Latex) TableRow(out *bytes.Buffer, text []byte) {
@@ -161,17 +240,31 @@ func (options *Latex) TableRow(out *bytes.Buffer, text []byte) {
 	out.Write(text)
 }
 
-func (options *Latex) TableHeaderCell(out *bytes.Buffer, text []byte, align int) {
-	if out.Len() > 0 {
-		out.WriteString(" & ")
-	}
-	out.Write(text)
+func (options *Latex) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	options.TableCell(out, text, flags)
 }
 
-func (options *Latex) TableCell(out *bytes.Buffer, text []byte, align int) {
+func (options *Latex) TableCell(out *bytes.Buffer, text []byte, flags int) {
 	if out.Len() > 0 {
 		out.WriteString(" & ")
 	}
+	if span := flags >> TABLE_CELL_SPAN_SHIFT; span > 0 {
+		out.WriteString("\\multicolumn{")
+		out.WriteString(strconv.Itoa(span + 1))
+		out.WriteString("}{")
+		switch flags & TABLE_ALIGNMENT_MASK {
+		case TABLE_ALIGNMENT_LEFT, TABLE_ALIGNMENT_JUSTIFY:
+			out.WriteByte('l')
+		case TABLE_ALIGNMENT_RIGHT:
+			out.WriteByte('r')
+		default:
+			out.WriteByte('c')
+		}
+		out.WriteString("}{")
+		out.Write(text)
+		out.WriteString("}")
+		return
+	}
 	out.Write(text)
 }
 
@@ -189,9 +282,9 @@ func (options *Latex) AutoLink(out *bytes.Buffer, link []byte, kind int) {
 	if kind == LINK_TYPE_EMAIL {
 		out.WriteString("mailto:")
 	}
-	out.Write(link)
+	escapeHref(out, link)
 	out.WriteString("}{")
-	out.Write(link)
+	escapeSpecialChars(out, link)
 	out.WriteString("}")
 }
 
@@ -208,7 +301,7 @@ func (options *Latex) DoubleEmphasis(out *bytes.Buffer, text []byte) {
 }
 
 func (options *Latex) Emphasis(out *bytes.Buffer, text []byte) {
-	out.WriteString("\\textit{")
+	out.WriteString("\\emph{")
 	out.Write(text)
 	out.WriteString("}")
 }
@@ -217,9 +310,9 @@ func (options *Latex) Image(out *bytes.Buffer, link []byte, title []byte, alt []
 	if bytes.HasPrefix(link, []byte("http://")) || bytes.HasPrefix(link, []byte("https://")) {
 		// treat it like a link
 		out.WriteString("\\href{")
-		out.Write(link)
+		escapeHref(out, link)
 		out.WriteString("}{")
-		out.Write(alt)
+		escapeSpecialChars(out, alt)
 		out.WriteString("}")
 	} else {
 		out.WriteString("\\includegraphics{")
@@ -234,7 +327,7 @@ func (options *Latex) LineBreak(out *bytes.Buffer) {
 
 func (options *Latex) Link(out *bytes.Buffer, link []byte, title []byte, content []byte) {
 	out.WriteString("\\href{")
-	out.Write(link)
+	escapeHref(out, link)
 	out.WriteString("}{")
 	out.Write(content)
 	out.WriteString("}")
@@ -255,13 +348,41 @@ func (options *Latex) StrikeThrough(out *bytes.Buffer, text []byte) {
 	out.WriteString("}")
 }
 
+func (options *Latex) Superscript(out *bytes.Buffer, text []byte) {
+	out.WriteString("\\textsuperscript{")
+	out.Write(text)
+	out.WriteString("}")
+}
+
+func (options *Latex) Subscript(out *bytes.Buffer, text []byte) {
+	out.WriteString("\\textsubscript{")
+	out.Write(text)
+	out.WriteString("}")
+}
+
+func (options *Latex) Math(out *bytes.Buffer, text []byte) {
+	out.WriteString("$")
+	out.Write(text)
+	out.WriteString("$")
+}
+
+func (options *Latex) Highlight(out *bytes.Buffer, text []byte) {
+	out.WriteString("\\hl{")
+	out.Write(text)
+	out.WriteString("}")
+}
+
+func (options *Latex) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	out.Write(text)
+}
+
 // TODO: this
 func (options *Latex) FootnoteRef(out *bytes.Buffer, ref []byte, id int) {
 
 }
 
 func needsBackslash(c byte) bool {
-	for _, r := range []byte("_{}%$&\\~#") {
+	for _, r := range []byte("_{}%$&#") {
 		if c == r {
 			return true
 		}
@@ -269,12 +390,20 @@ func needsBackslash(c byte) bool {
 	return false
 }
 
+// latexSymbols holds the special characters that a backslash does not
+// escape, and the commands that typeset them instead
+var latexSymbols = map[byte]string{
+	'\\': "\\textbackslash{}",
+	'~':  "\\textasciitilde{}",
+	'^':  "\\textasciicircum{}",
+}
+
 func escapeSpecialChars(out *bytes.Buffer, text []byte) {
 	for i := 0; i < len(text); i++ {
 		// directly copy normal characters
 		org := i
 
-		for i < len(text) && !needsBackslash(text[i]) {
+		for i < len(text) && !needsBackslash(text[i]) && latexSymbols[text[i]] == "" {
 			i++
 		}
 		if i > org {
@@ -285,14 +414,29 @@ func escapeSpecialChars(out *bytes.Buffer, text []byte) {
 		if i >= len(text) {
 			break
 		}
+		if symbol := latexSymbols[text[i]]; symbol != "" {
+			out.WriteString(symbol)
+			continue
+		}
 		out.WriteByte('\\')
 		out.WriteByte(text[i])
 	}
 }
 
+// escapeHref writes a URL for the first argument of \href, where only the
+// characters hyperref would otherwise take as comments or parameters need
+// escaping
+func escapeHref(out *bytes.Buffer, link []byte) {
+	for _, c := range link {
+		if c == '#' || c == '%' || c == '\\' {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(c)
+	}
+}
+
 func (options *Latex) Entity(out *bytes.Buffer, entity []byte) {
-	// TODO: convert this into a unicode character or something
-	out.Write(entity)
+	escapeSpecialChars(out, []byte(html.UnescapeString(string(entity))))
 }
 
 func (options *Latex) NormalText(out *bytes.Buffer, text []byte) {
@@ -301,6 +445,9 @@ func (options *Latex) NormalText(out *bytes.Buffer, text []byte) {
 
 // header and footer
 func (options *Latex) DocumentHeader(out *bytes.Buffer) {
+	if options.flags&LATEX_COMPLETE_PAGE == 0 {
+		return
+	}
 	out.WriteString("\\documentclass{article}\n")
 	out.WriteString("\n")
 	out.WriteString("\\usepackage{graphicx}\n")
@@ -309,6 +456,7 @@ func (options *Latex) DocumentHeader(out *bytes.Buffer) {
 	out.WriteString("\\usepackage[utf8]{inputenc}\n")
 	out.WriteString("\\usepackage{verbatim}\n")
 	out.WriteString("\\usepackage[normalem]{ulem}\n")
+	out.WriteString("\\usepackage{soul}\n")
 	out.WriteString("\\usepackage{hyperref}\n")
 	out.WriteString("\n")
 	out.WriteString("\\hypersetup{colorlinks,%\n")
@@ -330,5 +478,8 @@ func (options *Latex) DocumentHeader(out *bytes.Buffer) {
 }
 
 func (options *Latex) DocumentFooter(out *bytes.Buffer) {
+	if options.flags&LATEX_COMPLETE_PAGE == 0 {
+		return
+	}
 	out.WriteString("\n\\end{document}\n")
 }
@@ -18,6 +18,9 @@ package blackfriday
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
 	"strings"
 	"unicode/utf8"
 )
@@ -44,6 +47,20 @@ const (
 	EXTENSION_BACKSLASH_LINE_BREAK                   // translate trailing backslashes into line breaks
 	EXTENSION_DEFINITION_LISTS                       // render definition lists
 	EXTENSION_JOIN_LINES                             // delete newline and join lines
+	EXTENSION_GRID_TABLES                            // render grid tables drawn with +, - and |
+	EXTENSION_TASK_LISTS                             // render list items starting with [ ] or [x] as checkboxes
+	EXTENSION_TABLE_CAPTIONS                         // a [Caption] line directly above or below a table is its caption
+	EXTENSION_SUPERSCRIPT                            // superscript text using ^text^
+	EXTENSION_SUBSCRIPT                              // subscript text using ~text~
+	EXTENSION_HIGHLIGHT                              // highlight text using ==text==
+	EXTENSION_MATH                                   // TeX math between $ and $, or $$ and $$ for display math
+	EXTENSION_EMOJI                                  // replace emoji shortcodes like :smile: with the emoji
+	EXTENSION_AUTOLINK_WWW                           // detect host names starting with www. and link them with http://
+	EXTENSION_ADMONITIONS                            // render !!! kind "title" blocks with indented content as admonitions
+	EXTENSION_WIKI_LINKS                             // link [[Page Name]] and [[Page Name|text]] to the page name's slug
+	EXTENSION_MENTIONS                               // link @username mentions, see Options.MentionURL
+	EXTENSION_HASHTAGS                               // link #tag hashtags, see Options.HashtagURL
+	EXTENSION_ALERTS                                 // render blockquotes starting with a [!NOTE] line, and the like, as GitHub-style alerts
 
 	commonHtmlFlags = 0 |
 		HTML_USE_XHTML |
@@ -83,6 +100,11 @@ const (
 	LIST_ITEM_CONTAINS_BLOCK
 	LIST_ITEM_BEGINNING_OF_LIST
 	LIST_ITEM_END_OF_LIST
+	LIST_ITEM_TASK
+	LIST_ITEM_CHECKED
+	LIST_DELIMITER_PAREN // the ordered list is numbered 1), 2), ... rather than 1., 2., ...
+	LIST_BULLET_PLUS     // the unordered list item is bulleted with + rather than *
+	LIST_BULLET_DASH     // the unordered list item is bulleted with - rather than *
 )
 
 // These are the possible flag values for the table cell renderer.
@@ -91,7 +113,31 @@ const (
 const (
 	TABLE_ALIGNMENT_LEFT = 1 << iota
 	TABLE_ALIGNMENT_RIGHT
-	TABLE_ALIGNMENT_CENTER = (TABLE_ALIGNMENT_LEFT | TABLE_ALIGNMENT_RIGHT)
+	TABLE_ALIGNMENT_CENTER  = (TABLE_ALIGNMENT_LEFT | TABLE_ALIGNMENT_RIGHT)
+	TABLE_ALIGNMENT_JUSTIFY = 1 << 2
+)
+
+// A table cell that spans several columns (only grid tables have those) has
+// the number of extra columns it spans stored in its flags, shifted left by
+// TABLE_CELL_SPAN_SHIFT. The bits below hold the alignment.
+const (
+	TABLE_CELL_SPAN_SHIFT = 8
+	TABLE_ALIGNMENT_MASK  = 1<<TABLE_CELL_SPAN_SHIFT - 1
+)
+
+// What to do with the cells of a table row beyond the columns of the header,
+// see Options.TableOverflow.
+const (
+	TABLE_OVERFLOW_DROP   = iota // drop them
+	TABLE_OVERFLOW_MERGE         // keep the rest of the row, pipes included, in the last cell
+	TABLE_OVERFLOW_EXTEND        // render them as extra cells aligned like the last column
+)
+
+// Which lines underlined with = or - are headers, see Options.SetextHeaders.
+const (
+	SETEXT_HEADERS_ANY            = iota // any underline makes a header
+	SETEXT_HEADERS_NONE                  // none: only # headers are headers
+	SETEXT_HEADERS_FULL_UNDERLINE        // the underline must be at least as long as the text
 )
 
 // The size of a tab stop.
@@ -100,6 +146,14 @@ const (
 	TAB_SIZE_EIGHT   = 8
 )
 
+// The default limit on how deeply blocks and inline elements can be nested,
+// see Options.MaxNesting. Content nested deeper than this is dropped.
+const MAX_NESTING_DEFAULT = 16
+
+// The default limit on how deeply lists can be nested, see
+// Options.MaxListNesting.
+const MAX_LIST_NESTING_DEFAULT = 32
+
 // blockTags is a set of tags that are recognized as HTML block tags.
 // Any of these can be included in markdown text without special escaping.
 var blockTags = map[string]struct{}{
@@ -199,10 +253,96 @@ type Renderer interface {
 	GetFlags() int
 }
 
+// LinkAttributesRenderer is implemented by renderers that can write the
+// attributes given right after a link with Options.LinkAttributes, as in
+// [text](url){target=_blank .button}. Other renderers get a plain Link.
+type LinkAttributesRenderer interface {
+	AttributedLink(out *bytes.Buffer, link []byte, title []byte, content []byte, classes []string, attrs map[string]string)
+}
+
+// ListStartRenderer is implemented by renderers that can start an ordered
+// list at the number of its first item, as in a list that starts with 3.
+// Other renderers get a plain List, numbered from 1.
+type ListStartRenderer interface {
+	NumberedList(out *bytes.Buffer, text func() bool, flags, start int)
+}
+
+// TableCaptionRenderer is implemented by renderers that can write the
+// caption of a table given with EXTENSION_TABLE_CAPTIONS, as in a [Caption]
+// line right above or below the table. Other renderers get a plain Table.
+type TableCaptionRenderer interface {
+	CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte)
+}
+
+// CodeSpanLanguageRenderer is implemented by renderers that can write the
+// language given right after a code span with Options.CodeSpanLanguages, as
+// in `fmt.Println()`{.go}. Other renderers get a plain CodeSpan.
+type CodeSpanLanguageRenderer interface {
+	LanguageCodeSpan(out *bytes.Buffer, text []byte, lang string)
+}
+
+// ScriptRenderer is implemented by renderers that can write superscripts and
+// subscripts, enabled by EXTENSION_SUPERSCRIPT and EXTENSION_SUBSCRIPT, as in
+// x^2^ and H~2~O. For other renderers they are left as normal text.
+type ScriptRenderer interface {
+	Superscript(out *bytes.Buffer, text []byte)
+	Subscript(out *bytes.Buffer, text []byte)
+}
+
+// HighlightRenderer is implemented by renderers that can write highlighted
+// text, enabled by EXTENSION_HIGHLIGHT, as in ==text==. For other renderers
+// it is left as normal text.
+type HighlightRenderer interface {
+	Highlight(out *bytes.Buffer, text []byte)
+}
+
+// MathRenderer is implemented by renderers that can write math, enabled by
+// EXTENSION_MATH, as in $a+b$ and $$ blocks. Other renderers get inline math
+// as a CodeSpan and math blocks as a BlockCode in the language "math".
+type MathRenderer interface {
+	Math(out *bytes.Buffer, text []byte)
+	MathBlock(out *bytes.Buffer, text []byte)
+}
+
+// AdmonitionRenderer is implemented by renderers that can write admonitions,
+// enabled by EXTENSION_ADMONITIONS, as in a !!! note block. Other renderers
+// get a BlockQuote that starts with a paragraph holding the title.
+type AdmonitionRenderer interface {
+	Admonition(out *bytes.Buffer, text []byte, kind string, title []byte)
+}
+
+// AlertRenderer is implemented by renderers that can write GitHub-style
+// alerts, enabled by EXTENSION_ALERTS, as in a blockquote starting with
+// [!NOTE]. Other renderers get a plain BlockQuote that keeps the marker.
+type AlertRenderer interface {
+	Alert(out *bytes.Buffer, text []byte, kind string)
+}
+
+// DivRenderer is implemented by renderers that can write containers with a
+// class, for fenced divs enabled by Options.FencedDivs, as in ::: warning.
+// Other renderers get the blocks inside the div without a container.
+type DivRenderer interface {
+	Div(out *bytes.Buffer, text []byte, class string)
+}
+
+// AbbreviationRenderer is implemented by renderers that can mark up the
+// abbreviations defined with Options.Abbreviations, as in *[HTML]: HyperText
+// Markup Language. For other renderers they are left as normal text.
+type AbbreviationRenderer interface {
+	Abbreviation(out *bytes.Buffer, text []byte, title []byte)
+}
+
 // Callback functions for inline parsing. One such function is defined
 // for each character that triggers a response when parsing inline data.
 type inlineParser func(p *parser, out *bytes.Buffer, data []byte, offset int) int
 
+// InlineParser parses custom inline markup, see Options.InlineParsers. It is
+// called with the text being parsed, data, and the offset of its trigger byte
+// in it; the text before offset has been rendered already. It writes its
+// output to out, usually through the methods of r, and returns the number of
+// bytes it used from offset on, or 0 if there is no markup there.
+type InlineParser func(out *bytes.Buffer, r Renderer, data []byte, offset int) int
+
 // Parser holds runtime state used by the parser.
 // This is constructed by the Markdown function.
 type parser struct {
@@ -213,13 +353,85 @@ type parser struct {
 	flags          int
 	nesting        int
 	maxNesting     int
+	listNesting    int
+	maxListNesting int
 	insideLink     bool
 
 	// Footnotes need to be ordered as well as available to quickly check for
 	// presence. If a ref is also a footnote, it's stored both in refs and here
 	// in notes. Slice is nil if footnotes not enabled.
 	notes       []*reference
-	notesRecord map[string]struct{}
+	notesRecord map[string]*reference
+
+	// Inline footnotes by their whole text.
+	inlineNotes map[string]*reference
+
+	// If set, footnotes are numbered and listed by label rather than in the
+	// order they are first referenced.
+	sortFootnotes bool
+
+	// code spans can be followed by a language attribute
+	codeSpanLanguages bool
+
+	// newlines are line breaks inside paragraphs
+	paragraphLineBreaks bool
+	inParagraph         bool
+
+	// handling of table rows with too many cells
+	tableOverflow int
+
+	// which underlined lines are headers
+	setextHeaders int
+
+	// table rows ending in a backslash continue on the next line
+	tableLineContinuation bool
+
+	// extra emoji shortcodes
+	emoji map[string]string
+
+	// extra URL schemes to autolink
+	autolinkSchemes []string
+
+	// compose combining marks in text, and in code unless skipped
+	normalizeUnicode         bool
+	normalizeUnicodeSkipCode bool
+
+	// creates header IDs from header text
+	headerIDSlugifier func(text string) string
+
+	// link destinations of @mentions and #hashtags
+	mentionURL func(name string) string
+	hashtagURL func(tag string) string
+
+	// {key=value .class} blocks after links are their attributes
+	linkAttrs bool
+
+	// ::: name fenced divs are containers
+	fencedDivs bool
+
+	// *[ABBR]: definition lines define abbreviations
+	parseAbbreviations bool
+
+	// definitions of abbreviations, and the abbreviations, longest first
+	abbreviations map[string][]byte
+	abbrs         []string
+}
+
+// normalize applies NFC normalization to text if it is enabled.
+func (p *parser) normalize(text []byte) []byte {
+	if !p.normalizeUnicode {
+		return text
+	}
+	return composeNFC(text)
+}
+
+// normalizeCode applies NFC normalization to the content of code spans and
+// code blocks if it is enabled for them.
+func (p *parser) normalizeCode(text []byte) []byte {
+	if p.normalizeUnicodeSkipCode {
+		return text
+	}
+	return p.normalize(text)
 }
 
 func (p *parser) getRef(refid string) (ref *reference, found bool) {
@@ -237,8 +449,21 @@ func (p *parser) getRef(refid string) (ref *reference, found bool) {
 				text:     []byte(r.Text)}, true
 		}
 	}
-	// refs are case insensitive
-	ref, found = p.refs[strings.ToLower(refid)]
+	ref, found = p.refs[normalizeRefId(refid)]
+	return ref, found
+}
+
+// normalizeRefId returns the key a link reference is stored under. Matches
+// are case insensitive, and runs of whitespace in the id match a single
+// space.
+func normalizeRefId(id string) string {
+	return strings.ToLower(strings.Join(strings.Fields(id), " "))
+}
+
+// getFootnoteRef finds the definition of a footnote. Unlike link references,
+// footnote labels are case sensitive.
+func (p *parser) getFootnoteRef(label string) (ref *reference, found bool) {
+	ref, found = p.refs["^"+label]
 	return ref, found
 }
 
@@ -293,6 +518,145 @@ type Options struct {
 	// the override function indicates an override did not occur, the refids at
 	// the bottom will be used to fill in the link details.
 	ReferenceOverride ReferenceOverrideFunc
+
+	// SortFootnotes controls the order of the footnotes list when the
+	// EXTENSION_FOOTNOTES extension is enabled. By default footnotes are
+	// numbered and listed in the order they are first referenced; if this is
+	// set, they are sorted alphabetically by label instead, and the reference
+	// numbers in the text follow the sorted order.
+	SortFootnotes bool
+
+	// MaxNesting limits how deeply blockquotes, lists and other recursive
+	// blocks, and emphasis, links and other inline elements, can be nested.
+	// Content nested deeper than this is dropped from the output. Zero means
+	// MAX_NESTING_DEFAULT.
+	MaxNesting int
+
+	// MaxListNesting limits how deeply lists can be nested. Lists up to this
+	// depth are counted separately and don't use up the general nesting limit
+	// that bounds blockquotes and other recursive blocks, so deep outlines
+	// are not truncated. Lists nested deeper than this count against the
+	// general limit. Zero means MAX_LIST_NESTING_DEFAULT.
+	MaxListNesting int
+
+	// CodeSpanLanguages allows a code span to be followed by a language
+	// attribute, as in `fmt.Println()`{.go}, which is passed on to renderers
+	// that implement CodeSpanLanguageRenderer. The HTML renderer adds it as a
+	// language- class on the <code> element, like for fenced code blocks.
+	CodeSpanLanguages bool
+
+	// ParagraphLineBreaks translates the newlines inside paragraphs into
+	// line breaks, preserving the lines of hard-wrapped text. Unlike
+	// EXTENSION_HARD_LINE_BREAK, it leaves text outside of paragraphs, such as
+	// the items of tight lists, alone.
+	ParagraphLineBreaks bool
+
+	// TableOverflow selects what happens to the cells of a table row beyond
+	// the columns defined by the header. By default (TABLE_OVERFLOW_DROP)
+	// they are silently dropped.
+	TableOverflow int
+
+	// TableLineContinuation joins a table row that ends in a backslash with
+	// the next line, so long cells can be wrapped:
+	//
+	//	Name | Description
+	//	-----|------------
+	//	foo  | a long description \
+	//	       that goes on
+	//
+	// The continuation line needs no pipes; it is appended to the row after
+	// a space, and the row is split into cells after joining, so escaped
+	// pipes work as usual. A blank line ends the table as always. Header
+	// rows are not joined: the line after the header must be the separator
+	// line for the table to be recognized at all.
+	TableLineContinuation bool
+
+	// NormalizeUnicode composes letters followed by combining marks into
+	// their precomposed forms (Unicode NFC), so that text, code and
+	// generated header IDs are the same whether the input was precomposed
+	// or decomposed. Only the Latin, Greek and Cyrillic compositions are
+	// known. Link destinations and raw HTML are left alone.
+	NormalizeUnicode bool
+
+	// AutolinkSchemes adds URL schemes, such as "tel" or "myapp", that are
+	// autolinked by EXTENSION_AUTOLINK besides http, https, ftp and mailto.
+	// The links need no // after the colon, as in tel:+15551234. Schemes
+	// match case-insensitively, and only at the start of a word.
+	AutolinkSchemes []string
+
+	// Emoji adds emoji shortcodes, without the colons, to the built-in ones
+	// used by EXTENSION_EMOJI, or overrides them. The values are the text
+	// that replaces the shortcode.
+	Emoji map[string]string
+
+	// NormalizeUnicodeSkipCode leaves the content of code spans and code
+	// blocks unnormalized when NormalizeUnicode is set.
+	NormalizeUnicodeSkipCode bool
+
+	// HeaderIDSlugifier creates the header IDs generated by
+	// EXTENSION_AUTO_HEADER_IDS from the header text, in place of
+	// SanitizedAnchorName. It is called once per header, and the HTML
+	// renderer uses its result for both the anchor and the table of contents
+	// link. Explicit {#id} IDs are used as they are.
+	HeaderIDSlugifier func(text string) string
+
+	// FrontMatter, if set, turns on the detection of YAML front matter: a
+	// document whose very first line is --- starts with a block of YAML
+	// that runs up to the next --- line. The block is removed from the
+	// document and its raw content, without the fences, is passed to
+	// FrontMatter before rendering. Without a closing line the document is
+	// rendered as usual and FrontMatter is not called.
+	FrontMatter func(yaml []byte)
+
+	// SkipInline parses only the block structure of the document. The text
+	// of headers, paragraphs, list items and table cells is passed to the
+	// renderer's NormalText as it is, without looking for emphasis, links,
+	// code spans or any other inline markup. This saves the work for callers
+	// that only want the blocks, such as the headers or the code blocks. The
+	// HTML renderer escapes the text, so the inline markup shows as written.
+	SkipInline bool
+
+	// InlineParsers adds parsers for custom inline markup, such as @mentions,
+	// each called where its trigger byte is found in text. A custom parser
+	// comes before the built-in one for the same byte, which is only tried
+	// if the custom parser returns 0.
+	InlineParsers map[byte]InlineParser
+
+	// MentionURL returns the destination of the link made of a @username
+	// mention by EXTENSION_MENTIONS, given the name without the @. If it is
+	// nil, mentions link to /username.
+	MentionURL func(name string) string
+
+	// HashtagURL returns the destination of the link made of a #tag by
+	// EXTENSION_HASHTAGS, given the tag without the #. If it is nil,
+	// hashtags link to /tags/tag.
+	HashtagURL func(tag string) string
+
+	// SetextHeaders selects which lines underlined with = or - are headers.
+	// By default (SETEXT_HEADERS_ANY) any underline is enough. With
+	// SETEXT_HEADERS_NONE the underline is part of the paragraph, or a
+	// horizontal rule if made of dashes, and with
+	// SETEXT_HEADERS_FULL_UNDERLINE it must be at least as long as the text
+	// above it.
+	SetextHeaders int
+
+	// FencedDivs renders Pandoc-style fenced divs as containers: the blocks
+	// between a line of three or more colons and a class name, as in
+	// ::: warning, or {.class} attributes, and a line of colons alone are
+	// wrapped in a div with that class. Fenced divs nest.
+	FencedDivs bool
+
+	// Abbreviations turns on Markdown Extra-style abbreviations: definition
+	// lines such as *[HTML]: HyperText Markup Language are removed from the
+	// document, and every whole-word occurrence of the abbreviation in the
+	// text is passed to the renderer's Abbreviation with its definition.
+	Abbreviations bool
+
+	// LinkAttributes takes a block of attributes right after a link, as in
+	// [text](url){target=_blank .button}, and passes its classes and other
+	// attributes to renderers that implement LinkAttributesRenderer. Other
+	// renderers drop them.
+	LinkAttributes bool
 }
 
 // MarkdownBasic is a convenience function for simple rendering.
@@ -359,8 +723,40 @@ func MarkdownOptions(input []byte, renderer Renderer, opts Options) []byte {
 	p.r = renderer
 	p.flags = extensions
 	p.refOverride = opts.ReferenceOverride
+	p.sortFootnotes = opts.SortFootnotes
+	p.codeSpanLanguages = opts.CodeSpanLanguages
+	p.paragraphLineBreaks = opts.ParagraphLineBreaks
+	p.tableOverflow = opts.TableOverflow
+	p.setextHeaders = opts.SetextHeaders
+	p.tableLineContinuation = opts.TableLineContinuation
+	p.emoji = opts.Emoji
+	p.autolinkSchemes = opts.AutolinkSchemes
+	p.normalizeUnicode = opts.NormalizeUnicode
+	p.normalizeUnicodeSkipCode = opts.NormalizeUnicodeSkipCode
+	p.headerIDSlugifier = opts.HeaderIDSlugifier
+	if p.headerIDSlugifier == nil {
+		p.headerIDSlugifier = SanitizedAnchorName
+	}
+	p.mentionURL = opts.MentionURL
+	if p.mentionURL == nil {
+		p.mentionURL = func(name string) string { return "/" + name }
+	}
+	p.hashtagURL = opts.HashtagURL
+	if p.hashtagURL == nil {
+		p.hashtagURL = func(tag string) string { return "/tags/" + tag }
+	}
+	p.linkAttrs = opts.LinkAttributes
+	p.fencedDivs = opts.FencedDivs
+	p.parseAbbreviations = opts.Abbreviations
 	p.refs = make(map[string]*reference)
-	p.maxNesting = 16
+	p.maxNesting = opts.MaxNesting
+	if p.maxNesting <= 0 {
+		p.maxNesting = MAX_NESTING_DEFAULT
+	}
+	p.maxListNesting = opts.MaxListNesting
+	if p.maxListNesting <= 0 {
+		p.maxListNesting = MAX_LIST_NESTING_DEFAULT
+	}
 	p.insideLink = false
 
 	// register inline parsers
@@ -369,20 +765,64 @@ func MarkdownOptions(input []byte, renderer Renderer, opts Options) []byte {
 	if extensions&EXTENSION_STRIKETHROUGH != 0 {
 		p.inlineCallback['~'] = emphasis
 	}
+	if extensions&EXTENSION_SUBSCRIPT != 0 {
+		p.inlineCallback['~'] = subscript
+	}
+	if extensions&EXTENSION_HIGHLIGHT != 0 {
+		p.inlineCallback['='] = emphasis
+	}
+	if extensions&EXTENSION_MATH != 0 {
+		p.inlineCallback['$'] = inlineMath
+	}
 	p.inlineCallback['`'] = codeSpan
 	p.inlineCallback['\n'] = lineBreak
 	p.inlineCallback['['] = link
+	if extensions&EXTENSION_WIKI_LINKS != 0 {
+		p.inlineCallback['['] = wikiLink
+	}
 	p.inlineCallback['<'] = leftAngle
 	p.inlineCallback['\\'] = escape
 	p.inlineCallback['&'] = entity
+	if extensions&EXTENSION_SUPERSCRIPT != 0 {
+		p.inlineCallback['^'] = superscript
+	}
 
 	if extensions&EXTENSION_AUTOLINK != 0 {
 		p.inlineCallback[':'] = autoLink
 	}
+	if extensions&EXTENSION_EMOJI != 0 {
+		p.inlineCallback[':'] = emoji
+	}
+	if extensions&EXTENSION_AUTOLINK_WWW != 0 {
+		p.inlineCallback['w'] = wwwAutoLink
+		p.inlineCallback['W'] = wwwAutoLink
+	}
+	if extensions&EXTENSION_MENTIONS != 0 {
+		p.inlineCallback['@'] = mention
+	}
+	if extensions&EXTENSION_HASHTAGS != 0 {
+		p.inlineCallback['#'] = hashtag
+	}
+
+	for c, parse := range opts.InlineParsers {
+		p.inlineCallback[c] = customInline(parse, p.inlineCallback[c])
+	}
+
+	if opts.SkipInline {
+		p.inlineCallback = [256]inlineParser{}
+	}
 
 	if extensions&EXTENSION_FOOTNOTES != 0 {
 		p.notes = make([]*reference, 0)
-		p.notesRecord = make(map[string]struct{})
+		p.notesRecord = make(map[string]*reference)
+		p.inlineNotes = make(map[string]*reference)
+	}
+
+	if opts.FrontMatter != nil {
+		if yaml, end := frontMatter(input); end > 0 {
+			opts.FrontMatter(yaml)
+			input = input[end:]
+		}
 	}
 
 	first := firstPass(p, input)
@@ -390,6 +830,78 @@ func MarkdownOptions(input []byte, renderer Renderer, opts Options) []byte {
 	return second
 }
 
+// customInline wraps an InlineParser, falling back to the built-in parser for
+// its trigger byte, if there is one
+func customInline(parse InlineParser, builtin inlineParser) inlineParser {
+	return func(p *parser, out *bytes.Buffer, data []byte, offset int) int {
+		if consumed := parse(out, p.r, data, offset); consumed > 0 {
+			return consumed
+		}
+		if builtin != nil {
+			return builtin(p, out, data, offset)
+		}
+		return 0
+	}
+}
+
+// MarkdownWriter is like MarkdownOptions but writes the rendered document to
+// w, for example an http.ResponseWriter, and returns any error from writing.
+// Nothing is written if renderer is nil.
+//
+// It is only a convenience wrapper and does not stream: the whole output is
+// built in memory, then written with a single call. The renderers can change
+// what they already wrote while finishing the document, as Html does with the
+// table of contents and Md with the trailing newlines, so no part of it is
+// final before the end.
+func MarkdownWriter(w io.Writer, input []byte, renderer Renderer, opts Options) error {
+	output := MarkdownOptions(input, renderer, opts)
+	if len(output) == 0 {
+		return nil
+	}
+	_, err := w.Write(output)
+	return err
+}
+
+// MarkdownReader is like MarkdownOptions but reads the input from r, and
+// returns any error from reading. The whole input is read into memory before
+// parsing starts, since the block parser looks ahead and back in it; line
+// endings are normalized as for the other entry points.
+func MarkdownReader(r io.Reader, renderer Renderer, opts Options) ([]byte, error) {
+	input, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return MarkdownOptions(input, renderer, opts), nil
+}
+
+// frontMatter finds a block of YAML front matter fenced by --- lines at the
+// start of data. It returns the YAML between the fences and the length of the
+// whole block, or 0 if data doesn't start with front matter.
+func frontMatter(data []byte) ([]byte, int) {
+	isFence := func(line []byte) bool {
+		return bytes.Equal(bytes.TrimRight(line, " \t\r"), []byte("---"))
+	}
+	eol := bytes.IndexByte(data, '\n')
+	if eol < 0 || !isFence(data[:eol]) {
+		return nil, 0
+	}
+	start := eol + 1
+	for beg := start; beg < len(data); {
+		end := beg
+		for end < len(data) && data[end] != '\n' {
+			end++
+		}
+		if isFence(data[beg:end]) {
+			if end < len(data) {
+				end++
+			}
+			return data[start:beg], end
+		}
+		beg = end + 1
+	}
+	return nil, 0
+}
+
 // first pass:
 // - normalize newlines
 // - extract references (outside of fenced code blocks)
@@ -401,12 +913,16 @@ func firstPass(p *parser, input []byte) []byte {
 	if p.flags&EXTENSION_TAB_SIZE_EIGHT != 0 {
 		tabSize = TAB_SIZE_EIGHT
 	}
+	// normalize newlines first, so that the lookahead for fenced code
+	// blocks below sees the same lines as the block parser
+	input = normalizeNewlines(input)
+
 	beg := 0
 	lastFencedCodeBlockEnd := 0
 	for beg < len(input) {
 		// Find end of this line, then process the line.
 		end := beg
-		for end < len(input) && input[end] != '\n' && input[end] != '\r' {
+		for end < len(input) && input[end] != '\n' {
 			end++
 		}
 
@@ -427,14 +943,14 @@ func firstPass(p *parser, input []byte) []byte {
 			} else if refEnd := isReference(p, input[beg:], tabSize); refEnd > 0 {
 				beg += refEnd
 				continue
+			} else if abbrEnd := isAbbreviation(p, input[beg:]); abbrEnd > 0 {
+				beg += abbrEnd
+				continue
 			} else {
 				expandTabs(&out, input[beg:end], tabSize)
 			}
 		}
 
-		if end < len(input) && input[end] == '\r' {
-			end++
-		}
 		if end < len(input) && input[end] == '\n' {
 			end++
 		}
@@ -455,38 +971,67 @@ func firstPass(p *parser, input []byte) []byte {
 func secondPass(p *parser, input []byte) []byte {
 	var output bytes.Buffer
 
-	p.r.DocumentHeader(&output)
-	p.block(&output, input)
-
-	if p.flags&EXTENSION_FOOTNOTES != 0 && len(p.notes) > 0 {
-		p.r.Footnotes(&output, func() bool {
-			flags := LIST_ITEM_BEGINNING_OF_LIST
-			for i := 0; i < len(p.notes); i += 1 {
-				ref := p.notes[i]
-				var buf bytes.Buffer
-				if ref.hasBlock {
-					flags |= LIST_ITEM_CONTAINS_BLOCK
-					p.block(&buf, ref.title)
-				} else {
-					p.inline(&buf, ref.title)
-				}
-				p.r.FootnoteItem(&output, ref.link, buf.Bytes(), flags)
-				flags &^= LIST_ITEM_BEGINNING_OF_LIST | LIST_ITEM_CONTAINS_BLOCK
-			}
-
-			return true
-		})
+	if p.flags&EXTENSION_FOOTNOTES != 0 && p.sortFootnotes {
+		p.sortNotes(input)
 	}
 
+	p.r.DocumentHeader(&output)
+	p.block(&output, input)
+	p.footnotes(&output)
 	p.r.DocumentFooter(&output)
 
-	if p.nesting != 0 {
+	if p.nesting != 0 || p.listNesting != 0 {
 		panic("Nesting level did not end at zero")
 	}
 
 	return output.Bytes()
 }
 
+// render the list of footnotes referenced in the document
+func (p *parser) footnotes(out *bytes.Buffer) {
+	if p.flags&EXTENSION_FOOTNOTES == 0 || len(p.notes) == 0 {
+		return
+	}
+
+	p.r.Footnotes(out, func() bool {
+		flags := LIST_ITEM_BEGINNING_OF_LIST
+		for i := 0; i < len(p.notes); i += 1 {
+			ref := p.notes[i]
+			var buf bytes.Buffer
+			if ref.hasBlock {
+				flags |= LIST_ITEM_CONTAINS_BLOCK
+				p.block(&buf, ref.title)
+			} else {
+				p.inline(&buf, ref.title)
+			}
+			p.r.FootnoteItem(out, ref.link, buf.Bytes(), flags)
+			flags &^= LIST_ITEM_BEGINNING_OF_LIST | LIST_ITEM_CONTAINS_BLOCK
+		}
+
+		return true
+	})
+}
+
+// Footnotes are numbered as they are referenced, so the only way to know
+// which of them end up in the list (footnotes can reference each other) is
+// a dry run of the second pass. Once they are all known, they are sorted by
+// label and renumbered; the real pass then reuses those numbers.
+func (p *parser) sortNotes(input []byte) {
+	var discard bytes.Buffer
+	r := p.r
+	p.r = nullRenderer{}
+	p.block(&discard, input)
+	p.footnotes(&discard)
+	p.r = r
+
+	sort.SliceStable(p.notes, func(i, j int) bool {
+		return bytes.Compare(p.notes[i].link, p.notes[j].link) < 0
+	})
+	for i, ref := range p.notes {
+		ref.noteId = i + 1
+	}
+}
+
 //
 // Link references
 //
@@ -626,14 +1171,72 @@ func isReference(p *parser, data []byte, tabSize int) int {
 		ref.title = data[titleOffset:titleEnd]
 	}
 
-	// id matches are case-insensitive
-	id := string(bytes.ToLower(data[idOffset:idEnd]))
+	// id matches are case-insensitive, except for footnotes, which are kept
+	// apart from the links
+	id := normalizeRefId(string(data[idOffset:idEnd]))
+	if noteId > 0 {
+		id = "^" + string(data[idOffset:idEnd])
+	}
 
 	p.refs[id] = ref
 
 	return lineEnd
 }
 
+// Check whether or not data starts with an abbreviation definition, as in
+// *[HTML]: HyperText Markup Language. If so, it is stored in the parser.
+// Returns the number of bytes to skip to move past it, or zero if the first
+// line is not an abbreviation definition.
+func isAbbreviation(p *parser, data []byte) int {
+	if !p.parseAbbreviations {
+		return 0
+	}
+
+	// up to 3 optional leading spaces
+	i := 0
+	for i < 3 && i < len(data) && data[i] == ' ' {
+		i++
+	}
+	if i+1 >= len(data) || data[i] != '*' || data[i+1] != '[' {
+		return 0
+	}
+	i += 2
+	abbrOffset := i
+	for i < len(data) && data[i] != '\n' && data[i] != ']' {
+		i++
+	}
+	if i+1 >= len(data) || data[i] != ']' || data[i+1] != ':' {
+		return 0
+	}
+	abbr := strings.TrimSpace(string(data[abbrOffset:i]))
+	if abbr == "" {
+		return 0
+	}
+
+	// the definition is the rest of the line
+	i = skipChar(data, i+2, ' ')
+	titleOffset := i
+	i = skipUntilChar(data, i, '\n')
+	title := bytes.TrimSpace(data[titleOffset:i])
+	if i < len(data) {
+		i++
+	}
+
+	if p.abbreviations == nil {
+		p.abbreviations = make(map[string][]byte)
+	}
+	if _, ok := p.abbreviations[abbr]; !ok {
+		// keep the longest abbreviations first, so they win over their prefixes
+		j := sort.Search(len(p.abbrs), func(j int) bool { return len(p.abbrs[j]) < len(abbr) })
+		p.abbrs = append(p.abbrs, "")
+		copy(p.abbrs[j+1:], p.abbrs[j:])
+		p.abbrs[j] = abbr
+	}
+	p.abbreviations[abbr] = title
+
+	return i
+}
+
 func scanLinkRef(p *parser, data []byte, i int) (linkOffset, linkEnd, titleOffset, titleEnd, lineEnd int) {
 	// link: whitespace-free sequence, optionally between angle brackets
 	if data[i] == '<' {
@@ -828,6 +1431,22 @@ func isalnum(c byte) bool {
 	return (c >= '0' && c <= '9') || isletter(c)
 }
 
+// Replace \r\n and lone \r line endings with \n.
+func normalizeNewlines(input []byte) []byte {
+	if bytes.IndexByte(input, '\r') < 0 {
+		return input
+	}
+	out := make([]byte, 0, len(input))
+	for i := 0; i < len(input); i++ {
+		if input[i] != '\r' {
+			out = append(out, input[i])
+		} else if i+1 == len(input) || input[i+1] != '\n' {
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
+
 // Replace tab characters with spaces, aligning to the next TAB_SIZE column.
 // always ends output with a newline
 func expandTabs(out *bytes.Buffer, line []byte, tabSize int) {
@@ -908,34 +1527,79 @@ func isIndented(data []byte, indentSize int) int {
 
 // Create a url-safe slug for fragments
 func slugify(in []byte) []byte {
-	if len(in) == 0 {
-		return in
-	}
-	out := make([]byte, 0, len(in))
-	sym := false
+	return appendSlug(nil, in)
+}
 
+// appendSlug appends the slug of in to out and returns the extended slice,
+// so callers on hot paths can reuse a buffer. Runs of symbols become a single
+// dash, and there are no dashes at either end; input without letters or
+// digits gives an empty slug.
+func appendSlug(out, in []byte) []byte {
+	start := len(out)
+	sym := false
 	for _, ch := range in {
 		if isalnum(ch) {
 			sym = false
 			out = append(out, ch)
-		} else if sym {
-			continue
-		} else {
+		} else if !sym && len(out) > start {
 			out = append(out, '-')
 			sym = true
 		}
 	}
-	var a, b int
-	var ch byte
-	for a, ch = range out {
-		if ch != '-' {
-			break
-		}
-	}
-	for b = len(out) - 1; b > 0; b-- {
-		if out[b] != '-' {
-			break
-		}
+	if sym {
+		out = out[:len(out)-1]
 	}
-	return out[a : b+1]
+	return out
 }
+
+//
+//
+// Null renderer
+//
+//
+
+// nullRenderer satisfies the Renderer interface but produces no output. It
+// still runs all the callbacks, so the parser sees the whole document; this
+// is used for dry runs that only collect state.
+type nullRenderer struct{}
+
+func (nullRenderer) BlockCode(out *bytes.Buffer, text []byte, infoString string)          {}
+func (nullRenderer) BlockQuote(out *bytes.Buffer, text []byte)                            {}
+func (nullRenderer) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {}
+func (nullRenderer) Alert(out *bytes.Buffer, text []byte, kind string)                    {}
+func (nullRenderer) Div(out *bytes.Buffer, text []byte, class string)                     {}
+func (nullRenderer) BlockHtml(out *bytes.Buffer, text []byte)                             {}
+func (nullRenderer) MathBlock(out *bytes.Buffer, text []byte)                             {}
+func (nullRenderer) Header(out *bytes.Buffer, text func() bool, level int, id string)     { text() }
+func (nullRenderer) HRule(out *bytes.Buffer)                                              {}
+func (nullRenderer) List(out *bytes.Buffer, text func() bool, flags int)                  { text() }
+func (nullRenderer) ListItem(out *bytes.Buffer, text []byte, flags int)                   {}
+func (nullRenderer) Paragraph(out *bytes.Buffer, text func() bool)                        { text() }
+func (nullRenderer) Table(out *bytes.Buffer, header, body []byte, columnData []int)       {}
+func (nullRenderer) TableRow(out *bytes.Buffer, text []byte)                              {}
+func (nullRenderer) TableHeaderCell(out *bytes.Buffer, text []byte, flags int)            {}
+func (nullRenderer) TableCell(out *bytes.Buffer, text []byte, flags int)                  {}
+func (nullRenderer) Footnotes(out *bytes.Buffer, text func() bool)                        { text() }
+func (nullRenderer) FootnoteItem(out *bytes.Buffer, name, text []byte, flags int)         {}
+func (nullRenderer) TitleBlock(out *bytes.Buffer, text []byte)                            {}
+func (nullRenderer) AutoLink(out *bytes.Buffer, link []byte, kind int)                    {}
+func (nullRenderer) CodeSpan(out *bytes.Buffer, text []byte)                              {}
+func (nullRenderer) DoubleEmphasis(out *bytes.Buffer, text []byte)                        {}
+func (nullRenderer) Emphasis(out *bytes.Buffer, text []byte)                              {}
+func (nullRenderer) Image(out *bytes.Buffer, link, title, alt []byte)                     {}
+func (nullRenderer) LineBreak(out *bytes.Buffer)                                          {}
+func (nullRenderer) Link(out *bytes.Buffer, link, title, content []byte)                  {}
+func (nullRenderer) RawHtmlTag(out *bytes.Buffer, tag []byte)                             {}
+func (nullRenderer) TripleEmphasis(out *bytes.Buffer, text []byte)                        {}
+func (nullRenderer) StrikeThrough(out *bytes.Buffer, text []byte)                         {}
+func (nullRenderer) Superscript(out *bytes.Buffer, text []byte)                           {}
+func (nullRenderer) Subscript(out *bytes.Buffer, text []byte)                             {}
+func (nullRenderer) Highlight(out *bytes.Buffer, text []byte)                             {}
+func (nullRenderer) Math(out *bytes.Buffer, text []byte)                                  {}
+func (nullRenderer) FootnoteRef(out *bytes.Buffer, ref []byte, id int)                    {}
+func (nullRenderer) Abbreviation(out *bytes.Buffer, text []byte, title []byte)            {}
+func (nullRenderer) Entity(out *bytes.Buffer, entity []byte)                              {}
+func (nullRenderer) NormalText(out *bytes.Buffer, text []byte)                            {}
+func (nullRenderer) DocumentHeader(out *bytes.Buffer)                                     {}
+func (nullRenderer) DocumentFooter(out *bytes.Buffer)                                     {}
+func (nullRenderer) GetFlags() int                                                        { return 0 }
@@ -0,0 +1,763 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// Markdown rendering backend
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Markdown renderer configuration options.
+const (
+	MD_PRESERVE_BULLETS = 1 << iota // keep the +, - or * bullet of each list item instead of always using *
+	MD_REFERENCE_LINKS              // write links and images as numbered references, defined at the end
+)
+
+// Md is a type that implements the Renderer interface for Markdown output.
+// Rendering a document back to Markdown normalizes it: lists use "*"
+// bullets, unless MD_PRESERVE_BULLETS is set, and "1." numbering that starts
+// at the list's first number, code blocks are fenced, headers use the ATX
+// style, reference links become inline links, and runs of whitespace are
+// collapsed. With MD_REFERENCE_LINKS, links and images are written as
+// numbered references instead, and their definitions follow the document.
+// Literal characters that would otherwise be interpreted are escaped.
+//
+// Do not create this directly, instead use the MarkdownRenderer function.
+type Md struct {
+	extensions int
+	flags      int // MD_* options
+
+	// the next number of each list being rendered
+	listNumbers []int
+
+	// whether the last list item was a definition term
+	afterTerm bool
+
+	// the destinations of MD_REFERENCE_LINKS, in the order of their numbers
+	refs       []mdRef
+	refNumbers map[string]int
+
+	// if set, written in the boxes of unchecked task items, and the next
+	// byte in those of checked ones, for SetTaskChecked to find them
+	taskMark byte
+}
+
+type mdRef struct {
+	link, title []byte
+}
+
+// MarkdownRenderer creates and configures an Md object, which satisfies the
+// Renderer interface.
+//
+// extensions is the set of EXTENSION_* options the output is meant to be
+// parsed with. It decides which characters need escaping and how code
+// blocks are written, so it should match the extensions the input was parsed
+// with.
+func MarkdownRenderer(extensions int) Renderer {
+	return &Md{extensions: extensions}
+}
+
+// MarkdownRendererWithFlags is like MarkdownRenderer, with flags, a set of
+// MD_* options ORed together.
+func MarkdownRendererWithFlags(extensions int, flags int) Renderer {
+	return &Md{extensions: extensions, flags: flags}
+}
+
+func (options *Md) GetFlags() int {
+	return options.flags
+}
+
+// write each line of text with prefix, leaving blank lines empty
+func writePrefixed(out *bytes.Buffer, text []byte, first, rest string) {
+	for i, line := range bytes.Split(bytes.TrimRight(text, "\n"), []byte("\n")) {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		switch {
+		case i == 0:
+			out.WriteString(first)
+		case len(line) > 0:
+			out.WriteString(rest)
+		}
+		out.Write(line)
+	}
+	out.WriteByte('\n')
+}
+
+// returns the longest run of c in text
+func longestRun(text []byte, c byte) int {
+	longest, run := 0, 0
+	for _, b := range text {
+		if b != c {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
+
+func (options *Md) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	blockSeparator(out)
+	if options.extensions&EXTENSION_FENCED_CODE == 0 {
+		writePrefixed(out, text, "    ", "    ")
+		return
+	}
+	fence := "```"
+	if n := longestRun(text, '`'); n >= len(fence) {
+		fence = string(bytes.Repeat([]byte("`"), n+1))
+	}
+	out.WriteString(fence)
+	out.WriteString(info)
+	out.WriteByte('\n')
+	writeLine(out, text)
+	out.WriteString(fence)
+	out.WriteByte('\n')
+}
+
+func (options *Md) BlockQuote(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	text = bytes.Trim(text, "\n")
+	for i, line := range bytes.Split(text, []byte("\n")) {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		if len(line) == 0 {
+			out.WriteByte('>')
+			continue
+		}
+		out.WriteString("> ")
+		out.Write(line)
+	}
+	out.WriteByte('\n')
+}
+
+func (options *Md) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {
+	blockSeparator(out)
+	out.WriteString("!!! ")
+	out.WriteString(kind)
+	out.WriteString(" \"")
+	out.Write(title)
+	out.WriteString("\"\n")
+	text = bytes.Trim(text, "\n")
+	if len(text) == 0 {
+		return
+	}
+	out.WriteByte('\n')
+	for _, line := range bytes.Split(text, []byte("\n")) {
+		if len(line) > 0 {
+			out.WriteString("    ")
+			out.Write(line)
+		}
+		out.WriteByte('\n')
+	}
+}
+
+func (options *Md) Alert(out *bytes.Buffer, text []byte, kind string) {
+	var quoted bytes.Buffer
+	quoted.WriteString("[!")
+	quoted.Write(bytes.ToUpper([]byte(kind)))
+	quoted.WriteString("]\n")
+	quoted.Write(bytes.TrimLeft(text, "\n"))
+	options.BlockQuote(out, quoted.Bytes())
+}
+
+func (options *Md) Div(out *bytes.Buffer, text []byte, class string) {
+	blockSeparator(out)
+	out.WriteString("::: ")
+	if strings.Contains(class, " ") {
+		out.WriteString("{." + strings.Replace(class, " ", " .", -1) + "}")
+	} else {
+		out.WriteString(class)
+	}
+	out.WriteByte('\n')
+	if text = bytes.Trim(text, "\n"); len(text) > 0 {
+		writeLine(out, text)
+	}
+	out.WriteString(":::\n")
+}
+
+func (options *Md) BlockHtml(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	writeLine(out, text)
+}
+
+func (options *Md) MathBlock(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	out.WriteString("$$\n")
+	writeLine(out, text)
+	out.WriteString("$$\n")
+}
+
+func (options *Md) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	marker := out.Len()
+	blockSeparator(out)
+	out.Write(bytes.Repeat([]byte("#"), level))
+	out.WriteByte(' ')
+	start := out.Len()
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+
+	// a trailing # would be taken for a closing sequence
+	if out.Len() > start && out.Bytes()[out.Len()-1] == '#' {
+		out.Truncate(out.Len() - 1)
+		out.WriteString("\\#")
+	}
+	if id != "" && options.extensions&EXTENSION_HEADER_IDS != 0 {
+		out.WriteString(" {#")
+		out.WriteString(id)
+		out.WriteByte('}')
+	}
+	out.WriteByte('\n')
+}
+
+func (options *Md) HRule(out *bytes.Buffer) {
+	blockSeparator(out)
+	out.WriteString("---\n")
+}
+
+func (options *Md) List(out *bytes.Buffer, text func() bool, flags int) {
+	options.NumberedList(out, text, flags, 1)
+}
+
+func (options *Md) NumberedList(out *bytes.Buffer, text func() bool, flags, start int) {
+	marker := out.Len()
+	if len(options.listNumbers) == 0 {
+		blockSeparator(out)
+	} else if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		// nested lists follow the item text directly
+		out.WriteByte('\n')
+	}
+	options.listNumbers = append(options.listNumbers, start)
+	ok := text()
+	options.listNumbers = options.listNumbers[:len(options.listNumbers)-1]
+	if !ok {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Md) ListItem(out *bytes.Buffer, text []byte, flags int) {
+	var prefix string
+	switch {
+	case flags&LIST_TYPE_TERM != 0:
+		// a blank line separates a term from the definitions before it,
+		// but not from the terms it shares them with
+		if flags&LIST_ITEM_BEGINNING_OF_LIST == 0 && !options.afterTerm {
+			blockSeparator(out)
+		}
+	case flags&LIST_TYPE_DEFINITION != 0:
+		prefix = ": "
+	case flags&LIST_TYPE_ORDERED != 0:
+		n := len(options.listNumbers) - 1
+		prefix = strconv.Itoa(options.listNumbers[n]) + ". "
+		if flags&LIST_DELIMITER_PAREN != 0 {
+			prefix = strconv.Itoa(options.listNumbers[n]) + ") "
+		}
+		options.listNumbers[n]++
+	case options.flags&MD_PRESERVE_BULLETS != 0 && flags&LIST_BULLET_PLUS != 0:
+		prefix = "+ "
+	case options.flags&MD_PRESERVE_BULLETS != 0 && flags&LIST_BULLET_DASH != 0:
+		prefix = "- "
+	default:
+		prefix = "* "
+	}
+	if flags&LIST_ITEM_TASK != 0 {
+		switch {
+		case options.taskMark != 0 && flags&LIST_ITEM_CHECKED != 0:
+			prefix += "[" + string(options.taskMark+1) + "] "
+		case options.taskMark != 0:
+			prefix += "[" + string(options.taskMark) + "] "
+		case flags&LIST_ITEM_CHECKED != 0:
+			prefix += "[x] "
+		default:
+			prefix += "[ ] "
+		}
+	}
+
+	// the lines after the first belong to the item when indented by four
+	// spaces, whatever the width of the prefix
+	writePrefixed(out, bytes.Trim(text, "\n"), prefix, "    ")
+
+	// items made of blocks are separated by blank lines
+	if flags&LIST_ITEM_CONTAINS_BLOCK != 0 && flags&LIST_TYPE_TERM == 0 {
+		out.WriteByte('\n')
+	}
+	options.afterTerm = flags&LIST_TYPE_TERM != 0
+}
+
+func (options *Md) Paragraph(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	blockSeparator(out)
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+	out.WriteByte('\n')
+}
+
+func (options *Md) Table(out *bytes.Buffer, header []byte, body []byte, columnData []int) {
+	options.CaptionedTable(out, header, body, columnData, nil)
+}
+
+func (options *Md) CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte) {
+	blockSeparator(out)
+	if len(caption) > 0 {
+		out.WriteByte('[')
+		out.Write(caption)
+		out.WriteString("]\n")
+	}
+	out.Write(header)
+	for _, align := range columnData {
+		switch align {
+		case TABLE_ALIGNMENT_LEFT:
+			out.WriteString("| :--- ")
+		case TABLE_ALIGNMENT_RIGHT:
+			out.WriteString("| ---: ")
+		case TABLE_ALIGNMENT_CENTER:
+			out.WriteString("| :---: ")
+		case TABLE_ALIGNMENT_JUSTIFY:
+			out.WriteString("| ::---:: ")
+		default:
+			out.WriteString("| --- ")
+		}
+	}
+	out.WriteString("|\n")
+	out.Write(body)
+}
+
+func (options *Md) TableRow(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+	out.WriteString("|\n")
+}
+
+func (options *Md) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	options.TableCell(out, text, flags)
+}
+
+func (options *Md) TableCell(out *bytes.Buffer, text []byte, flags int) {
+	out.WriteString("| ")
+	out.Write(bytes.Replace(text, []byte("|"), []byte("\\|"), -1))
+	out.WriteByte(' ')
+}
+
+func (options *Md) Footnotes(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	blockSeparator(out)
+	if !text() {
+		out.Truncate(marker)
+	}
+}
+
+func (options *Md) FootnoteItem(out *bytes.Buffer, name, text []byte, flags int) {
+	blockSeparator(out)
+	writePrefixed(out, bytes.Trim(text, "\n"), "[^"+string(name)+"]: ", "    ")
+}
+
+func (options *Md) TitleBlock(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	writeLine(out, text)
+}
+
+func (options *Md) AutoLink(out *bytes.Buffer, link []byte, kind int) {
+	out.WriteByte('<')
+	out.Write(link)
+	out.WriteByte('>')
+}
+
+func (options *Md) CodeSpan(out *bytes.Buffer, text []byte) {
+	options.LanguageCodeSpan(out, text, "")
+}
+
+func (options *Md) LanguageCodeSpan(out *bytes.Buffer, text []byte, lang string) {
+	text = bytes.Replace(text, []byte("\n"), []byte(" "), -1)
+	ticks := bytes.Repeat([]byte("`"), longestRun(text, '`')+1)
+	out.Write(ticks)
+	if text[0] == '`' {
+		out.WriteByte(' ')
+	}
+	out.Write(text)
+	if text[len(text)-1] == '`' {
+		out.WriteByte(' ')
+	}
+	out.Write(ticks)
+	if lang != "" {
+		out.WriteString("{.")
+		out.WriteString(lang)
+		out.WriteByte('}')
+	}
+}
+
+func (options *Md) DoubleEmphasis(out *bytes.Buffer, text []byte) {
+	out.WriteString("**")
+	out.Write(text)
+	out.WriteString("**")
+}
+
+func (options *Md) Emphasis(out *bytes.Buffer, text []byte) {
+	out.WriteByte('*')
+	out.Write(text)
+	out.WriteByte('*')
+}
+
+// links that contain spaces or parentheses are enclosed in angle brackets
+func writeLinkTarget(out *bytes.Buffer, link, title []byte) {
+	out.WriteByte('(')
+	if bytes.IndexAny(link, " ()") >= 0 {
+		out.WriteByte('<')
+		out.Write(link)
+		out.WriteByte('>')
+	} else {
+		out.Write(link)
+	}
+	if len(title) > 0 {
+		out.WriteString(" \"")
+		out.Write(title)
+		out.WriteByte('"')
+	}
+	out.WriteByte(')')
+}
+
+func (options *Md) Image(out *bytes.Buffer, link []byte, title []byte, alt []byte) {
+	// alt is the source text, so it is already escaped
+	out.WriteString("![")
+	out.Write(alt)
+	out.WriteByte(']')
+	options.linkTarget(out, link, title)
+}
+
+// linkTarget writes the destination of a link or image, as a reference with
+// MD_REFERENCE_LINKS. Identical destinations share a number.
+func (options *Md) linkTarget(out *bytes.Buffer, link, title []byte) {
+	// reference definitions cannot hold whitespace in the destination, or
+	// an empty one
+	if options.flags&MD_REFERENCE_LINKS == 0 || len(link) == 0 || bytes.IndexAny(link, " \t\n") >= 0 {
+		writeLinkTarget(out, link, title)
+		return
+	}
+	key := string(link) + "\x00" + string(title)
+	n, ok := options.refNumbers[key]
+	if !ok {
+		options.refs = append(options.refs, mdRef{
+			link:  append([]byte(nil), link...),
+			title: append([]byte(nil), title...),
+		})
+		n = len(options.refs)
+		options.refNumbers[key] = n
+	}
+	out.WriteByte('[')
+	out.WriteString(strconv.Itoa(n))
+	out.WriteByte(']')
+}
+
+func (options *Md) LineBreak(out *bytes.Buffer) {
+	out.WriteString("  \n")
+}
+
+func (options *Md) Link(out *bytes.Buffer, link []byte, title []byte, content []byte) {
+	out.WriteByte('[')
+	out.Write(content)
+	out.WriteByte(']')
+	options.linkTarget(out, link, title)
+}
+
+func (options *Md) AttributedLink(out *bytes.Buffer, link []byte, title []byte, content []byte, classes []string, attrs map[string]string) {
+	options.Link(out, link, title, content)
+	var fields []string
+	for _, class := range classes {
+		fields = append(fields, "."+class)
+	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		quote := "\""
+		if strings.Contains(attrs[key], quote) {
+			quote = "'"
+		}
+		fields = append(fields, key+"="+quote+attrs[key]+quote)
+	}
+	if len(fields) > 0 {
+		out.WriteString("{" + strings.Join(fields, " ") + "}")
+	}
+}
+
+func (options *Md) RawHtmlTag(out *bytes.Buffer, tag []byte) {
+	out.Write(tag)
+}
+
+func (options *Md) TripleEmphasis(out *bytes.Buffer, text []byte) {
+	out.WriteString("***")
+	out.Write(text)
+	out.WriteString("***")
+}
+
+func (options *Md) StrikeThrough(out *bytes.Buffer, text []byte) {
+	out.WriteString("~~")
+	out.Write(text)
+	out.WriteString("~~")
+}
+
+func (options *Md) Superscript(out *bytes.Buffer, text []byte) {
+	out.WriteByte('^')
+	out.Write(bytes.Replace(text, []byte(" "), []byte("\\ "), -1))
+	out.WriteByte('^')
+}
+
+func (options *Md) Subscript(out *bytes.Buffer, text []byte) {
+	out.WriteByte('~')
+	out.Write(bytes.Replace(text, []byte(" "), []byte("\\ "), -1))
+	out.WriteByte('~')
+}
+
+func (options *Md) Highlight(out *bytes.Buffer, text []byte) {
+	out.WriteString("==")
+	out.Write(text)
+	out.WriteString("==")
+}
+
+func (options *Md) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	out.Write(text)
+}
+
+func (options *Md) Math(out *bytes.Buffer, text []byte) {
+	out.WriteByte('$')
+	out.Write(text)
+	out.WriteByte('$')
+}
+
+func (options *Md) FootnoteRef(out *bytes.Buffer, ref []byte, id int) {
+	out.WriteString("[^")
+	out.Write(ref)
+	out.WriteByte(']')
+}
+
+func (options *Md) Entity(out *bytes.Buffer, entity []byte) {
+	out.Write(entity)
+}
+
+// returns the length of the entity at the start of text, or 0
+func entityLength(text []byte) int {
+	i := 1
+	if i < len(text) && text[i] == '#' {
+		i++
+	}
+	start := i
+	for i < len(text) && isalnum(text[i]) {
+		i++
+	}
+	if i == start || i >= len(text) || text[i] != ';' {
+		return 0
+	}
+	return i + 1
+}
+
+// returns the character that escapes c, or "" when c is taken literally
+// with the current extensions
+func (options *Md) escapeFor(c byte) string {
+	ext := options.extensions
+	switch c {
+	case '\\', '`', '*', '_', '[', ']', '<':
+		return "\\" + string(c)
+	case '~':
+		if ext&(EXTENSION_STRIKETHROUGH|EXTENSION_SUBSCRIPT|EXTENSION_FENCED_CODE) != 0 {
+			return "\\~"
+		}
+	case '^':
+		if ext&EXTENSION_SUPERSCRIPT != 0 {
+			return "\\^"
+		}
+	case '=':
+		if ext&EXTENSION_HIGHLIGHT != 0 {
+			return "\\="
+		}
+	case '$':
+		if ext&EXTENSION_MATH != 0 {
+			return "\\$"
+		}
+	}
+	return ""
+}
+
+// returns the escaped form of the block marker at the start of a line and
+// the number of bytes of line it replaces, or 0 if the line starts with no
+// marker
+func (options *Md) escapeLineStart(line []byte) (string, int) {
+	switch c := line[0]; c {
+	case '#', '>', '+', '-':
+		return "\\" + string(c), 1
+	case ':':
+		if options.extensions&EXTENSION_DEFINITION_LISTS != 0 {
+			return "\\:", 1
+		}
+	case '=':
+		// a line of = underlines a header
+		if len(bytes.Trim(line, "= ")) == 0 {
+			if options.extensions&EXTENSION_HIGHLIGHT != 0 {
+				return "\\=", 1
+			}
+			return "&#61;", 1
+		}
+	case '!':
+		// the text often arrives one ! at a time, so escape them all
+		if options.extensions&EXTENSION_ADMONITIONS != 0 {
+			return "\\!", 1
+		}
+	}
+	return "", 0
+}
+
+// reports whether a dot written next to out would follow a number at the
+// start of a line, and so start an ordered list
+func afterLineNumber(out *bytes.Buffer) bool {
+	line := out.Bytes()[bytes.LastIndexByte(out.Bytes(), '\n')+1:]
+	if len(line) == 0 {
+		return false
+	}
+	for _, c := range line {
+		if !isdigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (options *Md) NormalText(out *bytes.Buffer, text []byte) {
+	lineStart := out.Len() == 0 || bytes.HasSuffix(out.Bytes(), []byte("\n"))
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			// collapse runs of whitespace, and drop it around newlines
+			for i+1 < len(text) && (text[i+1] == ' ' || text[i+1] == '\t') {
+				i++
+			}
+			if !lineStart && (i+1 >= len(text) || text[i+1] != '\n') {
+				out.WriteByte(' ')
+			}
+			continue
+		case c == '\n':
+			out.WriteByte('\n')
+			lineStart = true
+			continue
+		}
+
+		if lineStart {
+			lineStart = false
+			if escaped, n := options.escapeLineStart(text[i:]); n > 0 {
+				out.WriteString(escaped)
+				i += n - 1
+				continue
+			}
+		}
+
+		switch {
+		case (c == '.' || c == ')') && afterLineNumber(out) && (i+1 == len(text) || text[i+1] == ' '):
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c == '&':
+			if i+1 == len(text) || entityLength(text[i:]) > 0 {
+				out.WriteString("\\&")
+			} else {
+				out.WriteByte('&')
+			}
+		case c == ':' && options.extensions&EXTENSION_EMOJI != 0 &&
+			i+1 < len(text) && isEmojiNameChar(text[i+1]):
+			out.WriteString("\\:")
+		default:
+			if escaped := options.escapeFor(c); escaped != "" {
+				out.WriteString(escaped)
+			} else {
+				out.WriteByte(c)
+			}
+		}
+	}
+}
+
+func (options *Md) DocumentHeader(out *bytes.Buffer) {
+	options.refs = nil
+	options.refNumbers = make(map[string]int)
+}
+
+func (options *Md) DocumentFooter(out *bytes.Buffer) {
+	// end the document with a single newline
+	for bytes.HasSuffix(out.Bytes(), []byte("\n\n")) {
+		out.Truncate(out.Len() - 1)
+	}
+
+	if len(options.refs) > 0 {
+		blockSeparator(out)
+	}
+	for i, ref := range options.refs {
+		out.WriteByte('[')
+		out.WriteString(strconv.Itoa(i + 1))
+		out.WriteString("]: ")
+		out.Write(ref.link)
+		if len(ref.title) > 0 {
+			out.WriteString(" \"")
+			out.Write(ref.title)
+			out.WriteByte('"')
+		}
+		out.WriteByte('\n')
+	}
+}
+
+// SetTaskChecked checks or unchecks a task list item of a Markdown document
+// and returns the document, rendered again by MarkdownRenderer. item counts
+// the task items from 0 in the order they appear, nested ones included. The
+// document is only normalized when there is no such item.
+//
+// extensions is the set of EXTENSION_* options the document is parsed with;
+// it should include EXTENSION_TASK_LISTS.
+func SetTaskChecked(input []byte, extensions int, item int, checked bool) []byte {
+	// the boxes are marked with control bytes that are not in the input,
+	// so that the items can be found in the order of the output
+	var mark byte = 1
+	for mark < ' '-1 && (bytes.IndexByte(input, mark) >= 0 || bytes.IndexByte(input, mark+1) >= 0) {
+		mark++
+	}
+	if mark >= ' '-1 {
+		return Markdown(input, MarkdownRenderer(extensions), extensions)
+	}
+
+	output := Markdown(input, &Md{extensions: extensions, taskMark: mark}, extensions)
+	n := 0
+	for i := 0; i+2 < len(output); i++ {
+		if output[i] != '[' || output[i+2] != ']' || (output[i+1] != mark && output[i+1] != mark+1) {
+			continue
+		}
+		isChecked := output[i+1] == mark+1
+		if n == item {
+			isChecked = checked
+		}
+		if isChecked {
+			output[i+1] = 'x'
+		} else {
+			output[i+1] = ' '
+		}
+		n++
+	}
+	return output
+}
@@ -0,0 +1,918 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// Unicode normalization
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+	"sort"
+	"unicode/utf8"
+)
+
+// composeNFC composes the letters in text that are followed by combining
+// diacritical marks (U+0300 to U+036F) into their precomposed forms, as
+// Unicode Normalization Form C does. It is a minimal normalizer: only the
+// compositions of the Latin, Greek and Cyrillic alphabets are known, and
+// other characters are left as they are. Text without combining marks is
+// returned unchanged.
+func composeNFC(text []byte) []byte {
+	// the combining diacritical marks are encoded as 0xcc 0x80 to 0xcd 0xaf
+	if bytes.IndexByte(text, 0xcc) < 0 && bytes.IndexByte(text, 0xcd) < 0 {
+		return text
+	}
+
+	runes := bytes.Runes(text)
+
+	// put runs of marks in canonical order first
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && combiningClass(runes[j]) != 0 {
+			j++
+		}
+		if j > i {
+			marks := runes[i:j]
+			sort.SliceStable(marks, func(a, b int) bool {
+				return combiningClass(marks[a]) < combiningClass(marks[b])
+			})
+			i = j
+		} else {
+			i++
+		}
+	}
+
+	// then combine each mark with the preceding letter, unless a mark of
+	// the same class comes between them
+	out := runes[:0]
+	starter, lastClass := -1, 0
+	for _, r := range runes {
+		class := combiningClass(r)
+		if class != 0 && starter >= 0 && (len(out) == starter+1 || lastClass < class) {
+			if c, ok := nfcCompositions[[2]rune{out[starter], r}]; ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		lastClass = class
+		out = append(out, r)
+	}
+
+	result := make([]byte, 0, len(text))
+	var buf [utf8.UTFMax]byte
+	for _, r := range out {
+		n := utf8.EncodeRune(buf[:], r)
+		result = append(result, buf[:n]...)
+	}
+	return result
+}
+
+// combiningClass returns the canonical combining class of the combining
+// diacritical marks, and 0 for every other character.
+func combiningClass(r rune) int {
+	if r < 0x300 || r > 0x36f {
+		return 0
+	}
+	for _, c := range combiningClasses {
+		if r <= c.last {
+			return c.class
+		}
+	}
+	return 0
+}
+
+var combiningClasses = []struct {
+	last  rune
+	class int
+}{
+	{0x314, 230},
+	{0x315, 232},
+	{0x319, 220},
+	{0x31a, 232},
+	{0x31b, 216},
+	{0x320, 220},
+	{0x322, 202},
+	{0x326, 220},
+	{0x328, 202},
+	{0x333, 220},
+	{0x338, 1},
+	{0x33c, 220},
+	{0x344, 230},
+	{0x345, 240},
+	{0x346, 230},
+	{0x349, 220},
+	{0x34c, 230},
+	{0x34e, 220},
+	{0x34f, 0},
+	{0x352, 230},
+	{0x356, 220},
+	{0x357, 230},
+	{0x358, 232},
+	{0x35a, 220},
+	{0x35b, 230},
+	{0x35c, 233},
+	{0x35e, 234},
+	{0x35f, 233},
+	{0x361, 234},
+	{0x362, 233},
+	{0x36f, 230},
+}
+
+// nfcCompositions maps a letter and a combining mark to the precomposed
+// letter.
+var nfcCompositions = map[[2]rune]rune{
+	{0x41, 0x300}:   0xc0,   // latin capital letter a with grave
+	{0x41, 0x301}:   0xc1,   // latin capital letter a with acute
+	{0x41, 0x302}:   0xc2,   // latin capital letter a with circumflex
+	{0x41, 0x303}:   0xc3,   // latin capital letter a with tilde
+	{0x41, 0x308}:   0xc4,   // latin capital letter a with diaeresis
+	{0x41, 0x30a}:   0xc5,   // latin capital letter a with ring above
+	{0x43, 0x327}:   0xc7,   // latin capital letter c with cedilla
+	{0x45, 0x300}:   0xc8,   // latin capital letter e with grave
+	{0x45, 0x301}:   0xc9,   // latin capital letter e with acute
+	{0x45, 0x302}:   0xca,   // latin capital letter e with circumflex
+	{0x45, 0x308}:   0xcb,   // latin capital letter e with diaeresis
+	{0x49, 0x300}:   0xcc,   // latin capital letter i with grave
+	{0x49, 0x301}:   0xcd,   // latin capital letter i with acute
+	{0x49, 0x302}:   0xce,   // latin capital letter i with circumflex
+	{0x49, 0x308}:   0xcf,   // latin capital letter i with diaeresis
+	{0x4e, 0x303}:   0xd1,   // latin capital letter n with tilde
+	{0x4f, 0x300}:   0xd2,   // latin capital letter o with grave
+	{0x4f, 0x301}:   0xd3,   // latin capital letter o with acute
+	{0x4f, 0x302}:   0xd4,   // latin capital letter o with circumflex
+	{0x4f, 0x303}:   0xd5,   // latin capital letter o with tilde
+	{0x4f, 0x308}:   0xd6,   // latin capital letter o with diaeresis
+	{0x55, 0x300}:   0xd9,   // latin capital letter u with grave
+	{0x55, 0x301}:   0xda,   // latin capital letter u with acute
+	{0x55, 0x302}:   0xdb,   // latin capital letter u with circumflex
+	{0x55, 0x308}:   0xdc,   // latin capital letter u with diaeresis
+	{0x59, 0x301}:   0xdd,   // latin capital letter y with acute
+	{0x61, 0x300}:   0xe0,   // latin small letter a with grave
+	{0x61, 0x301}:   0xe1,   // latin small letter a with acute
+	{0x61, 0x302}:   0xe2,   // latin small letter a with circumflex
+	{0x61, 0x303}:   0xe3,   // latin small letter a with tilde
+	{0x61, 0x308}:   0xe4,   // latin small letter a with diaeresis
+	{0x61, 0x30a}:   0xe5,   // latin small letter a with ring above
+	{0x63, 0x327}:   0xe7,   // latin small letter c with cedilla
+	{0x65, 0x300}:   0xe8,   // latin small letter e with grave
+	{0x65, 0x301}:   0xe9,   // latin small letter e with acute
+	{0x65, 0x302}:   0xea,   // latin small letter e with circumflex
+	{0x65, 0x308}:   0xeb,   // latin small letter e with diaeresis
+	{0x69, 0x300}:   0xec,   // latin small letter i with grave
+	{0x69, 0x301}:   0xed,   // latin small letter i with acute
+	{0x69, 0x302}:   0xee,   // latin small letter i with circumflex
+	{0x69, 0x308}:   0xef,   // latin small letter i with diaeresis
+	{0x6e, 0x303}:   0xf1,   // latin small letter n with tilde
+	{0x6f, 0x300}:   0xf2,   // latin small letter o with grave
+	{0x6f, 0x301}:   0xf3,   // latin small letter o with acute
+	{0x6f, 0x302}:   0xf4,   // latin small letter o with circumflex
+	{0x6f, 0x303}:   0xf5,   // latin small letter o with tilde
+	{0x6f, 0x308}:   0xf6,   // latin small letter o with diaeresis
+	{0x75, 0x300}:   0xf9,   // latin small letter u with grave
+	{0x75, 0x301}:   0xfa,   // latin small letter u with acute
+	{0x75, 0x302}:   0xfb,   // latin small letter u with circumflex
+	{0x75, 0x308}:   0xfc,   // latin small letter u with diaeresis
+	{0x79, 0x301}:   0xfd,   // latin small letter y with acute
+	{0x79, 0x308}:   0xff,   // latin small letter y with diaeresis
+	{0x41, 0x304}:   0x100,  // latin capital letter a with macron
+	{0x61, 0x304}:   0x101,  // latin small letter a with macron
+	{0x41, 0x306}:   0x102,  // latin capital letter a with breve
+	{0x61, 0x306}:   0x103,  // latin small letter a with breve
+	{0x41, 0x328}:   0x104,  // latin capital letter a with ogonek
+	{0x61, 0x328}:   0x105,  // latin small letter a with ogonek
+	{0x43, 0x301}:   0x106,  // latin capital letter c with acute
+	{0x63, 0x301}:   0x107,  // latin small letter c with acute
+	{0x43, 0x302}:   0x108,  // latin capital letter c with circumflex
+	{0x63, 0x302}:   0x109,  // latin small letter c with circumflex
+	{0x43, 0x307}:   0x10a,  // latin capital letter c with dot above
+	{0x63, 0x307}:   0x10b,  // latin small letter c with dot above
+	{0x43, 0x30c}:   0x10c,  // latin capital letter c with caron
+	{0x63, 0x30c}:   0x10d,  // latin small letter c with caron
+	{0x44, 0x30c}:   0x10e,  // latin capital letter d with caron
+	{0x64, 0x30c}:   0x10f,  // latin small letter d with caron
+	{0x45, 0x304}:   0x112,  // latin capital letter e with macron
+	{0x65, 0x304}:   0x113,  // latin small letter e with macron
+	{0x45, 0x306}:   0x114,  // latin capital letter e with breve
+	{0x65, 0x306}:   0x115,  // latin small letter e with breve
+	{0x45, 0x307}:   0x116,  // latin capital letter e with dot above
+	{0x65, 0x307}:   0x117,  // latin small letter e with dot above
+	{0x45, 0x328}:   0x118,  // latin capital letter e with ogonek
+	{0x65, 0x328}:   0x119,  // latin small letter e with ogonek
+	{0x45, 0x30c}:   0x11a,  // latin capital letter e with caron
+	{0x65, 0x30c}:   0x11b,  // latin small letter e with caron
+	{0x47, 0x302}:   0x11c,  // latin capital letter g with circumflex
+	{0x67, 0x302}:   0x11d,  // latin small letter g with circumflex
+	{0x47, 0x306}:   0x11e,  // latin capital letter g with breve
+	{0x67, 0x306}:   0x11f,  // latin small letter g with breve
+	{0x47, 0x307}:   0x120,  // latin capital letter g with dot above
+	{0x67, 0x307}:   0x121,  // latin small letter g with dot above
+	{0x47, 0x327}:   0x122,  // latin capital letter g with cedilla
+	{0x67, 0x327}:   0x123,  // latin small letter g with cedilla
+	{0x48, 0x302}:   0x124,  // latin capital letter h with circumflex
+	{0x68, 0x302}:   0x125,  // latin small letter h with circumflex
+	{0x49, 0x303}:   0x128,  // latin capital letter i with tilde
+	{0x69, 0x303}:   0x129,  // latin small letter i with tilde
+	{0x49, 0x304}:   0x12a,  // latin capital letter i with macron
+	{0x69, 0x304}:   0x12b,  // latin small letter i with macron
+	{0x49, 0x306}:   0x12c,  // latin capital letter i with breve
+	{0x69, 0x306}:   0x12d,  // latin small letter i with breve
+	{0x49, 0x328}:   0x12e,  // latin capital letter i with ogonek
+	{0x69, 0x328}:   0x12f,  // latin small letter i with ogonek
+	{0x49, 0x307}:   0x130,  // latin capital letter i with dot above
+	{0x4a, 0x302}:   0x134,  // latin capital letter j with circumflex
+	{0x6a, 0x302}:   0x135,  // latin small letter j with circumflex
+	{0x4b, 0x327}:   0x136,  // latin capital letter k with cedilla
+	{0x6b, 0x327}:   0x137,  // latin small letter k with cedilla
+	{0x4c, 0x301}:   0x139,  // latin capital letter l with acute
+	{0x6c, 0x301}:   0x13a,  // latin small letter l with acute
+	{0x4c, 0x327}:   0x13b,  // latin capital letter l with cedilla
+	{0x6c, 0x327}:   0x13c,  // latin small letter l with cedilla
+	{0x4c, 0x30c}:   0x13d,  // latin capital letter l with caron
+	{0x6c, 0x30c}:   0x13e,  // latin small letter l with caron
+	{0x4e, 0x301}:   0x143,  // latin capital letter n with acute
+	{0x6e, 0x301}:   0x144,  // latin small letter n with acute
+	{0x4e, 0x327}:   0x145,  // latin capital letter n with cedilla
+	{0x6e, 0x327}:   0x146,  // latin small letter n with cedilla
+	{0x4e, 0x30c}:   0x147,  // latin capital letter n with caron
+	{0x6e, 0x30c}:   0x148,  // latin small letter n with caron
+	{0x4f, 0x304}:   0x14c,  // latin capital letter o with macron
+	{0x6f, 0x304}:   0x14d,  // latin small letter o with macron
+	{0x4f, 0x306}:   0x14e,  // latin capital letter o with breve
+	{0x6f, 0x306}:   0x14f,  // latin small letter o with breve
+	{0x4f, 0x30b}:   0x150,  // latin capital letter o with double acute
+	{0x6f, 0x30b}:   0x151,  // latin small letter o with double acute
+	{0x52, 0x301}:   0x154,  // latin capital letter r with acute
+	{0x72, 0x301}:   0x155,  // latin small letter r with acute
+	{0x52, 0x327}:   0x156,  // latin capital letter r with cedilla
+	{0x72, 0x327}:   0x157,  // latin small letter r with cedilla
+	{0x52, 0x30c}:   0x158,  // latin capital letter r with caron
+	{0x72, 0x30c}:   0x159,  // latin small letter r with caron
+	{0x53, 0x301}:   0x15a,  // latin capital letter s with acute
+	{0x73, 0x301}:   0x15b,  // latin small letter s with acute
+	{0x53, 0x302}:   0x15c,  // latin capital letter s with circumflex
+	{0x73, 0x302}:   0x15d,  // latin small letter s with circumflex
+	{0x53, 0x327}:   0x15e,  // latin capital letter s with cedilla
+	{0x73, 0x327}:   0x15f,  // latin small letter s with cedilla
+	{0x53, 0x30c}:   0x160,  // latin capital letter s with caron
+	{0x73, 0x30c}:   0x161,  // latin small letter s with caron
+	{0x54, 0x327}:   0x162,  // latin capital letter t with cedilla
+	{0x74, 0x327}:   0x163,  // latin small letter t with cedilla
+	{0x54, 0x30c}:   0x164,  // latin capital letter t with caron
+	{0x74, 0x30c}:   0x165,  // latin small letter t with caron
+	{0x55, 0x303}:   0x168,  // latin capital letter u with tilde
+	{0x75, 0x303}:   0x169,  // latin small letter u with tilde
+	{0x55, 0x304}:   0x16a,  // latin capital letter u with macron
+	{0x75, 0x304}:   0x16b,  // latin small letter u with macron
+	{0x55, 0x306}:   0x16c,  // latin capital letter u with breve
+	{0x75, 0x306}:   0x16d,  // latin small letter u with breve
+	{0x55, 0x30a}:   0x16e,  // latin capital letter u with ring above
+	{0x75, 0x30a}:   0x16f,  // latin small letter u with ring above
+	{0x55, 0x30b}:   0x170,  // latin capital letter u with double acute
+	{0x75, 0x30b}:   0x171,  // latin small letter u with double acute
+	{0x55, 0x328}:   0x172,  // latin capital letter u with ogonek
+	{0x75, 0x328}:   0x173,  // latin small letter u with ogonek
+	{0x57, 0x302}:   0x174,  // latin capital letter w with circumflex
+	{0x77, 0x302}:   0x175,  // latin small letter w with circumflex
+	{0x59, 0x302}:   0x176,  // latin capital letter y with circumflex
+	{0x79, 0x302}:   0x177,  // latin small letter y with circumflex
+	{0x59, 0x308}:   0x178,  // latin capital letter y with diaeresis
+	{0x5a, 0x301}:   0x179,  // latin capital letter z with acute
+	{0x7a, 0x301}:   0x17a,  // latin small letter z with acute
+	{0x5a, 0x307}:   0x17b,  // latin capital letter z with dot above
+	{0x7a, 0x307}:   0x17c,  // latin small letter z with dot above
+	{0x5a, 0x30c}:   0x17d,  // latin capital letter z with caron
+	{0x7a, 0x30c}:   0x17e,  // latin small letter z with caron
+	{0x4f, 0x31b}:   0x1a0,  // latin capital letter o with horn
+	{0x6f, 0x31b}:   0x1a1,  // latin small letter o with horn
+	{0x55, 0x31b}:   0x1af,  // latin capital letter u with horn
+	{0x75, 0x31b}:   0x1b0,  // latin small letter u with horn
+	{0x41, 0x30c}:   0x1cd,  // latin capital letter a with caron
+	{0x61, 0x30c}:   0x1ce,  // latin small letter a with caron
+	{0x49, 0x30c}:   0x1cf,  // latin capital letter i with caron
+	{0x69, 0x30c}:   0x1d0,  // latin small letter i with caron
+	{0x4f, 0x30c}:   0x1d1,  // latin capital letter o with caron
+	{0x6f, 0x30c}:   0x1d2,  // latin small letter o with caron
+	{0x55, 0x30c}:   0x1d3,  // latin capital letter u with caron
+	{0x75, 0x30c}:   0x1d4,  // latin small letter u with caron
+	{0xdc, 0x304}:   0x1d5,  // latin capital letter u with diaeresis and macron
+	{0xfc, 0x304}:   0x1d6,  // latin small letter u with diaeresis and macron
+	{0xdc, 0x301}:   0x1d7,  // latin capital letter u with diaeresis and acute
+	{0xfc, 0x301}:   0x1d8,  // latin small letter u with diaeresis and acute
+	{0xdc, 0x30c}:   0x1d9,  // latin capital letter u with diaeresis and caron
+	{0xfc, 0x30c}:   0x1da,  // latin small letter u with diaeresis and caron
+	{0xdc, 0x300}:   0x1db,  // latin capital letter u with diaeresis and grave
+	{0xfc, 0x300}:   0x1dc,  // latin small letter u with diaeresis and grave
+	{0xc4, 0x304}:   0x1de,  // latin capital letter a with diaeresis and macron
+	{0xe4, 0x304}:   0x1df,  // latin small letter a with diaeresis and macron
+	{0x226, 0x304}:  0x1e0,  // latin capital letter a with dot above and macron
+	{0x227, 0x304}:  0x1e1,  // latin small letter a with dot above and macron
+	{0xc6, 0x304}:   0x1e2,  // latin capital letter ae with macron
+	{0xe6, 0x304}:   0x1e3,  // latin small letter ae with macron
+	{0x47, 0x30c}:   0x1e6,  // latin capital letter g with caron
+	{0x67, 0x30c}:   0x1e7,  // latin small letter g with caron
+	{0x4b, 0x30c}:   0x1e8,  // latin capital letter k with caron
+	{0x6b, 0x30c}:   0x1e9,  // latin small letter k with caron
+	{0x4f, 0x328}:   0x1ea,  // latin capital letter o with ogonek
+	{0x6f, 0x328}:   0x1eb,  // latin small letter o with ogonek
+	{0x1ea, 0x304}:  0x1ec,  // latin capital letter o with ogonek and macron
+	{0x1eb, 0x304}:  0x1ed,  // latin small letter o with ogonek and macron
+	{0x1b7, 0x30c}:  0x1ee,  // latin capital letter ezh with caron
+	{0x292, 0x30c}:  0x1ef,  // latin small letter ezh with caron
+	{0x6a, 0x30c}:   0x1f0,  // latin small letter j with caron
+	{0x47, 0x301}:   0x1f4,  // latin capital letter g with acute
+	{0x67, 0x301}:   0x1f5,  // latin small letter g with acute
+	{0x4e, 0x300}:   0x1f8,  // latin capital letter n with grave
+	{0x6e, 0x300}:   0x1f9,  // latin small letter n with grave
+	{0xc5, 0x301}:   0x1fa,  // latin capital letter a with ring above and acute
+	{0xe5, 0x301}:   0x1fb,  // latin small letter a with ring above and acute
+	{0xc6, 0x301}:   0x1fc,  // latin capital letter ae with acute
+	{0xe6, 0x301}:   0x1fd,  // latin small letter ae with acute
+	{0xd8, 0x301}:   0x1fe,  // latin capital letter o with stroke and acute
+	{0xf8, 0x301}:   0x1ff,  // latin small letter o with stroke and acute
+	{0x41, 0x30f}:   0x200,  // latin capital letter a with double grave
+	{0x61, 0x30f}:   0x201,  // latin small letter a with double grave
+	{0x41, 0x311}:   0x202,  // latin capital letter a with inverted breve
+	{0x61, 0x311}:   0x203,  // latin small letter a with inverted breve
+	{0x45, 0x30f}:   0x204,  // latin capital letter e with double grave
+	{0x65, 0x30f}:   0x205,  // latin small letter e with double grave
+	{0x45, 0x311}:   0x206,  // latin capital letter e with inverted breve
+	{0x65, 0x311}:   0x207,  // latin small letter e with inverted breve
+	{0x49, 0x30f}:   0x208,  // latin capital letter i with double grave
+	{0x69, 0x30f}:   0x209,  // latin small letter i with double grave
+	{0x49, 0x311}:   0x20a,  // latin capital letter i with inverted breve
+	{0x69, 0x311}:   0x20b,  // latin small letter i with inverted breve
+	{0x4f, 0x30f}:   0x20c,  // latin capital letter o with double grave
+	{0x6f, 0x30f}:   0x20d,  // latin small letter o with double grave
+	{0x4f, 0x311}:   0x20e,  // latin capital letter o with inverted breve
+	{0x6f, 0x311}:   0x20f,  // latin small letter o with inverted breve
+	{0x52, 0x30f}:   0x210,  // latin capital letter r with double grave
+	{0x72, 0x30f}:   0x211,  // latin small letter r with double grave
+	{0x52, 0x311}:   0x212,  // latin capital letter r with inverted breve
+	{0x72, 0x311}:   0x213,  // latin small letter r with inverted breve
+	{0x55, 0x30f}:   0x214,  // latin capital letter u with double grave
+	{0x75, 0x30f}:   0x215,  // latin small letter u with double grave
+	{0x55, 0x311}:   0x216,  // latin capital letter u with inverted breve
+	{0x75, 0x311}:   0x217,  // latin small letter u with inverted breve
+	{0x53, 0x326}:   0x218,  // latin capital letter s with comma below
+	{0x73, 0x326}:   0x219,  // latin small letter s with comma below
+	{0x54, 0x326}:   0x21a,  // latin capital letter t with comma below
+	{0x74, 0x326}:   0x21b,  // latin small letter t with comma below
+	{0x48, 0x30c}:   0x21e,  // latin capital letter h with caron
+	{0x68, 0x30c}:   0x21f,  // latin small letter h with caron
+	{0x41, 0x307}:   0x226,  // latin capital letter a with dot above
+	{0x61, 0x307}:   0x227,  // latin small letter a with dot above
+	{0x45, 0x327}:   0x228,  // latin capital letter e with cedilla
+	{0x65, 0x327}:   0x229,  // latin small letter e with cedilla
+	{0xd6, 0x304}:   0x22a,  // latin capital letter o with diaeresis and macron
+	{0xf6, 0x304}:   0x22b,  // latin small letter o with diaeresis and macron
+	{0xd5, 0x304}:   0x22c,  // latin capital letter o with tilde and macron
+	{0xf5, 0x304}:   0x22d,  // latin small letter o with tilde and macron
+	{0x4f, 0x307}:   0x22e,  // latin capital letter o with dot above
+	{0x6f, 0x307}:   0x22f,  // latin small letter o with dot above
+	{0x22e, 0x304}:  0x230,  // latin capital letter o with dot above and macron
+	{0x22f, 0x304}:  0x231,  // latin small letter o with dot above and macron
+	{0x59, 0x304}:   0x232,  // latin capital letter y with macron
+	{0x79, 0x304}:   0x233,  // latin small letter y with macron
+	{0xa8, 0x301}:   0x385,  // greek dialytika tonos
+	{0x391, 0x301}:  0x386,  // greek capital letter alpha with tonos
+	{0x395, 0x301}:  0x388,  // greek capital letter epsilon with tonos
+	{0x397, 0x301}:  0x389,  // greek capital letter eta with tonos
+	{0x399, 0x301}:  0x38a,  // greek capital letter iota with tonos
+	{0x39f, 0x301}:  0x38c,  // greek capital letter omicron with tonos
+	{0x3a5, 0x301}:  0x38e,  // greek capital letter upsilon with tonos
+	{0x3a9, 0x301}:  0x38f,  // greek capital letter omega with tonos
+	{0x3ca, 0x301}:  0x390,  // greek small letter iota with dialytika and tonos
+	{0x399, 0x308}:  0x3aa,  // greek capital letter iota with dialytika
+	{0x3a5, 0x308}:  0x3ab,  // greek capital letter upsilon with dialytika
+	{0x3b1, 0x301}:  0x3ac,  // greek small letter alpha with tonos
+	{0x3b5, 0x301}:  0x3ad,  // greek small letter epsilon with tonos
+	{0x3b7, 0x301}:  0x3ae,  // greek small letter eta with tonos
+	{0x3b9, 0x301}:  0x3af,  // greek small letter iota with tonos
+	{0x3cb, 0x301}:  0x3b0,  // greek small letter upsilon with dialytika and tonos
+	{0x3b9, 0x308}:  0x3ca,  // greek small letter iota with dialytika
+	{0x3c5, 0x308}:  0x3cb,  // greek small letter upsilon with dialytika
+	{0x3bf, 0x301}:  0x3cc,  // greek small letter omicron with tonos
+	{0x3c5, 0x301}:  0x3cd,  // greek small letter upsilon with tonos
+	{0x3c9, 0x301}:  0x3ce,  // greek small letter omega with tonos
+	{0x3d2, 0x301}:  0x3d3,  // greek upsilon with acute and hook symbol
+	{0x3d2, 0x308}:  0x3d4,  // greek upsilon with diaeresis and hook symbol
+	{0x415, 0x300}:  0x400,  // cyrillic capital letter ie with grave
+	{0x415, 0x308}:  0x401,  // cyrillic capital letter io
+	{0x413, 0x301}:  0x403,  // cyrillic capital letter gje
+	{0x406, 0x308}:  0x407,  // cyrillic capital letter yi
+	{0x41a, 0x301}:  0x40c,  // cyrillic capital letter kje
+	{0x418, 0x300}:  0x40d,  // cyrillic capital letter i with grave
+	{0x423, 0x306}:  0x40e,  // cyrillic capital letter short u
+	{0x418, 0x306}:  0x419,  // cyrillic capital letter short i
+	{0x438, 0x306}:  0x439,  // cyrillic small letter short i
+	{0x435, 0x300}:  0x450,  // cyrillic small letter ie with grave
+	{0x435, 0x308}:  0x451,  // cyrillic small letter io
+	{0x433, 0x301}:  0x453,  // cyrillic small letter gje
+	{0x456, 0x308}:  0x457,  // cyrillic small letter yi
+	{0x43a, 0x301}:  0x45c,  // cyrillic small letter kje
+	{0x438, 0x300}:  0x45d,  // cyrillic small letter i with grave
+	{0x443, 0x306}:  0x45e,  // cyrillic small letter short u
+	{0x474, 0x30f}:  0x476,  // cyrillic capital letter izhitsa with double grave accent
+	{0x475, 0x30f}:  0x477,  // cyrillic small letter izhitsa with double grave accent
+	{0x416, 0x306}:  0x4c1,  // cyrillic capital letter zhe with breve
+	{0x436, 0x306}:  0x4c2,  // cyrillic small letter zhe with breve
+	{0x410, 0x306}:  0x4d0,  // cyrillic capital letter a with breve
+	{0x430, 0x306}:  0x4d1,  // cyrillic small letter a with breve
+	{0x410, 0x308}:  0x4d2,  // cyrillic capital letter a with diaeresis
+	{0x430, 0x308}:  0x4d3,  // cyrillic small letter a with diaeresis
+	{0x415, 0x306}:  0x4d6,  // cyrillic capital letter ie with breve
+	{0x435, 0x306}:  0x4d7,  // cyrillic small letter ie with breve
+	{0x4d8, 0x308}:  0x4da,  // cyrillic capital letter schwa with diaeresis
+	{0x4d9, 0x308}:  0x4db,  // cyrillic small letter schwa with diaeresis
+	{0x416, 0x308}:  0x4dc,  // cyrillic capital letter zhe with diaeresis
+	{0x436, 0x308}:  0x4dd,  // cyrillic small letter zhe with diaeresis
+	{0x417, 0x308}:  0x4de,  // cyrillic capital letter ze with diaeresis
+	{0x437, 0x308}:  0x4df,  // cyrillic small letter ze with diaeresis
+	{0x418, 0x304}:  0x4e2,  // cyrillic capital letter i with macron
+	{0x438, 0x304}:  0x4e3,  // cyrillic small letter i with macron
+	{0x418, 0x308}:  0x4e4,  // cyrillic capital letter i with diaeresis
+	{0x438, 0x308}:  0x4e5,  // cyrillic small letter i with diaeresis
+	{0x41e, 0x308}:  0x4e6,  // cyrillic capital letter o with diaeresis
+	{0x43e, 0x308}:  0x4e7,  // cyrillic small letter o with diaeresis
+	{0x4e8, 0x308}:  0x4ea,  // cyrillic capital letter barred o with diaeresis
+	{0x4e9, 0x308}:  0x4eb,  // cyrillic small letter barred o with diaeresis
+	{0x42d, 0x308}:  0x4ec,  // cyrillic capital letter e with diaeresis
+	{0x44d, 0x308}:  0x4ed,  // cyrillic small letter e with diaeresis
+	{0x423, 0x304}:  0x4ee,  // cyrillic capital letter u with macron
+	{0x443, 0x304}:  0x4ef,  // cyrillic small letter u with macron
+	{0x423, 0x308}:  0x4f0,  // cyrillic capital letter u with diaeresis
+	{0x443, 0x308}:  0x4f1,  // cyrillic small letter u with diaeresis
+	{0x423, 0x30b}:  0x4f2,  // cyrillic capital letter u with double acute
+	{0x443, 0x30b}:  0x4f3,  // cyrillic small letter u with double acute
+	{0x427, 0x308}:  0x4f4,  // cyrillic capital letter che with diaeresis
+	{0x447, 0x308}:  0x4f5,  // cyrillic small letter che with diaeresis
+	{0x42b, 0x308}:  0x4f8,  // cyrillic capital letter yeru with diaeresis
+	{0x44b, 0x308}:  0x4f9,  // cyrillic small letter yeru with diaeresis
+	{0x41, 0x325}:   0x1e00, // latin capital letter a with ring below
+	{0x61, 0x325}:   0x1e01, // latin small letter a with ring below
+	{0x42, 0x307}:   0x1e02, // latin capital letter b with dot above
+	{0x62, 0x307}:   0x1e03, // latin small letter b with dot above
+	{0x42, 0x323}:   0x1e04, // latin capital letter b with dot below
+	{0x62, 0x323}:   0x1e05, // latin small letter b with dot below
+	{0x42, 0x331}:   0x1e06, // latin capital letter b with line below
+	{0x62, 0x331}:   0x1e07, // latin small letter b with line below
+	{0xc7, 0x301}:   0x1e08, // latin capital letter c with cedilla and acute
+	{0xe7, 0x301}:   0x1e09, // latin small letter c with cedilla and acute
+	{0x44, 0x307}:   0x1e0a, // latin capital letter d with dot above
+	{0x64, 0x307}:   0x1e0b, // latin small letter d with dot above
+	{0x44, 0x323}:   0x1e0c, // latin capital letter d with dot below
+	{0x64, 0x323}:   0x1e0d, // latin small letter d with dot below
+	{0x44, 0x331}:   0x1e0e, // latin capital letter d with line below
+	{0x64, 0x331}:   0x1e0f, // latin small letter d with line below
+	{0x44, 0x327}:   0x1e10, // latin capital letter d with cedilla
+	{0x64, 0x327}:   0x1e11, // latin small letter d with cedilla
+	{0x44, 0x32d}:   0x1e12, // latin capital letter d with circumflex below
+	{0x64, 0x32d}:   0x1e13, // latin small letter d with circumflex below
+	{0x112, 0x300}:  0x1e14, // latin capital letter e with macron and grave
+	{0x113, 0x300}:  0x1e15, // latin small letter e with macron and grave
+	{0x112, 0x301}:  0x1e16, // latin capital letter e with macron and acute
+	{0x113, 0x301}:  0x1e17, // latin small letter e with macron and acute
+	{0x45, 0x32d}:   0x1e18, // latin capital letter e with circumflex below
+	{0x65, 0x32d}:   0x1e19, // latin small letter e with circumflex below
+	{0x45, 0x330}:   0x1e1a, // latin capital letter e with tilde below
+	{0x65, 0x330}:   0x1e1b, // latin small letter e with tilde below
+	{0x228, 0x306}:  0x1e1c, // latin capital letter e with cedilla and breve
+	{0x229, 0x306}:  0x1e1d, // latin small letter e with cedilla and breve
+	{0x46, 0x307}:   0x1e1e, // latin capital letter f with dot above
+	{0x66, 0x307}:   0x1e1f, // latin small letter f with dot above
+	{0x47, 0x304}:   0x1e20, // latin capital letter g with macron
+	{0x67, 0x304}:   0x1e21, // latin small letter g with macron
+	{0x48, 0x307}:   0x1e22, // latin capital letter h with dot above
+	{0x68, 0x307}:   0x1e23, // latin small letter h with dot above
+	{0x48, 0x323}:   0x1e24, // latin capital letter h with dot below
+	{0x68, 0x323}:   0x1e25, // latin small letter h with dot below
+	{0x48, 0x308}:   0x1e26, // latin capital letter h with diaeresis
+	{0x68, 0x308}:   0x1e27, // latin small letter h with diaeresis
+	{0x48, 0x327}:   0x1e28, // latin capital letter h with cedilla
+	{0x68, 0x327}:   0x1e29, // latin small letter h with cedilla
+	{0x48, 0x32e}:   0x1e2a, // latin capital letter h with breve below
+	{0x68, 0x32e}:   0x1e2b, // latin small letter h with breve below
+	{0x49, 0x330}:   0x1e2c, // latin capital letter i with tilde below
+	{0x69, 0x330}:   0x1e2d, // latin small letter i with tilde below
+	{0xcf, 0x301}:   0x1e2e, // latin capital letter i with diaeresis and acute
+	{0xef, 0x301}:   0x1e2f, // latin small letter i with diaeresis and acute
+	{0x4b, 0x301}:   0x1e30, // latin capital letter k with acute
+	{0x6b, 0x301}:   0x1e31, // latin small letter k with acute
+	{0x4b, 0x323}:   0x1e32, // latin capital letter k with dot below
+	{0x6b, 0x323}:   0x1e33, // latin small letter k with dot below
+	{0x4b, 0x331}:   0x1e34, // latin capital letter k with line below
+	{0x6b, 0x331}:   0x1e35, // latin small letter k with line below
+	{0x4c, 0x323}:   0x1e36, // latin capital letter l with dot below
+	{0x6c, 0x323}:   0x1e37, // latin small letter l with dot below
+	{0x1e36, 0x304}: 0x1e38, // latin capital letter l with dot below and macron
+	{0x1e37, 0x304}: 0x1e39, // latin small letter l with dot below and macron
+	{0x4c, 0x331}:   0x1e3a, // latin capital letter l with line below
+	{0x6c, 0x331}:   0x1e3b, // latin small letter l with line below
+	{0x4c, 0x32d}:   0x1e3c, // latin capital letter l with circumflex below
+	{0x6c, 0x32d}:   0x1e3d, // latin small letter l with circumflex below
+	{0x4d, 0x301}:   0x1e3e, // latin capital letter m with acute
+	{0x6d, 0x301}:   0x1e3f, // latin small letter m with acute
+	{0x4d, 0x307}:   0x1e40, // latin capital letter m with dot above
+	{0x6d, 0x307}:   0x1e41, // latin small letter m with dot above
+	{0x4d, 0x323}:   0x1e42, // latin capital letter m with dot below
+	{0x6d, 0x323}:   0x1e43, // latin small letter m with dot below
+	{0x4e, 0x307}:   0x1e44, // latin capital letter n with dot above
+	{0x6e, 0x307}:   0x1e45, // latin small letter n with dot above
+	{0x4e, 0x323}:   0x1e46, // latin capital letter n with dot below
+	{0x6e, 0x323}:   0x1e47, // latin small letter n with dot below
+	{0x4e, 0x331}:   0x1e48, // latin capital letter n with line below
+	{0x6e, 0x331}:   0x1e49, // latin small letter n with line below
+	{0x4e, 0x32d}:   0x1e4a, // latin capital letter n with circumflex below
+	{0x6e, 0x32d}:   0x1e4b, // latin small letter n with circumflex below
+	{0xd5, 0x301}:   0x1e4c, // latin capital letter o with tilde and acute
+	{0xf5, 0x301}:   0x1e4d, // latin small letter o with tilde and acute
+	{0xd5, 0x308}:   0x1e4e, // latin capital letter o with tilde and diaeresis
+	{0xf5, 0x308}:   0x1e4f, // latin small letter o with tilde and diaeresis
+	{0x14c, 0x300}:  0x1e50, // latin capital letter o with macron and grave
+	{0x14d, 0x300}:  0x1e51, // latin small letter o with macron and grave
+	{0x14c, 0x301}:  0x1e52, // latin capital letter o with macron and acute
+	{0x14d, 0x301}:  0x1e53, // latin small letter o with macron and acute
+	{0x50, 0x301}:   0x1e54, // latin capital letter p with acute
+	{0x70, 0x301}:   0x1e55, // latin small letter p with acute
+	{0x50, 0x307}:   0x1e56, // latin capital letter p with dot above
+	{0x70, 0x307}:   0x1e57, // latin small letter p with dot above
+	{0x52, 0x307}:   0x1e58, // latin capital letter r with dot above
+	{0x72, 0x307}:   0x1e59, // latin small letter r with dot above
+	{0x52, 0x323}:   0x1e5a, // latin capital letter r with dot below
+	{0x72, 0x323}:   0x1e5b, // latin small letter r with dot below
+	{0x1e5a, 0x304}: 0x1e5c, // latin capital letter r with dot below and macron
+	{0x1e5b, 0x304}: 0x1e5d, // latin small letter r with dot below and macron
+	{0x52, 0x331}:   0x1e5e, // latin capital letter r with line below
+	{0x72, 0x331}:   0x1e5f, // latin small letter r with line below
+	{0x53, 0x307}:   0x1e60, // latin capital letter s with dot above
+	{0x73, 0x307}:   0x1e61, // latin small letter s with dot above
+	{0x53, 0x323}:   0x1e62, // latin capital letter s with dot below
+	{0x73, 0x323}:   0x1e63, // latin small letter s with dot below
+	{0x15a, 0x307}:  0x1e64, // latin capital letter s with acute and dot above
+	{0x15b, 0x307}:  0x1e65, // latin small letter s with acute and dot above
+	{0x160, 0x307}:  0x1e66, // latin capital letter s with caron and dot above
+	{0x161, 0x307}:  0x1e67, // latin small letter s with caron and dot above
+	{0x1e62, 0x307}: 0x1e68, // latin capital letter s with dot below and dot above
+	{0x1e63, 0x307}: 0x1e69, // latin small letter s with dot below and dot above
+	{0x54, 0x307}:   0x1e6a, // latin capital letter t with dot above
+	{0x74, 0x307}:   0x1e6b, // latin small letter t with dot above
+	{0x54, 0x323}:   0x1e6c, // latin capital letter t with dot below
+	{0x74, 0x323}:   0x1e6d, // latin small letter t with dot below
+	{0x54, 0x331}:   0x1e6e, // latin capital letter t with line below
+	{0x74, 0x331}:   0x1e6f, // latin small letter t with line below
+	{0x54, 0x32d}:   0x1e70, // latin capital letter t with circumflex below
+	{0x74, 0x32d}:   0x1e71, // latin small letter t with circumflex below
+	{0x55, 0x324}:   0x1e72, // latin capital letter u with diaeresis below
+	{0x75, 0x324}:   0x1e73, // latin small letter u with diaeresis below
+	{0x55, 0x330}:   0x1e74, // latin capital letter u with tilde below
+	{0x75, 0x330}:   0x1e75, // latin small letter u with tilde below
+	{0x55, 0x32d}:   0x1e76, // latin capital letter u with circumflex below
+	{0x75, 0x32d}:   0x1e77, // latin small letter u with circumflex below
+	{0x168, 0x301}:  0x1e78, // latin capital letter u with tilde and acute
+	{0x169, 0x301}:  0x1e79, // latin small letter u with tilde and acute
+	{0x16a, 0x308}:  0x1e7a, // latin capital letter u with macron and diaeresis
+	{0x16b, 0x308}:  0x1e7b, // latin small letter u with macron and diaeresis
+	{0x56, 0x303}:   0x1e7c, // latin capital letter v with tilde
+	{0x76, 0x303}:   0x1e7d, // latin small letter v with tilde
+	{0x56, 0x323}:   0x1e7e, // latin capital letter v with dot below
+	{0x76, 0x323}:   0x1e7f, // latin small letter v with dot below
+	{0x57, 0x300}:   0x1e80, // latin capital letter w with grave
+	{0x77, 0x300}:   0x1e81, // latin small letter w with grave
+	{0x57, 0x301}:   0x1e82, // latin capital letter w with acute
+	{0x77, 0x301}:   0x1e83, // latin small letter w with acute
+	{0x57, 0x308}:   0x1e84, // latin capital letter w with diaeresis
+	{0x77, 0x308}:   0x1e85, // latin small letter w with diaeresis
+	{0x57, 0x307}:   0x1e86, // latin capital letter w with dot above
+	{0x77, 0x307}:   0x1e87, // latin small letter w with dot above
+	{0x57, 0x323}:   0x1e88, // latin capital letter w with dot below
+	{0x77, 0x323}:   0x1e89, // latin small letter w with dot below
+	{0x58, 0x307}:   0x1e8a, // latin capital letter x with dot above
+	{0x78, 0x307}:   0x1e8b, // latin small letter x with dot above
+	{0x58, 0x308}:   0x1e8c, // latin capital letter x with diaeresis
+	{0x78, 0x308}:   0x1e8d, // latin small letter x with diaeresis
+	{0x59, 0x307}:   0x1e8e, // latin capital letter y with dot above
+	{0x79, 0x307}:   0x1e8f, // latin small letter y with dot above
+	{0x5a, 0x302}:   0x1e90, // latin capital letter z with circumflex
+	{0x7a, 0x302}:   0x1e91, // latin small letter z with circumflex
+	{0x5a, 0x323}:   0x1e92, // latin capital letter z with dot below
+	{0x7a, 0x323}:   0x1e93, // latin small letter z with dot below
+	{0x5a, 0x331}:   0x1e94, // latin capital letter z with line below
+	{0x7a, 0x331}:   0x1e95, // latin small letter z with line below
+	{0x68, 0x331}:   0x1e96, // latin small letter h with line below
+	{0x74, 0x308}:   0x1e97, // latin small letter t with diaeresis
+	{0x77, 0x30a}:   0x1e98, // latin small letter w with ring above
+	{0x79, 0x30a}:   0x1e99, // latin small letter y with ring above
+	{0x17f, 0x307}:  0x1e9b, // latin small letter long s with dot above
+	{0x41, 0x323}:   0x1ea0, // latin capital letter a with dot below
+	{0x61, 0x323}:   0x1ea1, // latin small letter a with dot below
+	{0x41, 0x309}:   0x1ea2, // latin capital letter a with hook above
+	{0x61, 0x309}:   0x1ea3, // latin small letter a with hook above
+	{0xc2, 0x301}:   0x1ea4, // latin capital letter a with circumflex and acute
+	{0xe2, 0x301}:   0x1ea5, // latin small letter a with circumflex and acute
+	{0xc2, 0x300}:   0x1ea6, // latin capital letter a with circumflex and grave
+	{0xe2, 0x300}:   0x1ea7, // latin small letter a with circumflex and grave
+	{0xc2, 0x309}:   0x1ea8, // latin capital letter a with circumflex and hook above
+	{0xe2, 0x309}:   0x1ea9, // latin small letter a with circumflex and hook above
+	{0xc2, 0x303}:   0x1eaa, // latin capital letter a with circumflex and tilde
+	{0xe2, 0x303}:   0x1eab, // latin small letter a with circumflex and tilde
+	{0x1ea0, 0x302}: 0x1eac, // latin capital letter a with circumflex and dot below
+	{0x1ea1, 0x302}: 0x1ead, // latin small letter a with circumflex and dot below
+	{0x102, 0x301}:  0x1eae, // latin capital letter a with breve and acute
+	{0x103, 0x301}:  0x1eaf, // latin small letter a with breve and acute
+	{0x102, 0x300}:  0x1eb0, // latin capital letter a with breve and grave
+	{0x103, 0x300}:  0x1eb1, // latin small letter a with breve and grave
+	{0x102, 0x309}:  0x1eb2, // latin capital letter a with breve and hook above
+	{0x103, 0x309}:  0x1eb3, // latin small letter a with breve and hook above
+	{0x102, 0x303}:  0x1eb4, // latin capital letter a with breve and tilde
+	{0x103, 0x303}:  0x1eb5, // latin small letter a with breve and tilde
+	{0x1ea0, 0x306}: 0x1eb6, // latin capital letter a with breve and dot below
+	{0x1ea1, 0x306}: 0x1eb7, // latin small letter a with breve and dot below
+	{0x45, 0x323}:   0x1eb8, // latin capital letter e with dot below
+	{0x65, 0x323}:   0x1eb9, // latin small letter e with dot below
+	{0x45, 0x309}:   0x1eba, // latin capital letter e with hook above
+	{0x65, 0x309}:   0x1ebb, // latin small letter e with hook above
+	{0x45, 0x303}:   0x1ebc, // latin capital letter e with tilde
+	{0x65, 0x303}:   0x1ebd, // latin small letter e with tilde
+	{0xca, 0x301}:   0x1ebe, // latin capital letter e with circumflex and acute
+	{0xea, 0x301}:   0x1ebf, // latin small letter e with circumflex and acute
+	{0xca, 0x300}:   0x1ec0, // latin capital letter e with circumflex and grave
+	{0xea, 0x300}:   0x1ec1, // latin small letter e with circumflex and grave
+	{0xca, 0x309}:   0x1ec2, // latin capital letter e with circumflex and hook above
+	{0xea, 0x309}:   0x1ec3, // latin small letter e with circumflex and hook above
+	{0xca, 0x303}:   0x1ec4, // latin capital letter e with circumflex and tilde
+	{0xea, 0x303}:   0x1ec5, // latin small letter e with circumflex and tilde
+	{0x1eb8, 0x302}: 0x1ec6, // latin capital letter e with circumflex and dot below
+	{0x1eb9, 0x302}: 0x1ec7, // latin small letter e with circumflex and dot below
+	{0x49, 0x309}:   0x1ec8, // latin capital letter i with hook above
+	{0x69, 0x309}:   0x1ec9, // latin small letter i with hook above
+	{0x49, 0x323}:   0x1eca, // latin capital letter i with dot below
+	{0x69, 0x323}:   0x1ecb, // latin small letter i with dot below
+	{0x4f, 0x323}:   0x1ecc, // latin capital letter o with dot below
+	{0x6f, 0x323}:   0x1ecd, // latin small letter o with dot below
+	{0x4f, 0x309}:   0x1ece, // latin capital letter o with hook above
+	{0x6f, 0x309}:   0x1ecf, // latin small letter o with hook above
+	{0xd4, 0x301}:   0x1ed0, // latin capital letter o with circumflex and acute
+	{0xf4, 0x301}:   0x1ed1, // latin small letter o with circumflex and acute
+	{0xd4, 0x300}:   0x1ed2, // latin capital letter o with circumflex and grave
+	{0xf4, 0x300}:   0x1ed3, // latin small letter o with circumflex and grave
+	{0xd4, 0x309}:   0x1ed4, // latin capital letter o with circumflex and hook above
+	{0xf4, 0x309}:   0x1ed5, // latin small letter o with circumflex and hook above
+	{0xd4, 0x303}:   0x1ed6, // latin capital letter o with circumflex and tilde
+	{0xf4, 0x303}:   0x1ed7, // latin small letter o with circumflex and tilde
+	{0x1ecc, 0x302}: 0x1ed8, // latin capital letter o with circumflex and dot below
+	{0x1ecd, 0x302}: 0x1ed9, // latin small letter o with circumflex and dot below
+	{0x1a0, 0x301}:  0x1eda, // latin capital letter o with horn and acute
+	{0x1a1, 0x301}:  0x1edb, // latin small letter o with horn and acute
+	{0x1a0, 0x300}:  0x1edc, // latin capital letter o with horn and grave
+	{0x1a1, 0x300}:  0x1edd, // latin small letter o with horn and grave
+	{0x1a0, 0x309}:  0x1ede, // latin capital letter o with horn and hook above
+	{0x1a1, 0x309}:  0x1edf, // latin small letter o with horn and hook above
+	{0x1a0, 0x303}:  0x1ee0, // latin capital letter o with horn and tilde
+	{0x1a1, 0x303}:  0x1ee1, // latin small letter o with horn and tilde
+	{0x1a0, 0x323}:  0x1ee2, // latin capital letter o with horn and dot below
+	{0x1a1, 0x323}:  0x1ee3, // latin small letter o with horn and dot below
+	{0x55, 0x323}:   0x1ee4, // latin capital letter u with dot below
+	{0x75, 0x323}:   0x1ee5, // latin small letter u with dot below
+	{0x55, 0x309}:   0x1ee6, // latin capital letter u with hook above
+	{0x75, 0x309}:   0x1ee7, // latin small letter u with hook above
+	{0x1af, 0x301}:  0x1ee8, // latin capital letter u with horn and acute
+	{0x1b0, 0x301}:  0x1ee9, // latin small letter u with horn and acute
+	{0x1af, 0x300}:  0x1eea, // latin capital letter u with horn and grave
+	{0x1b0, 0x300}:  0x1eeb, // latin small letter u with horn and grave
+	{0x1af, 0x309}:  0x1eec, // latin capital letter u with horn and hook above
+	{0x1b0, 0x309}:  0x1eed, // latin small letter u with horn and hook above
+	{0x1af, 0x303}:  0x1eee, // latin capital letter u with horn and tilde
+	{0x1b0, 0x303}:  0x1eef, // latin small letter u with horn and tilde
+	{0x1af, 0x323}:  0x1ef0, // latin capital letter u with horn and dot below
+	{0x1b0, 0x323}:  0x1ef1, // latin small letter u with horn and dot below
+	{0x59, 0x300}:   0x1ef2, // latin capital letter y with grave
+	{0x79, 0x300}:   0x1ef3, // latin small letter y with grave
+	{0x59, 0x323}:   0x1ef4, // latin capital letter y with dot below
+	{0x79, 0x323}:   0x1ef5, // latin small letter y with dot below
+	{0x59, 0x309}:   0x1ef6, // latin capital letter y with hook above
+	{0x79, 0x309}:   0x1ef7, // latin small letter y with hook above
+	{0x59, 0x303}:   0x1ef8, // latin capital letter y with tilde
+	{0x79, 0x303}:   0x1ef9, // latin small letter y with tilde
+	{0x3b1, 0x313}:  0x1f00, // greek small letter alpha with psili
+	{0x3b1, 0x314}:  0x1f01, // greek small letter alpha with dasia
+	{0x1f00, 0x300}: 0x1f02, // greek small letter alpha with psili and varia
+	{0x1f01, 0x300}: 0x1f03, // greek small letter alpha with dasia and varia
+	{0x1f00, 0x301}: 0x1f04, // greek small letter alpha with psili and oxia
+	{0x1f01, 0x301}: 0x1f05, // greek small letter alpha with dasia and oxia
+	{0x1f00, 0x342}: 0x1f06, // greek small letter alpha with psili and perispomeni
+	{0x1f01, 0x342}: 0x1f07, // greek small letter alpha with dasia and perispomeni
+	{0x391, 0x313}:  0x1f08, // greek capital letter alpha with psili
+	{0x391, 0x314}:  0x1f09, // greek capital letter alpha with dasia
+	{0x1f08, 0x300}: 0x1f0a, // greek capital letter alpha with psili and varia
+	{0x1f09, 0x300}: 0x1f0b, // greek capital letter alpha with dasia and varia
+	{0x1f08, 0x301}: 0x1f0c, // greek capital letter alpha with psili and oxia
+	{0x1f09, 0x301}: 0x1f0d, // greek capital letter alpha with dasia and oxia
+	{0x1f08, 0x342}: 0x1f0e, // greek capital letter alpha with psili and perispomeni
+	{0x1f09, 0x342}: 0x1f0f, // greek capital letter alpha with dasia and perispomeni
+	{0x3b5, 0x313}:  0x1f10, // greek small letter epsilon with psili
+	{0x3b5, 0x314}:  0x1f11, // greek small letter epsilon with dasia
+	{0x1f10, 0x300}: 0x1f12, // greek small letter epsilon with psili and varia
+	{0x1f11, 0x300}: 0x1f13, // greek small letter epsilon with dasia and varia
+	{0x1f10, 0x301}: 0x1f14, // greek small letter epsilon with psili and oxia
+	{0x1f11, 0x301}: 0x1f15, // greek small letter epsilon with dasia and oxia
+	{0x395, 0x313}:  0x1f18, // greek capital letter epsilon with psili
+	{0x395, 0x314}:  0x1f19, // greek capital letter epsilon with dasia
+	{0x1f18, 0x300}: 0x1f1a, // greek capital letter epsilon with psili and varia
+	{0x1f19, 0x300}: 0x1f1b, // greek capital letter epsilon with dasia and varia
+	{0x1f18, 0x301}: 0x1f1c, // greek capital letter epsilon with psili and oxia
+	{0x1f19, 0x301}: 0x1f1d, // greek capital letter epsilon with dasia and oxia
+	{0x3b7, 0x313}:  0x1f20, // greek small letter eta with psili
+	{0x3b7, 0x314}:  0x1f21, // greek small letter eta with dasia
+	{0x1f20, 0x300}: 0x1f22, // greek small letter eta with psili and varia
+	{0x1f21, 0x300}: 0x1f23, // greek small letter eta with dasia and varia
+	{0x1f20, 0x301}: 0x1f24, // greek small letter eta with psili and oxia
+	{0x1f21, 0x301}: 0x1f25, // greek small letter eta with dasia and oxia
+	{0x1f20, 0x342}: 0x1f26, // greek small letter eta with psili and perispomeni
+	{0x1f21, 0x342}: 0x1f27, // greek small letter eta with dasia and perispomeni
+	{0x397, 0x313}:  0x1f28, // greek capital letter eta with psili
+	{0x397, 0x314}:  0x1f29, // greek capital letter eta with dasia
+	{0x1f28, 0x300}: 0x1f2a, // greek capital letter eta with psili and varia
+	{0x1f29, 0x300}: 0x1f2b, // greek capital letter eta with dasia and varia
+	{0x1f28, 0x301}: 0x1f2c, // greek capital letter eta with psili and oxia
+	{0x1f29, 0x301}: 0x1f2d, // greek capital letter eta with dasia and oxia
+	{0x1f28, 0x342}: 0x1f2e, // greek capital letter eta with psili and perispomeni
+	{0x1f29, 0x342}: 0x1f2f, // greek capital letter eta with dasia and perispomeni
+	{0x3b9, 0x313}:  0x1f30, // greek small letter iota with psili
+	{0x3b9, 0x314}:  0x1f31, // greek small letter iota with dasia
+	{0x1f30, 0x300}: 0x1f32, // greek small letter iota with psili and varia
+	{0x1f31, 0x300}: 0x1f33, // greek small letter iota with dasia and varia
+	{0x1f30, 0x301}: 0x1f34, // greek small letter iota with psili and oxia
+	{0x1f31, 0x301}: 0x1f35, // greek small letter iota with dasia and oxia
+	{0x1f30, 0x342}: 0x1f36, // greek small letter iota with psili and perispomeni
+	{0x1f31, 0x342}: 0x1f37, // greek small letter iota with dasia and perispomeni
+	{0x399, 0x313}:  0x1f38, // greek capital letter iota with psili
+	{0x399, 0x314}:  0x1f39, // greek capital letter iota with dasia
+	{0x1f38, 0x300}: 0x1f3a, // greek capital letter iota with psili and varia
+	{0x1f39, 0x300}: 0x1f3b, // greek capital letter iota with dasia and varia
+	{0x1f38, 0x301}: 0x1f3c, // greek capital letter iota with psili and oxia
+	{0x1f39, 0x301}: 0x1f3d, // greek capital letter iota with dasia and oxia
+	{0x1f38, 0x342}: 0x1f3e, // greek capital letter iota with psili and perispomeni
+	{0x1f39, 0x342}: 0x1f3f, // greek capital letter iota with dasia and perispomeni
+	{0x3bf, 0x313}:  0x1f40, // greek small letter omicron with psili
+	{0x3bf, 0x314}:  0x1f41, // greek small letter omicron with dasia
+	{0x1f40, 0x300}: 0x1f42, // greek small letter omicron with psili and varia
+	{0x1f41, 0x300}: 0x1f43, // greek small letter omicron with dasia and varia
+	{0x1f40, 0x301}: 0x1f44, // greek small letter omicron with psili and oxia
+	{0x1f41, 0x301}: 0x1f45, // greek small letter omicron with dasia and oxia
+	{0x39f, 0x313}:  0x1f48, // greek capital letter omicron with psili
+	{0x39f, 0x314}:  0x1f49, // greek capital letter omicron with dasia
+	{0x1f48, 0x300}: 0x1f4a, // greek capital letter omicron with psili and varia
+	{0x1f49, 0x300}: 0x1f4b, // greek capital letter omicron with dasia and varia
+	{0x1f48, 0x301}: 0x1f4c, // greek capital letter omicron with psili and oxia
+	{0x1f49, 0x301}: 0x1f4d, // greek capital letter omicron with dasia and oxia
+	{0x3c5, 0x313}:  0x1f50, // greek small letter upsilon with psili
+	{0x3c5, 0x314}:  0x1f51, // greek small letter upsilon with dasia
+	{0x1f50, 0x300}: 0x1f52, // greek small letter upsilon with psili and varia
+	{0x1f51, 0x300}: 0x1f53, // greek small letter upsilon with dasia and varia
+	{0x1f50, 0x301}: 0x1f54, // greek small letter upsilon with psili and oxia
+	{0x1f51, 0x301}: 0x1f55, // greek small letter upsilon with dasia and oxia
+	{0x1f50, 0x342}: 0x1f56, // greek small letter upsilon with psili and perispomeni
+	{0x1f51, 0x342}: 0x1f57, // greek small letter upsilon with dasia and perispomeni
+	{0x3a5, 0x314}:  0x1f59, // greek capital letter upsilon with dasia
+	{0x1f59, 0x300}: 0x1f5b, // greek capital letter upsilon with dasia and varia
+	{0x1f59, 0x301}: 0x1f5d, // greek capital letter upsilon with dasia and oxia
+	{0x1f59, 0x342}: 0x1f5f, // greek capital letter upsilon with dasia and perispomeni
+	{0x3c9, 0x313}:  0x1f60, // greek small letter omega with psili
+	{0x3c9, 0x314}:  0x1f61, // greek small letter omega with dasia
+	{0x1f60, 0x300}: 0x1f62, // greek small letter omega with psili and varia
+	{0x1f61, 0x300}: 0x1f63, // greek small letter omega with dasia and varia
+	{0x1f60, 0x301}: 0x1f64, // greek small letter omega with psili and oxia
+	{0x1f61, 0x301}: 0x1f65, // greek small letter omega with dasia and oxia
+	{0x1f60, 0x342}: 0x1f66, // greek small letter omega with psili and perispomeni
+	{0x1f61, 0x342}: 0x1f67, // greek small letter omega with dasia and perispomeni
+	{0x3a9, 0x313}:  0x1f68, // greek capital letter omega with psili
+	{0x3a9, 0x314}:  0x1f69, // greek capital letter omega with dasia
+	{0x1f68, 0x300}: 0x1f6a, // greek capital letter omega with psili and varia
+	{0x1f69, 0x300}: 0x1f6b, // greek capital letter omega with dasia and varia
+	{0x1f68, 0x301}: 0x1f6c, // greek capital letter omega with psili and oxia
+	{0x1f69, 0x301}: 0x1f6d, // greek capital letter omega with dasia and oxia
+	{0x1f68, 0x342}: 0x1f6e, // greek capital letter omega with psili and perispomeni
+	{0x1f69, 0x342}: 0x1f6f, // greek capital letter omega with dasia and perispomeni
+	{0x3b1, 0x300}:  0x1f70, // greek small letter alpha with varia
+	{0x3b5, 0x300}:  0x1f72, // greek small letter epsilon with varia
+	{0x3b7, 0x300}:  0x1f74, // greek small letter eta with varia
+	{0x3b9, 0x300}:  0x1f76, // greek small letter iota with varia
+	{0x3bf, 0x300}:  0x1f78, // greek small letter omicron with varia
+	{0x3c5, 0x300}:  0x1f7a, // greek small letter upsilon with varia
+	{0x3c9, 0x300}:  0x1f7c, // greek small letter omega with varia
+	{0x1f00, 0x345}: 0x1f80, // greek small letter alpha with psili and ypogegrammeni
+	{0x1f01, 0x345}: 0x1f81, // greek small letter alpha with dasia and ypogegrammeni
+	{0x1f02, 0x345}: 0x1f82, // greek small letter alpha with psili and varia and ypogegrammeni
+	{0x1f03, 0x345}: 0x1f83, // greek small letter alpha with dasia and varia and ypogegrammeni
+	{0x1f04, 0x345}: 0x1f84, // greek small letter alpha with psili and oxia and ypogegrammeni
+	{0x1f05, 0x345}: 0x1f85, // greek small letter alpha with dasia and oxia and ypogegrammeni
+	{0x1f06, 0x345}: 0x1f86, // greek small letter alpha with psili and perispomeni and ypogegrammeni
+	{0x1f07, 0x345}: 0x1f87, // greek small letter alpha with dasia and perispomeni and ypogegrammeni
+	{0x1f08, 0x345}: 0x1f88, // greek capital letter alpha with psili and prosgegrammeni
+	{0x1f09, 0x345}: 0x1f89, // greek capital letter alpha with dasia and prosgegrammeni
+	{0x1f0a, 0x345}: 0x1f8a, // greek capital letter alpha with psili and varia and prosgegrammeni
+	{0x1f0b, 0x345}: 0x1f8b, // greek capital letter alpha with dasia and varia and prosgegrammeni
+	{0x1f0c, 0x345}: 0x1f8c, // greek capital letter alpha with psili and oxia and prosgegrammeni
+	{0x1f0d, 0x345}: 0x1f8d, // greek capital letter alpha with dasia and oxia and prosgegrammeni
+	{0x1f0e, 0x345}: 0x1f8e, // greek capital letter alpha with psili and perispomeni and prosgegrammeni
+	{0x1f0f, 0x345}: 0x1f8f, // greek capital letter alpha with dasia and perispomeni and prosgegrammeni
+	{0x1f20, 0x345}: 0x1f90, // greek small letter eta with psili and ypogegrammeni
+	{0x1f21, 0x345}: 0x1f91, // greek small letter eta with dasia and ypogegrammeni
+	{0x1f22, 0x345}: 0x1f92, // greek small letter eta with psili and varia and ypogegrammeni
+	{0x1f23, 0x345}: 0x1f93, // greek small letter eta with dasia and varia and ypogegrammeni
+	{0x1f24, 0x345}: 0x1f94, // greek small letter eta with psili and oxia and ypogegrammeni
+	{0x1f25, 0x345}: 0x1f95, // greek small letter eta with dasia and oxia and ypogegrammeni
+	{0x1f26, 0x345}: 0x1f96, // greek small letter eta with psili and perispomeni and ypogegrammeni
+	{0x1f27, 0x345}: 0x1f97, // greek small letter eta with dasia and perispomeni and ypogegrammeni
+	{0x1f28, 0x345}: 0x1f98, // greek capital letter eta with psili and prosgegrammeni
+	{0x1f29, 0x345}: 0x1f99, // greek capital letter eta with dasia and prosgegrammeni
+	{0x1f2a, 0x345}: 0x1f9a, // greek capital letter eta with psili and varia and prosgegrammeni
+	{0x1f2b, 0x345}: 0x1f9b, // greek capital letter eta with dasia and varia and prosgegrammeni
+	{0x1f2c, 0x345}: 0x1f9c, // greek capital letter eta with psili and oxia and prosgegrammeni
+	{0x1f2d, 0x345}: 0x1f9d, // greek capital letter eta with dasia and oxia and prosgegrammeni
+	{0x1f2e, 0x345}: 0x1f9e, // greek capital letter eta with psili and perispomeni and prosgegrammeni
+	{0x1f2f, 0x345}: 0x1f9f, // greek capital letter eta with dasia and perispomeni and prosgegrammeni
+	{0x1f60, 0x345}: 0x1fa0, // greek small letter omega with psili and ypogegrammeni
+	{0x1f61, 0x345}: 0x1fa1, // greek small letter omega with dasia and ypogegrammeni
+	{0x1f62, 0x345}: 0x1fa2, // greek small letter omega with psili and varia and ypogegrammeni
+	{0x1f63, 0x345}: 0x1fa3, // greek small letter omega with dasia and varia and ypogegrammeni
+	{0x1f64, 0x345}: 0x1fa4, // greek small letter omega with psili and oxia and ypogegrammeni
+	{0x1f65, 0x345}: 0x1fa5, // greek small letter omega with dasia and oxia and ypogegrammeni
+	{0x1f66, 0x345}: 0x1fa6, // greek small letter omega with psili and perispomeni and ypogegrammeni
+	{0x1f67, 0x345}: 0x1fa7, // greek small letter omega with dasia and perispomeni and ypogegrammeni
+	{0x1f68, 0x345}: 0x1fa8, // greek capital letter omega with psili and prosgegrammeni
+	{0x1f69, 0x345}: 0x1fa9, // greek capital letter omega with dasia and prosgegrammeni
+	{0x1f6a, 0x345}: 0x1faa, // greek capital letter omega with psili and varia and prosgegrammeni
+	{0x1f6b, 0x345}: 0x1fab, // greek capital letter omega with dasia and varia and prosgegrammeni
+	{0x1f6c, 0x345}: 0x1fac, // greek capital letter omega with psili and oxia and prosgegrammeni
+	{0x1f6d, 0x345}: 0x1fad, // greek capital letter omega with dasia and oxia and prosgegrammeni
+	{0x1f6e, 0x345}: 0x1fae, // greek capital letter omega with psili and perispomeni and prosgegrammeni
+	{0x1f6f, 0x345}: 0x1faf, // greek capital letter omega with dasia and perispomeni and prosgegrammeni
+	{0x3b1, 0x306}:  0x1fb0, // greek small letter alpha with vrachy
+	{0x3b1, 0x304}:  0x1fb1, // greek small letter alpha with macron
+	{0x1f70, 0x345}: 0x1fb2, // greek small letter alpha with varia and ypogegrammeni
+	{0x3b1, 0x345}:  0x1fb3, // greek small letter alpha with ypogegrammeni
+	{0x3ac, 0x345}:  0x1fb4, // greek small letter alpha with oxia and ypogegrammeni
+	{0x3b1, 0x342}:  0x1fb6, // greek small letter alpha with perispomeni
+	{0x1fb6, 0x345}: 0x1fb7, // greek small letter alpha with perispomeni and ypogegrammeni
+	{0x391, 0x306}:  0x1fb8, // greek capital letter alpha with vrachy
+	{0x391, 0x304}:  0x1fb9, // greek capital letter alpha with macron
+	{0x391, 0x300}:  0x1fba, // greek capital letter alpha with varia
+	{0x391, 0x345}:  0x1fbc, // greek capital letter alpha with prosgegrammeni
+	{0xa8, 0x342}:   0x1fc1, // greek dialytika and perispomeni
+	{0x1f74, 0x345}: 0x1fc2, // greek small letter eta with varia and ypogegrammeni
+	{0x3b7, 0x345}:  0x1fc3, // greek small letter eta with ypogegrammeni
+	{0x3ae, 0x345}:  0x1fc4, // greek small letter eta with oxia and ypogegrammeni
+	{0x3b7, 0x342}:  0x1fc6, // greek small letter eta with perispomeni
+	{0x1fc6, 0x345}: 0x1fc7, // greek small letter eta with perispomeni and ypogegrammeni
+	{0x395, 0x300}:  0x1fc8, // greek capital letter epsilon with varia
+	{0x397, 0x300}:  0x1fca, // greek capital letter eta with varia
+	{0x397, 0x345}:  0x1fcc, // greek capital letter eta with prosgegrammeni
+	{0x1fbf, 0x300}: 0x1fcd, // greek psili and varia
+	{0x1fbf, 0x301}: 0x1fce, // greek psili and oxia
+	{0x1fbf, 0x342}: 0x1fcf, // greek psili and perispomeni
+	{0x3b9, 0x306}:  0x1fd0, // greek small letter iota with vrachy
+	{0x3b9, 0x304}:  0x1fd1, // greek small letter iota with macron
+	{0x3ca, 0x300}:  0x1fd2, // greek small letter iota with dialytika and varia
+	{0x3b9, 0x342}:  0x1fd6, // greek small letter iota with perispomeni
+	{0x3ca, 0x342}:  0x1fd7, // greek small letter iota with dialytika and perispomeni
+	{0x399, 0x306}:  0x1fd8, // greek capital letter iota with vrachy
+	{0x399, 0x304}:  0x1fd9, // greek capital letter iota with macron
+	{0x399, 0x300}:  0x1fda, // greek capital letter iota with varia
+	{0x1ffe, 0x300}: 0x1fdd, // greek dasia and varia
+	{0x1ffe, 0x301}: 0x1fde, // greek dasia and oxia
+	{0x1ffe, 0x342}: 0x1fdf, // greek dasia and perispomeni
+	{0x3c5, 0x306}:  0x1fe0, // greek small letter upsilon with vrachy
+	{0x3c5, 0x304}:  0x1fe1, // greek small letter upsilon with macron
+	{0x3cb, 0x300}:  0x1fe2, // greek small letter upsilon with dialytika and varia
+	{0x3c1, 0x313}:  0x1fe4, // greek small letter rho with psili
+	{0x3c1, 0x314}:  0x1fe5, // greek small letter rho with dasia
+	{0x3c5, 0x342}:  0x1fe6, // greek small letter upsilon with perispomeni
+	{0x3cb, 0x342}:  0x1fe7, // greek small letter upsilon with dialytika and perispomeni
+	{0x3a5, 0x306}:  0x1fe8, // greek capital letter upsilon with vrachy
+	{0x3a5, 0x304}:  0x1fe9, // greek capital letter upsilon with macron
+	{0x3a5, 0x300}:  0x1fea, // greek capital letter upsilon with varia
+	{0x3a1, 0x314}:  0x1fec, // greek capital letter rho with dasia
+	{0xa8, 0x300}:   0x1fed, // greek dialytika and varia
+	{0x1f7c, 0x345}: 0x1ff2, // greek small letter omega with varia and ypogegrammeni
+	{0x3c9, 0x345}:  0x1ff3, // greek small letter omega with ypogegrammeni
+	{0x3ce, 0x345}:  0x1ff4, // greek small letter omega with oxia and ypogegrammeni
+	{0x3c9, 0x342}:  0x1ff6, // greek small letter omega with perispomeni
+	{0x1ff6, 0x345}: 0x1ff7, // greek small letter omega with perispomeni and ypogegrammeni
+	{0x39f, 0x300}:  0x1ff8, // greek capital letter omicron with varia
+	{0x3a9, 0x300}:  0x1ffa, // greek capital letter omega with varia
+	{0x3a9, 0x345}:  0x1ffc, // greek capital letter omega with prosgegrammeni
+}
@@ -0,0 +1,328 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// Plain text rendering backend
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+)
+
+// PlainText is a type that implements the Renderer interface for plain text
+// output, for search indexing or previews. All formatting is dropped: blocks
+// are separated by blank lines, list items are prefixed with "- " or their
+// number, links are replaced by their text, images by their alt text, and
+// code blocks are kept as they are, without fences. Raw HTML is dropped.
+//
+// Do not create this directly, instead use the PlainTextRenderer function.
+type PlainText struct {
+	// the next number of each ordered list being rendered
+	listNumbers []int
+}
+
+// PlainTextRenderer creates and configures a PlainText object, which
+// satisfies the Renderer interface.
+//
+// flags is a set of PLAINTEXT_* options ORed together (currently no such
+// options are defined).
+func PlainTextRenderer(flags int) Renderer {
+	return &PlainText{}
+}
+
+// ToPlainText is a convenience function that renders input as plain text
+// with the given options.
+func ToPlainText(input []byte, opts Options) []byte {
+	return MarkdownOptions(input, PlainTextRenderer(0), opts)
+}
+
+func (options *PlainText) GetFlags() int {
+	return 0
+}
+
+// separate a block from the text before it by a blank line, or just start a
+// new line inside a list item
+func blockSeparator(out *bytes.Buffer) {
+	switch {
+	case out.Len() == 0:
+	case !bytes.HasSuffix(out.Bytes(), []byte("\n")):
+		out.WriteByte('\n')
+	case !bytes.HasSuffix(out.Bytes(), []byte("\n\n")):
+		out.WriteByte('\n')
+	}
+}
+
+// write text ending with a single newline
+func writeLine(out *bytes.Buffer, text []byte) {
+	out.Write(bytes.TrimRight(text, "\n"))
+	out.WriteByte('\n')
+}
+
+func (options *PlainText) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	blockSeparator(out)
+	writeLine(out, text)
+}
+
+func (options *PlainText) BlockQuote(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	writeLine(out, bytes.Trim(text, "\n"))
+}
+
+func (options *PlainText) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {
+	blockSeparator(out)
+	if len(title) > 0 {
+		writeLine(out, title)
+	}
+	if text = bytes.Trim(text, "\n"); len(text) > 0 {
+		if len(title) > 0 {
+			out.WriteByte('\n')
+		}
+		writeLine(out, text)
+	}
+}
+
+func (options *PlainText) Alert(out *bytes.Buffer, text []byte, kind string) {
+	options.Admonition(out, text, kind, alertTitle(kind))
+}
+
+func (options *PlainText) Div(out *bytes.Buffer, text []byte, class string) {
+	if text = bytes.Trim(text, "\n"); len(text) > 0 {
+		blockSeparator(out)
+		writeLine(out, text)
+	}
+}
+
+func (options *PlainText) BlockHtml(out *bytes.Buffer, text []byte) {
+}
+
+func (options *PlainText) MathBlock(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	writeLine(out, text)
+}
+
+func (options *PlainText) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	marker := out.Len()
+	blockSeparator(out)
+	start := out.Len()
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+	if out.Len() == start {
+		out.Truncate(marker)
+		return
+	}
+	out.WriteByte('\n')
+}
+
+func (options *PlainText) HRule(out *bytes.Buffer) {
+}
+
+func (options *PlainText) List(out *bytes.Buffer, text func() bool, flags int) {
+	options.NumberedList(out, text, flags, 1)
+}
+
+func (options *PlainText) NumberedList(out *bytes.Buffer, text func() bool, flags, start int) {
+	marker := out.Len()
+	if len(options.listNumbers) == 0 {
+		blockSeparator(out)
+	} else if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		// nested lists follow the item text directly
+		out.WriteByte('\n')
+	}
+	options.listNumbers = append(options.listNumbers, start)
+	ok := text()
+	options.listNumbers = options.listNumbers[:len(options.listNumbers)-1]
+	if !ok {
+		out.Truncate(marker)
+	}
+}
+
+func (options *PlainText) ListItem(out *bytes.Buffer, text []byte, flags int) {
+	var prefix string
+	switch {
+	case flags&LIST_TYPE_TERM != 0:
+	case flags&LIST_TYPE_DEFINITION != 0:
+		prefix = "  "
+	case flags&LIST_TYPE_ORDERED != 0:
+		n := len(options.listNumbers) - 1
+		prefix = strconv.Itoa(options.listNumbers[n]) + ". "
+		options.listNumbers[n]++
+	default:
+		prefix = "- "
+	}
+	if flags&LIST_ITEM_TASK != 0 {
+		if flags&LIST_ITEM_CHECKED != 0 {
+			prefix += "[x] "
+		} else {
+			prefix += "[ ] "
+		}
+	}
+
+	// indent the lines after the first to line up with it
+	indent := bytes.Repeat([]byte(" "), len(prefix))
+	out.WriteString(prefix)
+	for i, line := range bytes.Split(bytes.Trim(text, "\n"), []byte("\n")) {
+		if i > 0 {
+			out.WriteByte('\n')
+			if len(line) > 0 {
+				out.Write(indent)
+			}
+		}
+		out.Write(line)
+	}
+	out.WriteByte('\n')
+}
+
+func (options *PlainText) Paragraph(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	blockSeparator(out)
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+	out.WriteByte('\n')
+}
+
+func (options *PlainText) Table(out *bytes.Buffer, header []byte, body []byte, columnData []int) {
+	options.CaptionedTable(out, header, body, columnData, nil)
+}
+
+func (options *PlainText) CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte) {
+	blockSeparator(out)
+	if len(caption) > 0 {
+		writeLine(out, caption)
+	}
+	out.Write(header)
+	out.Write(body)
+}
+
+// the cells of a row are separated by tabs
+func (options *PlainText) TableRow(out *bytes.Buffer, text []byte) {
+	writeLine(out, bytes.TrimSuffix(text, []byte("\t")))
+}
+
+func (options *PlainText) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	options.TableCell(out, text, flags)
+}
+
+func (options *PlainText) TableCell(out *bytes.Buffer, text []byte, flags int) {
+	out.Write(text)
+	out.WriteByte('\t')
+}
+
+func (options *PlainText) Footnotes(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	blockSeparator(out)
+	options.listNumbers = append(options.listNumbers, 1)
+	ok := text()
+	options.listNumbers = options.listNumbers[:len(options.listNumbers)-1]
+	if !ok {
+		out.Truncate(marker)
+	}
+}
+
+func (options *PlainText) FootnoteItem(out *bytes.Buffer, name, text []byte, flags int) {
+	n := len(options.listNumbers) - 1
+	out.WriteByte('[')
+	out.WriteString(strconv.Itoa(options.listNumbers[n]))
+	out.WriteString("] ")
+	options.listNumbers[n]++
+	writeLine(out, bytes.TrimSpace(text))
+}
+
+func (options *PlainText) TitleBlock(out *bytes.Buffer, text []byte) {
+	blockSeparator(out)
+	writeLine(out, text)
+}
+
+func (options *PlainText) AutoLink(out *bytes.Buffer, link []byte, kind int) {
+	out.Write(bytes.TrimPrefix(link, []byte("mailto:")))
+}
+
+func (options *PlainText) CodeSpan(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) DoubleEmphasis(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) Emphasis(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) Image(out *bytes.Buffer, link []byte, title []byte, alt []byte) {
+	out.Write(alt)
+}
+
+func (options *PlainText) LineBreak(out *bytes.Buffer) {
+	out.WriteByte('\n')
+}
+
+func (options *PlainText) Link(out *bytes.Buffer, link []byte, title []byte, content []byte) {
+	out.Write(content)
+}
+
+func (options *PlainText) RawHtmlTag(out *bytes.Buffer, tag []byte) {
+}
+
+func (options *PlainText) TripleEmphasis(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) StrikeThrough(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) Superscript(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) Subscript(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) Highlight(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) Math(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) FootnoteRef(out *bytes.Buffer, ref []byte, id int) {
+	out.WriteByte('[')
+	out.WriteString(strconv.Itoa(id))
+	out.WriteByte(']')
+}
+
+func (options *PlainText) Entity(out *bytes.Buffer, entity []byte) {
+	out.WriteString(html.UnescapeString(string(entity)))
+}
+
+func (options *PlainText) NormalText(out *bytes.Buffer, text []byte) {
+	out.Write(text)
+}
+
+func (options *PlainText) DocumentHeader(out *bytes.Buffer) {
+}
+
+func (options *PlainText) DocumentFooter(out *bytes.Buffer) {
+}
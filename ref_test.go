@@ -14,8 +14,14 @@
 package blackfriday
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -126,3 +132,115 @@ func TestReference_EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK(t *testing.T) {
 	}
 	doTestsReference(t, files, EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK)
 }
+
+func TestReferenceMarkdownRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range files {
+		// the inputs for other renderers may need extensions
+		if _, err := os.Stat(strings.TrimSuffix(filename, ".text") + ".html"); err != nil {
+			continue
+		}
+		input, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Errorf("Couldn't open '%s', error: %v\n", filename, err)
+			continue
+		}
+
+		// rendering the normalized Markdown must give the same document
+		normalized := Markdown(input, MarkdownRenderer(0), 0)
+		expected := runMarkdownReference(string(input), 0)
+		actual := runMarkdownReference(string(normalized), 0)
+		if actual != expected {
+			t.Errorf("\n    [%#v]\nNormalized[%#v]\nExpected  [%#v]\nActual    [%#v]",
+				filename, string(normalized), expected, actual)
+		}
+	}
+}
+
+func TestJsonReference(t *testing.T) {
+	extensions := EXTENSION_TABLES | EXTENSION_HEADER_IDS
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "JSON output.text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "JSON output.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual bytes.Buffer
+	output := Markdown(input, JsonRenderer(0), extensions)
+	if err := json.Indent(&actual, output, "", "  "); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	if actual.String() != string(expected) {
+		t.Errorf("\nExpected[%s]\nActual  [%s]", expected, actual.String())
+	}
+
+	// every prefix of the input must give valid JSON too
+	if !testing.Short() {
+		for end := 1; end <= len(input); end++ {
+			if output := Markdown(input[:end], JsonRenderer(0), extensions); !json.Valid(output) {
+				t.Errorf("invalid JSON for [%#v]:\n%s", string(input[:end]), output)
+			}
+		}
+	}
+}
+
+func TestLatexReference(t *testing.T) {
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "LaTeX output.text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "LaTeX output.tex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := Markdown(input, LatexRenderer(0), EXTENSION_TABLES)
+	if string(actual) != string(expected) {
+		t.Errorf("\nExpected[%s]\nActual  [%s]", expected, actual)
+	}
+
+	// a complete page wraps the same body in an article
+	page := string(Markdown(input, LatexRenderer(LATEX_COMPLETE_PAGE), EXTENSION_TABLES))
+	if !strings.HasPrefix(page, "\\documentclass{article}\n") ||
+		!strings.Contains(page, "\\begin{document}\n"+string(expected)) ||
+		!strings.HasSuffix(page, "\n\\end{document}\n") {
+		t.Errorf("unexpected complete page:\n%s", page)
+	}
+}
+
+func TestXmlReference(t *testing.T) {
+	extensions := EXTENSION_FENCED_CODE | EXTENSION_HEADER_IDS | EXTENSION_DEFINITION_LISTS
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "XML output.text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "XML output.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := Markdown(input, XmlRenderer(0), extensions)
+	if string(actual) != string(expected) {
+		t.Errorf("\nExpected[%s]\nActual  [%s]", expected, actual)
+	}
+
+	// a complete page must be well-formed XML
+	page := Markdown(input, XmlRenderer(XML_COMPLETE_PAGE), extensions)
+	if !bytes.Contains(page, expected) {
+		t.Errorf("complete page does not contain the document:\n%s", page)
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(page))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, page)
+		}
+	}
+}
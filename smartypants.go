@@ -318,34 +318,6 @@ func smartNumberGeneric(out *bytes.Buffer, smrt *smartypantsData, previousChar b
 	return 0
 }
 
-func smartNumber(out *bytes.Buffer, smrt *smartypantsData, previousChar byte, text []byte) int {
-	if wordBoundary(previousChar) && previousChar != '/' && len(text) >= 3 {
-		if text[0] == '1' && text[1] == '/' && text[2] == '2' {
-			if len(text) < 4 || wordBoundary(text[3]) && text[3] != '/' {
-				out.WriteString("&frac12;")
-				return 2
-			}
-		}
-
-		if text[0] == '1' && text[1] == '/' && text[2] == '4' {
-			if len(text) < 4 || wordBoundary(text[3]) && text[3] != '/' || (len(text) >= 5 && tolower(text[3]) == 't' && tolower(text[4]) == 'h') {
-				out.WriteString("&frac14;")
-				return 2
-			}
-		}
-
-		if text[0] == '3' && text[1] == '/' && text[2] == '4' {
-			if len(text) < 4 || wordBoundary(text[3]) && text[3] != '/' || (len(text) >= 6 && tolower(text[3]) == 't' && tolower(text[4]) == 'h' && tolower(text[5]) == 's') {
-				out.WriteString("&frac34;")
-				return 2
-			}
-		}
-	}
-
-	out.WriteByte(text[0])
-	return 0
-}
-
 func smartDoubleQuoteVariant(out *bytes.Buffer, smrt *smartypantsData, previousChar byte, text []byte, quote byte) int {
 	nextChar := byte(0)
 	if len(text) > 1 {
@@ -388,26 +360,33 @@ var (
 	smartAmpRegularNBSP = smartAmp(false, true)
 )
 
+// smartypants builds the substitution table for the given HTML flags. Quotes
+// and the other punctuation come with HTML_USE_SMARTYPANTS, while dashes and
+// fractions each have their own flag and work with or without it.
 func smartypants(flags int) *smartypantsRenderer {
 	r := new(smartypantsRenderer)
-	addNBSP := flags&HTML_SMARTYPANTS_QUOTES_NBSP != 0
-	if flags&HTML_SMARTYPANTS_ANGLED_QUOTES == 0 {
-		r['"'] = smartDoubleQuote
-		if !addNBSP {
-			r['&'] = smartAmpRegular
-		} else {
-			r['&'] = smartAmpRegularNBSP
-		}
-	} else {
-		r['"'] = smartAngledDoubleQuote
-		if !addNBSP {
-			r['&'] = smartAmpAngled
+	if flags&HTML_USE_SMARTYPANTS != 0 {
+		addNBSP := flags&HTML_SMARTYPANTS_QUOTES_NBSP != 0
+		if flags&HTML_SMARTYPANTS_ANGLED_QUOTES == 0 {
+			r['"'] = smartDoubleQuote
+			if !addNBSP {
+				r['&'] = smartAmpRegular
+			} else {
+				r['&'] = smartAmpRegularNBSP
+			}
 		} else {
-			r['&'] = smartAmpAngledNBSP
+			r['"'] = smartAngledDoubleQuote
+			if !addNBSP {
+				r['&'] = smartAmpAngled
+			} else {
+				r['&'] = smartAmpAngledNBSP
+			}
 		}
+		r['\''] = smartSingleQuote
+		r['('] = smartParens
+		r['.'] = smartPeriod
+		r['`'] = smartBacktick
 	}
-	r['\''] = smartSingleQuote
-	r['('] = smartParens
 	if flags&HTML_SMARTYPANTS_DASHES != 0 {
 		if flags&HTML_SMARTYPANTS_LATEX_DASHES == 0 {
 			r['-'] = smartDash
@@ -415,16 +394,11 @@ func smartypants(flags int) *smartypantsRenderer {
 			r['-'] = smartDashLatex
 		}
 	}
-	r['.'] = smartPeriod
-	if flags&HTML_SMARTYPANTS_FRACTIONS == 0 {
-		r['1'] = smartNumber
-		r['3'] = smartNumber
-	} else {
+	if flags&HTML_SMARTYPANTS_FRACTIONS != 0 {
 		for ch := '1'; ch <= '9'; ch++ {
 			r[ch] = smartNumberGeneric
 		}
 	}
 	r['<'] = smartLeftAngle
-	r['`'] = smartBacktick
 	return r
 }
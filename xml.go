@@ -0,0 +1,515 @@
+//
+// Blackfriday Markdown Processor
+// Available at http://github.com/russross/blackfriday
+//
+// Copyright © 2011 Russ Ross <russ@russross.com>.
+// Distributed under the Simplified BSD License.
+// See README.md for details.
+//
+
+//
+//
+// DocBook XML rendering backend
+//
+//
+
+package blackfriday
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Xml renderer configuration options.
+const (
+	XML_COMPLETE_PAGE = 1 << iota // wrap the output in a DocBook <article>
+)
+
+// Xml is a type that implements the Renderer interface for DocBook XML
+// output. Headers open <section> elements that enclose everything up to the
+// next header of the same or a higher level, so the output has the nested
+// structure DocBook expects; headers inside block quotes and list items
+// cannot open sections and become <bridgehead> elements instead.
+//
+// Do not create this directly, instead use the XmlRenderer function.
+type Xml struct {
+	flags int // XML_* options
+
+	document *bytes.Buffer // the top-level output, where sections are opened
+	sections []int         // levels of the sections still open
+}
+
+// XmlRenderer creates and configures an Xml object, which satisfies the
+// Renderer interface.
+//
+// flags is a set of XML_* options ORed together. Without XML_COMPLETE_PAGE
+// only the contents of the article are rendered, ready to be included in
+// another document.
+func XmlRenderer(flags int) Renderer {
+	return &Xml{flags: flags}
+}
+
+func (options *Xml) GetFlags() int {
+	return options.flags
+}
+
+// write text with the XML special characters escaped, dropping the control
+// characters XML does not allow and replacing invalid UTF-8
+func xmlEscape(out *bytes.Buffer, text []byte) {
+	for len(text) > 0 {
+		r, size := utf8.DecodeRune(text)
+		switch {
+		case r == '<':
+			out.WriteString("&lt;")
+		case r == '>':
+			out.WriteString("&gt;")
+		case r == '&':
+			out.WriteString("&amp;")
+		case r == '"':
+			out.WriteString("&quot;")
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r':
+		default:
+			out.WriteRune(r)
+		}
+		text = text[size:]
+	}
+}
+
+// close the open sections down to the given level, keeping the ones above it
+func (options *Xml) closeSections(out *bytes.Buffer, level int) {
+	for len(options.sections) > 0 && options.sections[len(options.sections)-1] >= level {
+		out.WriteString("</section>\n")
+		options.sections = options.sections[:len(options.sections)-1]
+	}
+}
+
+// wrap the inline text of a tight list item in a paragraph, as DocBook list
+// items only hold blocks
+func xmlItemText(out *bytes.Buffer, text []byte, flags int) {
+	if flags&LIST_ITEM_CONTAINS_BLOCK != 0 {
+		out.Write(text)
+		return
+	}
+	out.WriteString("<para>")
+	out.Write(bytes.TrimRight(text, "\n"))
+	out.WriteString("</para>\n")
+}
+
+func (options *Xml) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	out.WriteString("<programlisting")
+	if fields := strings.Fields(info); len(fields) > 0 {
+		out.WriteString(" language=\"")
+		xmlEscape(out, []byte(fields[0]))
+		out.WriteString("\"")
+	}
+	out.WriteString(">")
+	xmlEscape(out, text)
+	out.WriteString("</programlisting>\n")
+}
+
+func (options *Xml) TitleBlock(out *bytes.Buffer, text []byte) {
+	text = bytes.TrimPrefix(text, []byte("% "))
+	text = bytes.Replace(text, []byte("\n% "), []byte("\n"), -1)
+	out.WriteString("<info><title>")
+	xmlEscape(out, bytes.TrimSpace(text))
+	out.WriteString("</title></info>\n")
+}
+
+func (options *Xml) MathBlock(out *bytes.Buffer, text []byte) {
+	out.WriteString("<informalequation><mathphrase>")
+	xmlEscape(out, text)
+	out.WriteString("</mathphrase></informalequation>\n")
+}
+
+func (options *Xml) BlockQuote(out *bytes.Buffer, text []byte) {
+	out.WriteString("<blockquote>\n")
+	out.Write(text)
+	out.WriteString("</blockquote>\n")
+}
+
+func (options *Xml) Admonition(out *bytes.Buffer, text []byte, kind string, title []byte) {
+	// DocBook has an element for some kinds, the others are notes
+	element := "note"
+	switch kind {
+	case "caution", "important", "tip", "warning":
+		element = kind
+	}
+	out.WriteString("<")
+	out.WriteString(element)
+	if element != kind {
+		out.WriteString(" role=\"")
+		xmlEscape(out, []byte(kind))
+		out.WriteString("\"")
+	}
+	out.WriteString(">\n")
+	if len(title) > 0 {
+		out.WriteString("<title>")
+		out.Write(title)
+		out.WriteString("</title>\n")
+	}
+	out.Write(text)
+	out.WriteString("</")
+	out.WriteString(element)
+	out.WriteString(">\n")
+}
+
+func (options *Xml) Alert(out *bytes.Buffer, text []byte, kind string) {
+	options.Admonition(out, text, kind, alertTitle(kind))
+}
+
+func (options *Xml) Div(out *bytes.Buffer, text []byte, class string) {
+	// DocBook has no generic container, so only the contents are kept
+	out.Write(text)
+}
+
+func (options *Xml) BlockHtml(out *bytes.Buffer, text []byte) {
+	// raw HTML is not DocBook, so keep it as literal text
+	out.WriteString("<literallayout role=\"html\">")
+	xmlEscape(out, bytes.TrimRight(text, "\n"))
+	out.WriteString("</literallayout>\n")
+}
+
+func (options *Xml) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	marker := out.Len()
+
+	if out != options.document {
+		out.WriteString("<bridgehead renderas=\"sect")
+		out.WriteString(strconv.Itoa(level))
+		out.WriteString("\">")
+		if !text() {
+			out.Truncate(marker)
+			return
+		}
+		out.WriteString("</bridgehead>\n")
+		return
+	}
+
+	sections := options.sections
+	options.closeSections(out, level)
+	out.WriteString("<section")
+	if id != "" {
+		out.WriteString(" xml:id=\"")
+		xmlEscape(out, []byte(id))
+		out.WriteString("\"")
+	}
+	out.WriteString(">\n<title>")
+	if !text() {
+		out.Truncate(marker)
+		options.sections = sections
+		return
+	}
+	out.WriteString("</title>\n")
+	options.sections = append(options.sections, level)
+}
+
+func (options *Xml) HRule(out *bytes.Buffer) {
+	out.WriteString("<para role=\"hrule\"/>\n")
+}
+
+func (options *Xml) List(out *bytes.Buffer, text func() bool, flags int) {
+	options.NumberedList(out, text, flags, 1)
+}
+
+func (options *Xml) NumberedList(out *bytes.Buffer, text func() bool, flags, start int) {
+	marker := out.Len()
+
+	element := "itemizedlist"
+	switch {
+	case flags&LIST_TYPE_DEFINITION != 0:
+		element = "variablelist"
+	case flags&LIST_TYPE_ORDERED != 0:
+		element = "orderedlist"
+	}
+	out.WriteString("<")
+	out.WriteString(element)
+	if flags&LIST_TYPE_ORDERED != 0 && start != 1 {
+		out.WriteString(" startingnumber=\"")
+		out.WriteString(strconv.Itoa(start))
+		out.WriteString("\"")
+	}
+	out.WriteString(">\n")
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+	out.WriteString("</")
+	out.WriteString(element)
+	out.WriteString(">\n")
+}
+
+// a DocBook variable list entry has one or more terms and a single list
+// item, so several definitions of the same terms share one item
+var (
+	xmlTermEnd  = []byte("</term>\n")
+	xmlEntryEnd = []byte("</listitem>\n</varlistentry>\n")
+)
+
+func (options *Xml) ListItem(out *bytes.Buffer, text []byte, flags int) {
+	switch {
+	case flags&LIST_TYPE_TERM != 0:
+		if !bytes.HasSuffix(out.Bytes(), xmlTermEnd) {
+			out.WriteString("<varlistentry>\n")
+		}
+		out.WriteString("<term>")
+		out.Write(bytes.TrimRight(text, "\n"))
+		out.Write(xmlTermEnd)
+	case flags&LIST_TYPE_DEFINITION != 0:
+		if bytes.HasSuffix(out.Bytes(), xmlEntryEnd) {
+			out.Truncate(out.Len() - len(xmlEntryEnd))
+		} else {
+			out.WriteString("<listitem>\n")
+		}
+		xmlItemText(out, text, flags)
+		out.Write(xmlEntryEnd)
+	default:
+		out.WriteString("<listitem>\n")
+		xmlItemText(out, text, flags)
+		out.WriteString("</listitem>\n")
+	}
+}
+
+func (options *Xml) Paragraph(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	out.WriteString("<para>")
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+	out.WriteString("</para>\n")
+}
+
+func (options *Xml) Table(out *bytes.Buffer, header []byte, body []byte, columnData []int) {
+	options.CaptionedTable(out, header, body, columnData, nil)
+}
+
+func (options *Xml) CaptionedTable(out *bytes.Buffer, header []byte, body []byte, columnData []int, caption []byte) {
+	if len(caption) > 0 {
+		out.WriteString("<table>\n<title>")
+		out.Write(caption)
+		out.WriteString("</title>\n")
+	} else {
+		out.WriteString("<informaltable>\n")
+	}
+	out.WriteString("<tgroup cols=\"")
+	out.WriteString(strconv.Itoa(len(columnData)))
+	out.WriteString("\">\n")
+	if len(header) > 0 {
+		out.WriteString("<thead>\n")
+		out.Write(header)
+		out.WriteString("</thead>\n")
+	}
+	out.WriteString("<tbody>\n")
+	out.Write(body)
+	out.WriteString("</tbody>\n</tgroup>\n")
+	if len(caption) > 0 {
+		out.WriteString("</table>\n")
+	} else {
+		out.WriteString("</informaltable>\n")
+	}
+}
+
+func (options *Xml) TableRow(out *bytes.Buffer, text []byte) {
+	out.WriteString("<row>")
+	out.Write(text)
+	out.WriteString("</row>\n")
+}
+
+func (options *Xml) TableHeaderCell(out *bytes.Buffer, text []byte, flags int) {
+	options.TableCell(out, text, flags)
+}
+
+func (options *Xml) TableCell(out *bytes.Buffer, text []byte, flags int) {
+	out.WriteString("<entry")
+	switch flags & TABLE_ALIGNMENT_MASK {
+	case TABLE_ALIGNMENT_LEFT:
+		out.WriteString(" align=\"left\"")
+	case TABLE_ALIGNMENT_RIGHT:
+		out.WriteString(" align=\"right\"")
+	case TABLE_ALIGNMENT_CENTER:
+		out.WriteString(" align=\"center\"")
+	case TABLE_ALIGNMENT_JUSTIFY:
+		out.WriteString(" align=\"justify\"")
+	}
+	out.WriteString(">")
+	out.Write(text)
+	out.WriteString("</entry>")
+}
+
+func (options *Xml) Footnotes(out *bytes.Buffer, text func() bool) {
+	marker := out.Len()
+	sections := options.sections
+	if out == options.document {
+		// the notes belong to the whole document, not its last section
+		options.closeSections(out, 1)
+	}
+	out.WriteString("<orderedlist role=\"footnotes\">\n")
+	if !text() {
+		out.Truncate(marker)
+		options.sections = sections
+		return
+	}
+	out.WriteString("</orderedlist>\n")
+}
+
+func (options *Xml) FootnoteItem(out *bytes.Buffer, name, text []byte, flags int) {
+	out.WriteString("<listitem xml:id=\"fn-")
+	xmlEscape(out, slugify(name))
+	out.WriteString("\">\n")
+	xmlItemText(out, text, flags)
+	out.WriteString("</listitem>\n")
+}
+
+func (options *Xml) AutoLink(out *bytes.Buffer, link []byte, kind int) {
+	if kind == LINK_TYPE_EMAIL {
+		out.WriteString("<email>")
+		xmlEscape(out, bytes.TrimPrefix(link, []byte("mailto:")))
+		out.WriteString("</email>")
+		return
+	}
+	out.WriteString("<link xlink:href=\"")
+	xmlEscape(out, link)
+	out.WriteString("\">")
+	xmlEscape(out, link)
+	out.WriteString("</link>")
+}
+
+func (options *Xml) CodeSpan(out *bytes.Buffer, text []byte) {
+	options.LanguageCodeSpan(out, text, "")
+}
+
+func (options *Xml) LanguageCodeSpan(out *bytes.Buffer, text []byte, lang string) {
+	out.WriteString("<code")
+	if lang != "" {
+		out.WriteString(" language=\"")
+		xmlEscape(out, []byte(lang))
+		out.WriteString("\"")
+	}
+	out.WriteString(">")
+	xmlEscape(out, text)
+	out.WriteString("</code>")
+}
+
+func (options *Xml) DoubleEmphasis(out *bytes.Buffer, text []byte) {
+	out.WriteString("<emphasis role=\"strong\">")
+	out.Write(text)
+	out.WriteString("</emphasis>")
+}
+
+func (options *Xml) Emphasis(out *bytes.Buffer, text []byte) {
+	out.WriteString("<emphasis>")
+	out.Write(text)
+	out.WriteString("</emphasis>")
+}
+
+func (options *Xml) Image(out *bytes.Buffer, link []byte, title []byte, alt []byte) {
+	out.WriteString("<inlinemediaobject><imageobject><imagedata fileref=\"")
+	xmlEscape(out, link)
+	out.WriteString("\"/></imageobject>")
+	if len(alt) > 0 {
+		out.WriteString("<textobject><phrase>")
+		xmlEscape(out, alt)
+		out.WriteString("</phrase></textobject>")
+	}
+	out.WriteString("</inlinemediaobject>")
+}
+
+func (options *Xml) LineBreak(out *bytes.Buffer) {
+	// DocBook has no line break element
+	out.WriteString("<?linebreak?>\n")
+}
+
+func (options *Xml) Link(out *bytes.Buffer, link []byte, title []byte, content []byte) {
+	out.WriteString("<link xlink:href=\"")
+	xmlEscape(out, link)
+	if len(title) > 0 {
+		out.WriteString("\" xlink:title=\"")
+		xmlEscape(out, title)
+	}
+	out.WriteString("\">")
+	out.Write(content)
+	out.WriteString("</link>")
+}
+
+func (options *Xml) RawHtmlTag(out *bytes.Buffer, tag []byte) {
+}
+
+func (options *Xml) TripleEmphasis(out *bytes.Buffer, text []byte) {
+	out.WriteString("<emphasis role=\"strong\"><emphasis>")
+	out.Write(text)
+	out.WriteString("</emphasis></emphasis>")
+}
+
+func (options *Xml) StrikeThrough(out *bytes.Buffer, text []byte) {
+	out.WriteString("<emphasis role=\"strikethrough\">")
+	out.Write(text)
+	out.WriteString("</emphasis>")
+}
+
+func (options *Xml) Superscript(out *bytes.Buffer, text []byte) {
+	out.WriteString("<superscript>")
+	out.Write(text)
+	out.WriteString("</superscript>")
+}
+
+func (options *Xml) Subscript(out *bytes.Buffer, text []byte) {
+	out.WriteString("<subscript>")
+	out.Write(text)
+	out.WriteString("</subscript>")
+}
+
+func (options *Xml) Highlight(out *bytes.Buffer, text []byte) {
+	out.WriteString("<emphasis role=\"highlight\">")
+	out.Write(text)
+	out.WriteString("</emphasis>")
+}
+
+func (options *Xml) Abbreviation(out *bytes.Buffer, text []byte, title []byte) {
+	out.WriteString("<abbrev>")
+	out.Write(text)
+	out.WriteString("</abbrev>")
+}
+
+func (options *Xml) Math(out *bytes.Buffer, text []byte) {
+	out.WriteString("<inlineequation><mathphrase>")
+	xmlEscape(out, text)
+	out.WriteString("</mathphrase></inlineequation>")
+}
+
+func (options *Xml) FootnoteRef(out *bytes.Buffer, ref []byte, id int) {
+	out.WriteString("<superscript><link linkend=\"fn-")
+	xmlEscape(out, slugify(ref))
+	out.WriteString("\">")
+	out.WriteString(strconv.Itoa(id))
+	out.WriteString("</link></superscript>")
+}
+
+func (options *Xml) Entity(out *bytes.Buffer, entity []byte) {
+	// most HTML entities are not defined in XML
+	xmlEscape(out, []byte(html.UnescapeString(string(entity))))
+}
+
+func (options *Xml) NormalText(out *bytes.Buffer, text []byte) {
+	xmlEscape(out, text)
+}
+
+func (options *Xml) DocumentHeader(out *bytes.Buffer) {
+	options.document = out
+	options.sections = options.sections[:0]
+	if options.flags&XML_COMPLETE_PAGE == 0 {
+		return
+	}
+	out.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	out.WriteString("<article xmlns=\"http://docbook.org/ns/docbook\" ")
+	out.WriteString("xmlns:xlink=\"http://www.w3.org/1999/xlink\" version=\"5.0\">\n")
+}
+
+func (options *Xml) DocumentFooter(out *bytes.Buffer) {
+	options.closeSections(out, 1)
+	if options.flags&XML_COMPLETE_PAGE == 0 {
+		return
+	}
+	out.WriteString("</article>\n")
+}